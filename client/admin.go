@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"swap-wallet/model"
+)
+
+// AdjustmentRequest credits (positive Amount) or debits (negative Amount) a
+// user's balance. ReasonCode is one of model.AdjustmentReasons.
+type AdjustmentRequest struct {
+	UserID        int     `json:"userId"`
+	Crypto        string  `json:"crypto"`
+	Amount        float64 `json:"amount"`
+	ReasonCode    string  `json:"reasonCode"`
+	Justification string  `json:"justification"`
+}
+
+// AdjustmentFilter narrows Adjustments. Zero values match everything.
+type AdjustmentFilter struct {
+	Status string
+	UserID int
+	Limit  int
+}
+
+type adjustmentDecision struct {
+	Note string `json:"note"`
+}
+
+// Adjust requests a manual balance adjustment. The calling user must be an
+// admin, and debits need their two-factor code set with WithTOTPCode.
+// Adjustments above the server's approval threshold come back with status
+// pending until another admin approves them.
+func (c *Client) Adjust(ctx context.Context, req AdjustmentRequest) (model.BalanceAdjustment, error) {
+	var adjustment model.BalanceAdjustment
+	err := c.do(ctx, "POST", "/admin/adjustments", nil, req, &adjustment)
+	return adjustment, err
+}
+
+// Adjustments lists balance adjustments, newest first.
+func (c *Client) Adjustments(ctx context.Context, filter AdjustmentFilter) ([]model.BalanceAdjustment, error) {
+	query := url.Values{}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.UserID > 0 {
+		query.Set("user", strconv.Itoa(filter.UserID))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var adjustments []model.BalanceAdjustment
+	err := c.do(ctx, "GET", "/admin/adjustments", query, nil, &adjustments)
+	return adjustments, err
+}
+
+func (c *Client) Adjustment(ctx context.Context, adjustmentID int) (model.BalanceAdjustment, error) {
+	var adjustment model.BalanceAdjustment
+	err := c.do(ctx, "GET", fmt.Sprintf("/admin/adjustments/%d", adjustmentID), nil, nil, &adjustment)
+	return adjustment, err
+}
+
+// ApproveAdjustment applies a pending adjustment requested by another admin.
+// Approving a debit needs a two-factor code set with WithTOTPCode.
+func (c *Client) ApproveAdjustment(ctx context.Context, adjustmentID int, note string) (model.BalanceAdjustment, error) {
+	var adjustment model.BalanceAdjustment
+	err := c.do(ctx, "POST", fmt.Sprintf("/admin/adjustments/%d/approve", adjustmentID), nil, adjustmentDecision{Note: note}, &adjustment)
+	return adjustment, err
+}
+
+// RejectAdjustment closes a pending adjustment without applying it.
+func (c *Client) RejectAdjustment(ctx context.Context, adjustmentID int, note string) (model.BalanceAdjustment, error) {
+	var adjustment model.BalanceAdjustment
+	err := c.do(ctx, "POST", fmt.Sprintf("/admin/adjustments/%d/reject", adjustmentID), nil, adjustmentDecision{Note: note}, &adjustment)
+	return adjustment, err
+}
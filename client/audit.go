@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"swap-wallet/model"
+	"time"
+)
+
+// AuditQuery narrows AuditLog. Zero values match everything; Before pages
+// back from the smallest id of an earlier result.
+type AuditQuery struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Before     int64
+	Limit      int
+}
+
+// AuditLog lists audit log entries, newest first. The calling user must be
+// compliance staff or an admin.
+func (c *Client) AuditLog(ctx context.Context, q AuditQuery) ([]model.AuditEntry, error) {
+	query := timeRange(q.From, q.To)
+	if q.Action != "" {
+		query.Set("action", q.Action)
+	}
+	if q.ActorID > 0 {
+		query.Set("actor", strconv.Itoa(q.ActorID))
+	}
+	if q.TargetType != "" {
+		query.Set("targetType", q.TargetType)
+	}
+	if q.TargetID != "" {
+		query.Set("targetId", q.TargetID)
+	}
+	if q.Before > 0 {
+		query.Set("before", strconv.FormatInt(q.Before, 10))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var entries []model.AuditEntry
+	err := c.do(ctx, "GET", "/audit", query, nil, &entries)
+	return entries, err
+}
+
+// VerifyAuditLog asks the server to recompute the audit log's hash chain.
+func (c *Client) VerifyAuditLog(ctx context.Context) (model.AuditVerification, error) {
+	var verification model.AuditVerification
+	err := c.do(ctx, "GET", "/audit/verify", nil, nil, &verification)
+	return verification, err
+}
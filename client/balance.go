@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+type Balance struct {
+	Crypto         string    `json:"crypto"`
+	CryptoBalance  float64   `json:"cryptoBalance"`
+	USDBalance     float64   `json:"USDBalance"`
+	Quote          string    `json:"quote"`
+	QuoteBalance   float64   `json:"quoteBalance"`
+	Price          float64   `json:"price"`
+	PriceTimestamp time.Time `json:"priceTimestamp"`
+}
+
+type PortfolioBalance struct {
+	CryptoName     string    `json:"crypto_name"`
+	CryptoBalance  float64   `json:"crypto_balance"`
+	USDBalance     float64   `json:"usd_balance"`
+	Quote          string    `json:"quote"`
+	QuoteBalance   float64   `json:"quote_balance"`
+	Price          float64   `json:"price"`
+	PriceTimestamp time.Time `json:"price_timestamp"`
+}
+
+type Portfolio struct {
+	Quote    string             `json:"quote"`
+	Total    float64            `json:"total"`
+	Balances []PortfolioBalance `json:"balances"`
+}
+
+// Balance returns the user's balance of crypto valued in quote. An empty
+// quote means USD.
+func (c *Client) Balance(ctx context.Context, crypto, quote string) (Balance, error) {
+	query := url.Values{"crypto": {crypto}}
+	if quote != "" {
+		query.Set("quote", quote)
+	}
+
+	var balance Balance
+	err := c.do(ctx, "GET", "/balance", query, nil, &balance)
+	return balance, err
+}
+
+// Balances returns every balance of the user valued in quote, with the
+// portfolio total. An empty quote means USD.
+func (c *Client) Balances(ctx context.Context, quote string) (Portfolio, error) {
+	query := url.Values{}
+	if quote != "" {
+		query.Set("quote", quote)
+	}
+
+	var portfolio Portfolio
+	err := c.do(ctx, "GET", "/portfolio", query, nil, &portfolio)
+	return portfolio, err
+}
@@ -0,0 +1,99 @@
+// Package client is a Go client for the swap-wallet HTTP API. A Client acts
+// on behalf of one user, identified by the userId header the API
+// authenticates with.
+//
+// Requests that fail transiently (network errors, 429, 5xx and
+// request_in_progress) are retried with exponential backoff. Every POST
+// carries an Idempotency-Key that stays the same across retries, so a retried
+// exchange is never applied twice. Package clienttest provides an in-memory
+// server for testing code built on this package.
+package client
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+type Client struct {
+	baseURL    string
+	userID     int
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// RetryPolicy controls retries of transient failures. Attempt n waits
+// BaseDelay * 2^(n-1), at most MaxDelay, unless the server asks for longer
+// with Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts over about a second.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// NoRetries sends every request once.
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+type Option func(*Client)
+
+// WithUserID sets the user the client acts for.
+func WithUserID(userID int) Option {
+	return func(c *Client) {
+		c.userID = userID
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30
+// seconds.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New returns a client for the API at baseURL, for example
+// http://localhost:8080.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ForUser returns a copy of the client that acts for userID.
+func (c *Client) ForUser(userID int) *Client {
+	copied := *c
+	copied.userID = userID
+	return &copied
+}
+
+// UserID returns the user the client acts for.
+func (c *Client) UserID() int {
+	return c.userID
+}
+
+// Message is the body of actions that only report success.
+type Message struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,455 @@
+// Package clienttest provides an in-memory fake of the swap-wallet HTTP API
+// for testing code built on package client.
+//
+//	server := clienttest.NewServer()
+//	defer server.Close()
+//	server.SetPrice("BTC", 60000)
+//	server.SetPrice("ETH", 3000)
+//	server.SetBalance(1, "BTC", 0.5)
+//	c := server.Client(1)
+//
+// The fake serves /balance, /balances, /exchange/preview and /exchange/apply
+// with the API's response and error formats. Prices are given in USD and
+// exchanges convert directly between assets without fees. Quotes are
+// single-use, and POSTs honour Idempotency-Key like the real API.
+package clienttest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"swap-wallet/client"
+	"sync"
+	"time"
+)
+
+const quoteTTL = time.Minute
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	balances   map[int]map[string]float64
+	prices     map[string]float64
+	quotes     map[string]fakeQuote
+	replies    map[string]recordedReply
+	failures   []failure
+	requests   map[string]int
+	applyCount int
+}
+
+type fakeQuote struct {
+	userID int
+	quote  client.Quote
+	source string
+	target string
+	// maxSlippage is the fraction of adverse rate movement accepted.
+	maxSlippage float64
+}
+
+type recordedReply struct {
+	status int
+	body   []byte
+}
+
+type failure struct {
+	path   string
+	status int
+	code   string
+}
+
+// NewServer starts a fake API with no users, balances or prices.
+func NewServer() *Server {
+	s := &Server{
+		balances: map[int]map[string]float64{},
+		prices:   map[string]float64{"USD": 1},
+		quotes:   map[string]fakeQuote{},
+		replies:  map[string]recordedReply{},
+		requests: map[string]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balance", s.handleBalance)
+	mux.HandleFunc("/portfolio", s.handlePortfolio)
+	mux.HandleFunc("/exchange/preview", s.handlePreview)
+	mux.HandleFunc("/exchange/apply", s.handleApply)
+	s.Server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+// Client returns a client for the fake acting as userID. Retries are quick so
+// that tests of retry behaviour stay fast.
+func (s *Server) Client(userID int, opts ...client.Option) *client.Client {
+	opts = append([]client.Option{
+		client.WithUserID(userID),
+		client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}),
+	}, opts...)
+	return client.New(s.URL, opts...)
+}
+
+// AddUser makes userID known with no balances.
+func (s *Server) AddUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.balances[userID] == nil {
+		s.balances[userID] = map[string]float64{}
+	}
+}
+
+// SetBalance sets a balance of userID, adding the user if needed.
+func (s *Server) SetBalance(userID int, crypto string, amount float64) {
+	s.AddUser(userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[userID][crypto] = amount
+}
+
+// Balance returns the current balance of userID in crypto.
+func (s *Server) Balance(userID int, crypto string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[userID][crypto]
+}
+
+// SetPrice sets the USD price of crypto. Changing a price after a quote makes
+// applying it subject to the quote's slippage limit.
+func (s *Server) SetPrice(crypto string, usd float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[crypto] = usd
+}
+
+// FailNext makes the next request to path fail with status and error code,
+// before it reaches the fake's handlers. Queued failures are used in order.
+func (s *Server) FailNext(path string, status int, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{path: path, status: status, code: code})
+}
+
+// ExpireQuotes makes every outstanding quote expire.
+func (s *Server) ExpireQuotes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes = map[string]fakeQuote{}
+}
+
+// Requests returns how many requests reached path, including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Applied returns how many exchanges were applied.
+func (s *Server) Applied() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyCount
+}
+
+// intercept counts requests, injects queued failures and replays responses
+// to repeated idempotency keys.
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		for i, f := range s.failures {
+			if f.path == r.URL.Path {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+				s.mu.Unlock()
+				writeError(w, f.status, f.code, "injected failure")
+				return
+			}
+		}
+
+		key := r.Header.Get(client.IdempotencyKeyHeader)
+		scope := r.Header.Get("userId") + " " + r.URL.Path + " " + key
+		if reply, ok := s.replies[scope]; ok && key != "" {
+			s.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(reply.status)
+			w.Write(reply.body)
+			return
+		}
+		s.mu.Unlock()
+
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, r)
+		if recorder.Code < http.StatusInternalServerError {
+			s.mu.Lock()
+			s.replies[scope] = recordedReply{status: recorder.Code, body: recorder.Body.Bytes()}
+			s.mu.Unlock()
+		}
+		for name, values := range recorder.Header() {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	})
+}
+
+// user returns the caller's id, or writes invalid_user. It must be called
+// with s.mu held.
+func (s *Server) user(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(r.Header.Get("userId"))
+	if err != nil || s.balances[userID] == nil {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidUser, "Invalid User ID")
+		return 0, false
+	}
+	return userID, true
+}
+
+// price returns the price of crypto in quote. It must be called with s.mu
+// held.
+func (s *Server) price(crypto, quote string) (float64, bool) {
+	cryptoUSD, ok := s.prices[crypto]
+	quoteUSD, quoteOK := s.prices[quote]
+	if !ok || !quoteOK || quoteUSD == 0 {
+		return 0, false
+	}
+	return cryptoUSD / quoteUSD, true
+}
+
+func quoteParam(r *http.Request) string {
+	quote := strings.ToUpper(r.URL.Query().Get("quote"))
+	if quote == "" {
+		return "USD"
+	}
+	return quote
+}
+
+func (s *Server) handleBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	crypto, quote := r.URL.Query().Get("crypto"), quoteParam(r)
+	price, ok := s.price(crypto, quote)
+	if !ok {
+		writeError(w, http.StatusServiceUnavailable, client.CodePriceUnavailable, fmt.Sprintf("price of %s in %s is unavailable", crypto, quote))
+		return
+	}
+	amount := s.balances[userID][crypto]
+	usdPrice, _ := s.price(crypto, "USD")
+	writeJSON(w, http.StatusOK, client.Balance{
+		Crypto:         crypto,
+		CryptoBalance:  amount,
+		USDBalance:     amount * usdPrice,
+		Quote:          quote,
+		QuoteBalance:   amount * price,
+		Price:          price,
+		PriceTimestamp: time.Now().UTC(),
+	})
+}
+
+func (s *Server) handlePortfolio(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	quote := quoteParam(r)
+	portfolio := client.Portfolio{Quote: quote, Balances: []client.PortfolioBalance{}}
+	var cryptos []string
+	for crypto := range s.balances[userID] {
+		cryptos = append(cryptos, crypto)
+	}
+	sort.Strings(cryptos)
+
+	for _, crypto := range cryptos {
+		price, ok := s.price(crypto, quote)
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, client.CodePriceUnavailable, fmt.Sprintf("price of %s in %s is unavailable", crypto, quote))
+			return
+		}
+		amount := s.balances[userID][crypto]
+		usdPrice, _ := s.price(crypto, "USD")
+		portfolio.Total += amount * price
+		portfolio.Balances = append(portfolio.Balances, client.PortfolioBalance{
+			CryptoName:     crypto,
+			CryptoBalance:  amount,
+			USDBalance:     amount * usdPrice,
+			Quote:          quote,
+			QuoteBalance:   amount * price,
+			Price:          price,
+			PriceTimestamp: time.Now().UTC(),
+		})
+	}
+	writeJSON(w, http.StatusOK, portfolio)
+}
+
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	source, target := query.Get("source"), query.Get("target")
+	sourceAmount, sourceErr := strconv.ParseFloat(query.Get("sourceAmount"), 64)
+	targetAmount, targetErr := strconv.ParseFloat(query.Get("targetAmount"), 64)
+	maxSlippage, _ := strconv.ParseFloat(query.Get("maxSlippage"), 64)
+	if source == "" || target == "" || (sourceErr == nil) == (targetErr == nil) {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidRequest, "source, target and exactly one of sourceAmount or targetAmount are required")
+		return
+	}
+	if source == target {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidRequest, "source and target must differ")
+		return
+	}
+
+	rate, ok := s.price(source, target)
+	if !ok {
+		writeError(w, http.StatusBadRequest, client.CodeUnknownAsset, fmt.Sprintf("no price for %s/%s; call SetPrice", source, target))
+		return
+	}
+
+	fixedSide := "source"
+	if sourceErr == nil {
+		targetAmount = sourceAmount * rate
+	} else {
+		fixedSide = "target"
+		sourceAmount = targetAmount / rate
+	}
+	if sourceAmount <= 0 || targetAmount <= 0 {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidRequest, "amount must be positive")
+		return
+	}
+
+	quote, err := s.issueQuote(userID, source, target, sourceAmount, targetAmount, rate, fixedSide, maxSlippage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, client.CodeInternal, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, quote)
+}
+
+// issueQuote stores and returns a quote. It must be called with s.mu held.
+func (s *Server) issueQuote(userID int, source, target string, sourceAmount, targetAmount, rate float64, fixedSide string, maxSlippage float64) (client.Quote, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return client.Quote{}, err
+	}
+
+	quote := client.Quote{
+		SourceAmount: sourceAmount,
+		TargetAmount: targetAmount,
+		Rate:         rate,
+		FixedSide:    fixedSide,
+		Legs: []client.QuoteLeg{{
+			SourceCrypto: source,
+			TargetCrypto: target,
+			SourceAmount: sourceAmount,
+			TargetAmount: targetAmount,
+			Rate:         rate,
+		}},
+		Token:     hex.EncodeToString(token),
+		ExpiresAt: time.Now().Add(quoteTTL).UTC(),
+	}
+	s.quotes[quote.Token] = fakeQuote{userID: userID, quote: quote, source: source, target: target, maxSlippage: maxSlippage}
+	return quote, nil
+}
+
+func (s *Server) handleApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, client.CodeInvalidRequest, "use POST")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	pending, ok := s.quotes[body.Token]
+	if !ok || pending.userID != userID || time.Now().After(pending.quote.ExpiresAt) {
+		writeError(w, http.StatusGone, client.CodeQuoteExpired, "quote has expired or was already used")
+		return
+	}
+	delete(s.quotes, body.Token)
+	quote := pending.quote
+
+	liveRate, _ := s.price(pending.source, pending.target)
+	if movement := (quote.Rate - liveRate) / quote.Rate; movement > pending.maxSlippage {
+		s.writeSlippage(w, pending, liveRate)
+		return
+	}
+
+	balances := s.balances[userID]
+	if balances[pending.source] < quote.SourceAmount-1e-12 {
+		writeError(w, http.StatusUnprocessableEntity, client.CodeInsufficientFund, "insufficient balance to complete the exchange")
+		return
+	}
+	balances[pending.source] = math.Max(0, balances[pending.source]-quote.SourceAmount)
+	balances[pending.target] += quote.TargetAmount
+	s.applyCount++
+
+	writeJSON(w, http.StatusOK, client.Message{Message: "Conversion finalized successfully"})
+}
+
+// writeSlippage rejects a quote whose rate moved too far and offers a requote
+// at the live rate keeping the fixed side. It must be called with s.mu held.
+func (s *Server) writeSlippage(w http.ResponseWriter, pending fakeQuote, liveRate float64) {
+	quote := pending.quote
+	sourceAmount, targetAmount := quote.SourceAmount, quote.SourceAmount*liveRate
+	if quote.FixedSide == "target" {
+		sourceAmount, targetAmount = quote.TargetAmount/liveRate, quote.TargetAmount
+	}
+	requote, err := s.issueQuote(pending.userID, pending.source, pending.target, sourceAmount, targetAmount, liveRate, quote.FixedSide, pending.maxSlippage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, client.CodeInternal, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"error": map[string]string{
+			"code":    client.CodeSlippageExceeded,
+			"message": fmt.Sprintf("rate moved from %f to %f beyond the allowed slippage", quote.Rate, liveRate),
+		},
+		"quotedRate": quote.Rate,
+		"liveRate":   liveRate,
+		"quote":      requote,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]map[string]string{
+		"error": {"code": code, "message": message},
+	})
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error codes returned by the API. See the Errors section of the readme.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidUser          = "invalid_user"
+	CodeInvalidQuote         = "invalid_quote"
+	CodeUnknownAsset         = "unknown_asset"
+	CodeNotFound             = "not_found"
+	CodeSlippageExceeded     = "slippage_exceeded"
+	CodeQuoteExpired         = "quote_expired"
+	CodeInsufficientFund     = "insufficient_funds"
+	CodeAssetDisabled        = "asset_disabled"
+	CodeNoRoute              = "no_route"
+	CodePriceUnavailable     = "price_unavailable"
+	CodeRequestInProgress    = "request_in_progress"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeRateLimited          = "rate_limited"
+	CodeForbidden            = "forbidden"
+	CodeAlreadyDecided       = "already_decided"
+	CodeAccountClosed        = "account_closed"
+	CodeAccountRestricted    = "account_restricted"
+	CodeAlreadyExists        = "already_exists"
+	CodeAccountNotEmpty      = "account_not_empty"
+	CodeTwoFactorRequired    = "two_factor_required"
+	CodeInvalidTwoFactor     = "invalid_two_factor_code"
+	CodeTwoFactorLocked      = "two_factor_locked"
+	CodeInternal             = "internal_error"
+)
+
+// Error is a request the API rejected. Code is the stable error code of the
+// API's error envelope, such as insufficient_funds or quote_expired.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	Details    []FieldError
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// IsCode reports whether err is an API error with the given code.
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, e.Code)
+	for _, detail := range e.Details {
+		message += fmt.Sprintf("; %s %s", detail.Field, detail.Message)
+	}
+	if e.RequestID != "" {
+		message += ", request " + e.RequestID
+	}
+	return message
+}
+
+// errorResponse is the API's error envelope. Slippage rejections carry extra
+// fields next to it, which is why the raw body is kept.
+type errorResponse struct {
+	Error struct {
+		Code      string       `json:"code"`
+		Message   string       `json:"message"`
+		Details   []FieldError `json:"details"`
+		RequestID string       `json:"requestId"`
+	} `json:"error"`
+}
+
+func responseError(resp *http.Response, data []byte) *Error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	var envelope errorResponse
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Details = envelope.Error.Details
+		if envelope.Error.RequestID != "" {
+			apiErr.RequestID = envelope.Error.RequestID
+		}
+		return apiErr
+	}
+
+	apiErr.Code = "http_" + strconv.Itoa(resp.StatusCode)
+	apiErr.Message = strings.TrimSpace(string(data))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PreviewRequest describes an exchange to quote. Exactly one of
+// SourceAmount, the amount to spend, and TargetAmount, the amount to
+// receive, must be set.
+type PreviewRequest struct {
+	Source       string
+	Target       string
+	SourceAmount float64
+	TargetAmount float64
+	// MaxSlippage is the fraction of adverse rate movement accepted when the
+	// quote is applied.
+	MaxSlippage float64
+}
+
+type QuoteLeg struct {
+	SourceCrypto string  `json:"sourceCrypto"`
+	TargetCrypto string  `json:"targetCrypto"`
+	SourceAmount float64 `json:"sourceAmount"`
+	TargetAmount float64 `json:"targetAmount"`
+	Rate         float64 `json:"rate"`
+	Spread       float64 `json:"spread"`
+	FeeRate      float64 `json:"feeRate"`
+	Fee          float64 `json:"fee"`
+}
+
+// Quote is a priced exchange. Apply it with ApplyExchange before ExpiresAt.
+type Quote struct {
+	SourceAmount float64    `json:"sourceAmount"`
+	TargetAmount float64    `json:"targetAmount"`
+	Fee          float64    `json:"fee"`
+	Rate         float64    `json:"rate"`
+	FixedSide    string     `json:"fixedSide"`
+	Legs         []QuoteLeg `json:"legs"`
+	Token        string     `json:"token"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+}
+
+// SlippageError is returned by ApplyExchange when the rate moved beyond the
+// allowed slippage. Quote is a fresh quote when the server requotes.
+type SlippageError struct {
+	Err        *Error
+	QuotedRate float64
+	LiveRate   float64
+	Quote      *Quote
+}
+
+func (e *SlippageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SlippageError) Unwrap() error {
+	return e.Err
+}
+
+// PreviewExchange quotes an exchange without applying it.
+func (c *Client) PreviewExchange(ctx context.Context, req PreviewRequest) (Quote, error) {
+	if (req.SourceAmount > 0) == (req.TargetAmount > 0) {
+		return Quote{}, fmt.Errorf("exactly one of SourceAmount or TargetAmount must be positive")
+	}
+
+	query := url.Values{"source": {req.Source}, "target": {req.Target}}
+	if req.SourceAmount > 0 {
+		query.Set("sourceAmount", strconv.FormatFloat(req.SourceAmount, 'f', -1, 64))
+	} else {
+		query.Set("targetAmount", strconv.FormatFloat(req.TargetAmount, 'f', -1, 64))
+	}
+	if req.MaxSlippage > 0 {
+		query.Set("maxSlippage", strconv.FormatFloat(req.MaxSlippage, 'f', -1, 64))
+	}
+
+	var quote Quote
+	err := c.do(ctx, "GET", "/exchange/preview", query, nil, &quote)
+	return quote, err
+}
+
+// ApplyExchange applies a quote by its token. A rate move beyond the allowed
+// slippage is reported as a *SlippageError. Exchanges above the two-factor
+// threshold need a code set with WithTOTPCode.
+func (c *Client) ApplyExchange(ctx context.Context, token string) error {
+	req, err := c.newRequest(ctx, "POST", "/exchange/apply", nil, map[string]string{"token": token})
+	if err != nil {
+		return err
+	}
+
+	data, err := c.send(ctx, req)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Code == CodeSlippageExceeded {
+		var body struct {
+			QuotedRate float64 `json:"quotedRate"`
+			LiveRate   float64 `json:"liveRate"`
+			Quote      *Quote  `json:"quote"`
+		}
+		json.Unmarshal(data, &body)
+		return &SlippageError{Err: apiErr, QuotedRate: body.QuotedRate, LiveRate: body.LiveRate, Quote: body.Quote}
+	}
+	return err
+}
+
+// maxQuotes bounds how many quotes QuoteAndApply requests when quotes keep
+// expiring or being requoted.
+const maxQuotes = 3
+
+// ErrQuoteDeclined is returned by QuoteAndApply when confirm declines a quote.
+var ErrQuoteDeclined = errors.New("quote declined")
+
+// QuoteAndApply previews req and applies the quote, returning the quote that
+// was applied. confirm, when not nil, sees every quote before it is applied
+// and may decline it. A quote that expires before it is applied is replaced
+// by a fresh one. When the rate moves beyond the allowed slippage and the
+// server offers a requote, the requote is applied only if confirm accepts
+// it; without confirm the *SlippageError is returned.
+func (c *Client) QuoteAndApply(ctx context.Context, req PreviewRequest, confirm func(Quote) bool) (Quote, error) {
+	quote, err := c.PreviewExchange(ctx, req)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if confirm != nil && !confirm(quote) {
+			return quote, ErrQuoteDeclined
+		}
+
+		err = c.ApplyExchange(ctx, quote.Token)
+		if err == nil || attempt == maxQuotes {
+			return quote, err
+		}
+
+		var slippageErr *SlippageError
+		switch {
+		case errors.As(err, &slippageErr) && slippageErr.Quote != nil && confirm != nil:
+			quote = *slippageErr.Quote
+		case IsCode(err, CodeQuoteExpired):
+			quote, err = c.PreviewExchange(ctx, req)
+			if err != nil {
+				return Quote{}, err
+			}
+		default:
+			return quote, err
+		}
+	}
+}
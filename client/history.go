@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+type PortfolioPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type PortfolioHistory struct {
+	Quote       string           `json:"quote"`
+	Granularity string           `json:"granularity"`
+	Points      []PortfolioPoint `json:"points"`
+}
+
+// HistoryRequest selects a portfolio history. Zero fields take the server
+// defaults: USD, daily points over the last 30 days.
+type HistoryRequest struct {
+	Quote       string
+	Granularity string
+	From        time.Time
+	To          time.Time
+}
+
+type AssetPnL struct {
+	CryptoName      string  `json:"crypto_name"`
+	Balance         float64 `json:"balance"`
+	CostBasis       float64 `json:"cost_basis"`
+	AverageCost     float64 `json:"average_cost"`
+	MarketValue     float64 `json:"market_value"`
+	RealizedPnL     float64 `json:"realized_pnl"`
+	UnrealizedPnL   float64 `json:"unrealized_pnl"`
+	UncoveredAmount float64 `json:"uncovered_amount"`
+}
+
+type PortfolioPnL struct {
+	Method        string     `json:"method"`
+	Quote         string     `json:"quote"`
+	CostBasis     float64    `json:"cost_basis"`
+	MarketValue   float64    `json:"market_value"`
+	RealizedPnL   float64    `json:"realized_pnl"`
+	UnrealizedPnL float64    `json:"unrealized_pnl"`
+	Assets        []AssetPnL `json:"assets"`
+}
+
+// PortfolioHistory returns the value of the user's portfolio over time.
+func (c *Client) PortfolioHistory(ctx context.Context, req HistoryRequest) (PortfolioHistory, error) {
+	query := timeRange(req.From, req.To)
+	if req.Quote != "" {
+		query.Set("quote", req.Quote)
+	}
+	if req.Granularity != "" {
+		query.Set("granularity", req.Granularity)
+	}
+
+	var history PortfolioHistory
+	err := c.do(ctx, "GET", "/portfolio/history", query, nil, &history)
+	return history, err
+}
+
+// Statement downloads the account statement for the period as csv or pdf.
+// Zero times take the server default of the last month.
+func (c *Client) Statement(ctx context.Context, from, to time.Time, format string) ([]byte, error) {
+	query := timeRange(from, to)
+	if format != "" {
+		query.Set("format", format)
+	}
+
+	req, err := c.newRequest(ctx, "GET", "/statements", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, req)
+}
+
+// PnL reports cost basis and profit-and-loss using method, one of fifo, lifo
+// or average. An empty method means fifo.
+func (c *Client) PnL(ctx context.Context, method string) (PortfolioPnL, error) {
+	query := url.Values{}
+	if method != "" {
+		query.Set("method", method)
+	}
+
+	var pnl PortfolioPnL
+	err := c.do(ctx, "GET", "/pnl", query, nil, &pnl)
+	return pnl, err
+}
+
+func timeRange(from, to time.Time) url.Values {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.UTC().Format(time.RFC3339))
+	}
+	return query
+}
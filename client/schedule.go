@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"swap-wallet/model"
+)
+
+// Schedules lists the user's recurring exchanges.
+func (c *Client) Schedules(ctx context.Context) ([]model.Schedule, error) {
+	var schedules []model.Schedule
+	err := c.do(ctx, "GET", "/schedules", nil, nil, &schedules)
+	return schedules, err
+}
+
+func (c *Client) PauseSchedule(ctx context.Context, scheduleID int) error {
+	return c.do(ctx, "POST", fmt.Sprintf("/schedules/%d/pause", scheduleID), nil, nil, nil)
+}
+
+// ResumeSchedule resumes a paused schedule. Schedules above the two-factor
+// threshold need a code set with WithTOTPCode.
+func (c *Client) ResumeSchedule(ctx context.Context, scheduleID int) error {
+	return c.do(ctx, "POST", fmt.Sprintf("/schedules/%d/resume", scheduleID), nil, nil, nil)
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey makes the POST sent with ctx use key instead of a
+// generated one, so it can be retried safely even from another process.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// TOTPCodeHeader carries the two-factor code of actions that need one.
+const TOTPCodeHeader = "X-TOTP-Code"
+
+type totpCodeContext struct{}
+
+// WithTOTPCode makes the requests sent with ctx carry code, a TOTP code or
+// recovery code, for actions that need two-factor authentication, such as
+// ApplyExchange above the two-factor threshold.
+func WithTOTPCode(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, totpCodeContext{}, code)
+}
+
+// request is an API call that can be sent more than once.
+type request struct {
+	method         string
+	path           string
+	query          url.Values
+	body           []byte
+	idempotencyKey string
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (request, error) {
+	req := request{method: method, path: path, query: query}
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return req, fmt.Errorf("failed to encode request body: %v", err)
+		}
+		req.body = encoded
+	}
+
+	if method == http.MethodPost {
+		if key, ok := ctx.Value(idempotencyKeyContext{}).(string); ok && key != "" {
+			req.idempotencyKey = key
+		} else {
+			key, err := newIdempotencyKey()
+			if err != nil {
+				return req, err
+			}
+			req.idempotencyKey = key
+		}
+	}
+	return req, nil
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *Client) httpRequest(ctx context.Context, req request) (*http.Request, error) {
+	endpoint := c.baseURL + req.path
+	if len(req.query) > 0 {
+		endpoint += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, req.idempotencyKey)
+	}
+	if c.userID != 0 {
+		httpReq.Header.Set("userId", strconv.Itoa(c.userID))
+	}
+	if code, ok := ctx.Value(totpCodeContext{}).(string); ok && code != "" {
+		httpReq.Header.Set(TOTPCodeHeader, code)
+	}
+	return httpReq, nil
+}
+
+// send performs req, retrying transient failures, and returns the response
+// body. A non-2xx response is returned as an *Error together with its body.
+func (c *Client) send(ctx context.Context, req request) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		data, retryAfter, err := c.attempt(ctx, req)
+		if err == nil || attempt >= c.retry.MaxAttempts || !c.retryable(req, err) {
+			return data, err
+		}
+
+		delay := c.retry.BaseDelay << (attempt - 1)
+		if delay > c.retry.MaxDelay {
+			delay = c.retry.MaxDelay
+		}
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request) ([]byte, time.Duration, error) {
+	httpReq, err := c.httpRequest(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return data, retryAfter, responseError(resp, data)
+	}
+	return data, 0, nil
+}
+
+// retryable reports whether err is transient and req safe to repeat: GETs
+// always are, and POSTs are thanks to their idempotency key.
+func (c *Client) retryable(req request, err error) bool {
+	if req.method != http.MethodGet && req.idempotencyKey == "" {
+		return false
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return apiErr.Code == CodeRequestInProgress
+}
+
+// do sends a JSON request and decodes the JSON response into out, which may
+// be nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %v", method, path, err)
+	}
+	return nil
+}
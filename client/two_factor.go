@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+}
+
+// TwoFactorEnrolment is a new TOTP secret. URI is usually shown as a QR code
+// for an authenticator app to scan.
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type recoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+func (c *Client) TwoFactor(ctx context.Context) (TwoFactorStatus, error) {
+	var status TwoFactorStatus
+	err := c.do(ctx, "GET", "/2fa", nil, nil, &status)
+	return status, err
+}
+
+// EnrolTwoFactor starts enrolment with a new secret. Two-factor
+// authentication is enabled once ConfirmTwoFactor accepts a code of it.
+func (c *Client) EnrolTwoFactor(ctx context.Context) (TwoFactorEnrolment, error) {
+	var enrolment TwoFactorEnrolment
+	err := c.do(ctx, "POST", "/2fa/enrol", nil, nil, &enrolment)
+	return enrolment, err
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a code of the
+// enrolled secret and returns the user's recovery codes, which are only
+// shown once.
+func (c *Client) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	var codes recoveryCodes
+	err := c.do(ctx, "POST", "/2fa/confirm", nil, map[string]string{"code": code}, &codes)
+	return codes.Codes, err
+}
+
+// ResetRecoveryCodes replaces the user's recovery codes. It needs a code set
+// with WithTOTPCode.
+func (c *Client) ResetRecoveryCodes(ctx context.Context) ([]string, error) {
+	var codes recoveryCodes
+	err := c.do(ctx, "POST", "/2fa/recovery-codes", nil, nil, &codes)
+	return codes.Codes, err
+}
+
+// DisableTwoFactor turns two-factor authentication off. It needs a code set
+// with WithTOTPCode.
+func (c *Client) DisableTwoFactor(ctx context.Context) error {
+	return c.do(ctx, "POST", "/2fa/disable", nil, nil, nil)
+}
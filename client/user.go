@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"swap-wallet/model"
+)
+
+// Registration is a new user's username and optional profile.
+type Registration struct {
+	Username      string `json:"username"`
+	DisplayName   string `json:"displayName,omitempty"`
+	Email         string `json:"email,omitempty"`
+	QuoteCurrency string `json:"quoteCurrency,omitempty"`
+	Locale        string `json:"locale,omitempty"`
+}
+
+// ProfileUpdate changes the profile fields that are set. An empty Email
+// removes the email.
+type ProfileUpdate struct {
+	DisplayName   *string `json:"displayName,omitempty"`
+	Email         *string `json:"email,omitempty"`
+	QuoteCurrency *string `json:"quoteCurrency,omitempty"`
+	Locale        *string `json:"locale,omitempty"`
+}
+
+// Register creates a user. It needs no user id, so any client can call it;
+// act as the new user with ForUser.
+func (c *Client) Register(ctx context.Context, registration Registration) (model.User, error) {
+	var user model.User
+	err := c.do(ctx, "POST", "/users", nil, registration, &user)
+	return user, err
+}
+
+// User returns a user's account and profile. Users can get their own;
+// staff anyone's.
+func (c *Client) User(ctx context.Context, userID int) (model.User, error) {
+	var user model.User
+	err := c.do(ctx, "GET", fmt.Sprintf("/users/%d", userID), nil, nil, &user)
+	return user, err
+}
+
+// LookupUser finds a user by exact username, or by email when username is
+// empty. The calling user must be staff.
+func (c *Client) LookupUser(ctx context.Context, username, email string) (model.User, error) {
+	query := url.Values{}
+	if username != "" {
+		query.Set("username", username)
+	} else {
+		query.Set("email", email)
+	}
+
+	var user model.User
+	err := c.do(ctx, "GET", "/users/lookup", query, nil, &user)
+	return user, err
+}
+
+func (c *Client) UpdateProfile(ctx context.Context, userID int, update ProfileUpdate) (model.User, error) {
+	var user model.User
+	err := c.do(ctx, "PATCH", fmt.Sprintf("/users/%d", userID), nil, update, &user)
+	return user, err
+}
+
+// DeleteUser deletes a user's account, which must not hold any balance.
+func (c *Client) DeleteUser(ctx context.Context, userID int) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/users/%d", userID), nil, nil, nil)
+}
+
+type userStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// SetUserStatus changes a user's account status to one of model.UserActive,
+// model.UserFrozen, model.UserWithdrawOnly or model.UserClosed. The calling
+// user must be an admin other than the user being changed.
+func (c *Client) SetUserStatus(ctx context.Context, userID int, status, reason string) (model.UserStatusChange, error) {
+	var change model.UserStatusChange
+	err := c.do(ctx, "POST", fmt.Sprintf("/admin/users/%d/status", userID), nil, userStatusRequest{Status: status, Reason: reason}, &change)
+	return change, err
+}
+
+// UserStatusHistory lists a user's status changes, newest first.
+func (c *Client) UserStatusHistory(ctx context.Context, userID int) ([]model.UserStatusChange, error) {
+	var history []model.UserStatusChange
+	err := c.do(ctx, "GET", fmt.Sprintf("/admin/users/%d/status-history", userID), nil, nil, &history)
+	return history, err
+}
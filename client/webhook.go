@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"swap-wallet/model"
+)
+
+// WebhookEndpoints lists the user's webhook endpoints. Secrets are not
+// included.
+func (c *Client) WebhookEndpoints(ctx context.Context) ([]model.WebhookEndpoint, error) {
+	var endpoints []model.WebhookEndpoint
+	err := c.do(ctx, "GET", "/webhooks", nil, nil, &endpoints)
+	return endpoints, err
+}
+
+// WebhookDeliveries lists recent deliveries to one of the user's endpoints.
+func (c *Client) WebhookDeliveries(ctx context.Context, endpointID int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := c.do(ctx, "GET", fmt.Sprintf("/webhooks/%d/deliveries", endpointID), nil, nil, &deliveries)
+	return deliveries, err
+}
+
+// Redeliver queues a delivery to be sent again.
+func (c *Client) Redeliver(ctx context.Context, endpointID, deliveryID int) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := c.do(ctx, "POST", fmt.Sprintf("/webhooks/%d/deliveries/%d/redeliver", endpointID, deliveryID), nil, nil, &delivery)
+	return delivery, err
+}
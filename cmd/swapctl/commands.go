@@ -0,0 +1,786 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"swap-wallet/client"
+	"swap-wallet/model"
+	"time"
+)
+
+// newFlags returns the flag set of the named command with a usage line.
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		cmd, _ := findCommand(name)
+		fmt.Fprintf(flags.Output(), "Usage: swapctl %s %s\n\nswapctl %s: %s\n", cmd.name, cmd.args, cmd.name, cmd.summary)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses args and checks that exactly n positional arguments
+// remain.
+func parseFlags(flags *flag.FlagSet, args []string, n int) []string {
+	flags.Parse(args)
+	if flags.NArg() != n {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return flags.Args()
+}
+
+func parseID(value, name string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return id, nil
+}
+
+// timeFlag is an optional time flag given as RFC 3339 or YYYY-MM-DD.
+type timeFlag struct {
+	time.Time
+}
+
+func (t *timeFlag) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(value string) error {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.ParseInLocation("2006-01-02", value, time.Local)
+	}
+	if err != nil {
+		return fmt.Errorf("expected RFC 3339 time or YYYY-MM-DD date")
+	}
+	t.Time = parsed
+	return nil
+}
+
+func runLogin(a *app, args []string) error {
+	flags := newFlags("login")
+	url := flags.String("url", a.profile.URL, "API base URL")
+	userID := flags.Int("user", a.profile.UserID, "user id")
+	parseFlags(flags, args, 0)
+	if *userID <= 0 {
+		return fmt.Errorf("-user is required")
+	}
+
+	p := profile{URL: *url, UserID: *userID}
+	_, err := client.New(p.URL, client.WithUserID(p.UserID)).Balances(a.ctx, "")
+	if client.IsCode(err, client.CodeInvalidUser) {
+		return fmt.Errorf("user %d is not known to %s", p.UserID, p.URL)
+	}
+	if err != nil {
+		return err
+	}
+
+	path, err := saveProfile(p)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Logged in to %s as user %d (saved to %s)\n", p.URL, p.UserID, path)
+	return nil
+}
+
+func runLogout(a *app, args []string) error {
+	parseFlags(newFlags("logout"), args, 0)
+	return removeProfile()
+}
+
+func runBalances(a *app, args []string) error {
+	flags := newFlags("balances")
+	quote := flags.String("quote", "", "currency to value balances in (default: your preferred quote currency)")
+	parseFlags(flags, args, 0)
+
+	portfolio, err := a.client.Balances(a.ctx, *quote)
+	if err != nil {
+		return err
+	}
+	return a.printer.print(portfolio, func(w io.Writer) {
+		row(w, "CRYPTO", "BALANCE", "PRICE", "VALUE", "PRICED AT")
+		for _, balance := range portfolio.Balances {
+			row(w, balance.CryptoName, balance.CryptoBalance, balance.Price, balance.QuoteBalance, balance.PriceTimestamp)
+		}
+		row(w, "TOTAL", "", "", fmt.Sprintf("%s %s", formatColumn(portfolio.Total), portfolio.Quote), "")
+	})
+}
+
+func runBalance(a *app, args []string) error {
+	flags := newFlags("balance")
+	quote := flags.String("quote", "", "currency to value the balance in (default: your preferred quote currency)")
+	crypto := parseFlags(flags, args, 1)[0]
+
+	balance, err := a.client.Balance(a.ctx, strings.ToUpper(crypto), *quote)
+	if err != nil {
+		return err
+	}
+	return a.printer.print(balance, func(w io.Writer) {
+		row(w, "CRYPTO", "BALANCE", "PRICE", "VALUE", "PRICED AT")
+		row(w, balance.Crypto, balance.CryptoBalance, balance.Price, fmt.Sprintf("%s %s", formatColumn(balance.QuoteBalance), balance.Quote), balance.PriceTimestamp)
+	})
+}
+
+// previewFlags registers the flags shared by preview and exchange.
+func previewFlags(flags *flag.FlagSet) *client.PreviewRequest {
+	req := &client.PreviewRequest{}
+	flags.StringVar(&req.Source, "source", "", "symbol to spend")
+	flags.StringVar(&req.Target, "target", "", "symbol to receive")
+	flags.Float64Var(&req.SourceAmount, "source-amount", 0, "exact amount to spend")
+	flags.Float64Var(&req.TargetAmount, "target-amount", 0, "exact amount to receive")
+	flags.Float64Var(&req.MaxSlippage, "max-slippage", 0, "fraction of adverse rate movement accepted when applying")
+	return req
+}
+
+// checkPreview upper-cases the symbols of req and checks they are given.
+func checkPreview(req client.PreviewRequest) (client.PreviewRequest, error) {
+	req.Source = strings.ToUpper(req.Source)
+	req.Target = strings.ToUpper(req.Target)
+	if req.Source == "" || req.Target == "" {
+		return req, fmt.Errorf("-source and -target are required")
+	}
+	return req, nil
+}
+
+func (a *app) printQuote(quote client.Quote) error {
+	return a.printer.print(quote, func(w io.Writer) {
+		row(w, "LEG", "FROM", "TO", "SPEND", "RECEIVE", "RATE", "FEE")
+		for i, leg := range quote.Legs {
+			row(w, i+1, leg.SourceCrypto, leg.TargetCrypto, leg.SourceAmount, leg.TargetAmount, leg.Rate, leg.Fee)
+		}
+		fmt.Fprintf(w, "\nSpend %s, receive %s at %s (fee %s), fixed %s side.\n",
+			formatColumn(quote.SourceAmount), formatColumn(quote.TargetAmount), formatColumn(quote.Rate), formatColumn(quote.Fee), quote.FixedSide)
+		fmt.Fprintf(w, "Expires %s. Token:\n%s\n", formatColumn(quote.ExpiresAt), quote.Token)
+	})
+}
+
+func runPreview(a *app, args []string) error {
+	flags := newFlags("preview")
+	req := previewFlags(flags)
+	parseFlags(flags, args, 0)
+
+	checked, err := checkPreview(*req)
+	if err != nil {
+		return err
+	}
+	quote, err := a.client.PreviewExchange(a.ctx, checked)
+	if err != nil {
+		return err
+	}
+	return a.printQuote(quote)
+}
+
+func (a *app) apply(token string) error {
+	err := a.client.ApplyExchange(a.ctx, token)
+	var slippageErr *client.SlippageError
+	if errors.As(err, &slippageErr) && slippageErr.Quote != nil {
+		fmt.Fprintf(os.Stderr, "Rate moved from %s to %s. New quote:\n", formatColumn(slippageErr.QuotedRate), formatColumn(slippageErr.LiveRate))
+		a.printQuote(*slippageErr.Quote)
+	}
+	if err != nil {
+		return err
+	}
+	return a.printFinalized()
+}
+
+func (a *app) printFinalized() error {
+	return a.printer.print(client.Message{Message: "Conversion finalized successfully"}, func(w io.Writer) {
+		fmt.Fprintln(w, "Conversion finalized successfully")
+	})
+}
+
+func runApply(a *app, args []string) error {
+	flags := newFlags("apply")
+	code := flags.String("code", "", "two-factor code, needed above the two-factor threshold")
+	token := parseFlags(flags, args, 1)[0]
+	a.ctx = client.WithTOTPCode(a.ctx, *code)
+	return a.apply(token)
+}
+
+func runExchange(a *app, args []string) error {
+	flags := newFlags("exchange")
+	req := previewFlags(flags)
+	yes := flags.Bool("yes", false, "apply without asking for confirmation")
+	code := flags.String("code", "", "two-factor code, needed above the two-factor threshold")
+	parseFlags(flags, args, 0)
+	a.ctx = client.WithTOTPCode(a.ctx, *code)
+
+	checked, err := checkPreview(*req)
+	if err != nil {
+		return err
+	}
+
+	// Without -yes every quote, including a requote offered after the rate
+	// moved, is shown and needs confirmation.
+	var confirm func(client.Quote) bool
+	if !*yes {
+		stdin := bufio.NewReader(os.Stdin)
+		confirm = func(quote client.Quote) bool {
+			if err := a.printQuote(quote); err != nil {
+				return false
+			}
+			fmt.Fprint(os.Stderr, "Apply this quote? [y/N] ")
+			answer, _ := stdin.ReadString('\n')
+			return strings.ToLower(strings.TrimSpace(answer)) == "y"
+		}
+	}
+
+	_, err = a.client.QuoteAndApply(a.ctx, checked, confirm)
+	if errors.Is(err, client.ErrQuoteDeclined) {
+		return fmt.Errorf("exchange cancelled")
+	}
+	if err != nil {
+		return err
+	}
+	return a.printFinalized()
+}
+
+func runHistory(a *app, args []string) error {
+	flags := newFlags("history")
+	var from, to timeFlag
+	req := client.HistoryRequest{}
+	flags.StringVar(&req.Quote, "quote", "", "currency to value the portfolio in (default USD)")
+	flags.StringVar(&req.Granularity, "granularity", "", "hourly or daily (default daily)")
+	flags.Var(&from, "from", "start of the range, RFC 3339 or YYYY-MM-DD")
+	flags.Var(&to, "to", "end of the range, RFC 3339 or YYYY-MM-DD")
+	parseFlags(flags, args, 0)
+	req.From, req.To = from.Time, to.Time
+
+	history, err := a.client.PortfolioHistory(a.ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.printer.print(history, func(w io.Writer) {
+		row(w, "TIME", "VALUE ("+history.Quote+")")
+		for _, point := range history.Points {
+			row(w, point.Time, point.Value)
+		}
+	})
+}
+
+func runStatement(a *app, args []string) error {
+	flags := newFlags("statement")
+	var from, to timeFlag
+	format := flags.String("format", "csv", "csv or pdf")
+	out := flags.String("out", "", "file to write (default: standard output for csv, statement.pdf for pdf)")
+	flags.Var(&from, "from", "start of the period, RFC 3339 or YYYY-MM-DD")
+	flags.Var(&to, "to", "end of the period, RFC 3339 or YYYY-MM-DD")
+	parseFlags(flags, args, 0)
+
+	statement, err := a.client.Statement(a.ctx, from.Time, to.Time, *format)
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" && *format == "pdf" {
+		path = "statement.pdf"
+	}
+	if path == "" {
+		_, err = os.Stdout.Write(statement)
+		return err
+	}
+	if err := os.WriteFile(path, statement, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
+
+func runPnL(a *app, args []string) error {
+	flags := newFlags("pnl")
+	method := flags.String("method", "", "fifo, lifo or average (default fifo)")
+	parseFlags(flags, args, 0)
+
+	pnl, err := a.client.PnL(a.ctx, *method)
+	if err != nil {
+		return err
+	}
+	return a.printer.print(pnl, func(w io.Writer) {
+		row(w, "CRYPTO", "BALANCE", "COST BASIS", "MARKET VALUE", "REALIZED", "UNREALIZED", "UNCOVERED")
+		for _, asset := range pnl.Assets {
+			row(w, asset.CryptoName, asset.Balance, asset.CostBasis, asset.MarketValue, asset.RealizedPnL, asset.UnrealizedPnL, asset.UncoveredAmount)
+		}
+		row(w, "TOTAL", "", pnl.CostBasis, pnl.MarketValue, pnl.RealizedPnL, pnl.UnrealizedPnL, "")
+	})
+}
+
+func runSchedules(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		parseFlags(newFlags("schedules"), args[1:], 0)
+		schedules, err := a.client.Schedules(a.ctx)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(schedules, func(w io.Writer) {
+			row(w, "ID", "FROM", "TO", "AMOUNT", "RECURRENCE", "STATUS", "NEXT RUN", "LAST RESULT")
+			for _, s := range schedules {
+				row(w, s.ID, s.SourceCrypto, s.TargetCrypto, s.SourceAmount, s.Recurrence, s.Status, s.NextRunAt, s.LastResult)
+			}
+		})
+	case "pause", "resume":
+		flags := newFlags("schedules")
+		code := flags.String("code", "", "two-factor code, needed to resume above the two-factor threshold")
+		rest := parseFlags(flags, args[1:], 1)
+		a.ctx = client.WithTOTPCode(a.ctx, *code)
+		id, err := parseID(rest[0], "schedule id")
+		if err != nil {
+			return err
+		}
+		if args[0] == "pause" {
+			err = a.client.PauseSchedule(a.ctx, id)
+		} else {
+			err = a.client.ResumeSchedule(a.ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Schedule %d %sd", id, args[0])
+		return a.printer.print(client.Message{Message: message}, func(w io.Writer) {
+			fmt.Fprintln(w, message)
+		})
+	default:
+		return fmt.Errorf("unknown schedules action %q: use list, pause or resume", args[0])
+	}
+}
+
+func runWebhooks(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		parseFlags(newFlags("webhooks"), args[1:], 0)
+		endpoints, err := a.client.WebhookEndpoints(a.ctx)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(endpoints, func(w io.Writer) {
+			row(w, "ID", "URL", "EVENTS", "ACTIVE", "CREATED")
+			for _, e := range endpoints {
+				row(w, e.ID, e.URL, strings.Join(e.EventTypes, ","), e.IsActive, e.CreatedAt)
+			}
+		})
+	case "deliveries":
+		rest := parseFlags(newFlags("webhooks"), args[1:], 1)
+		endpointID, err := parseID(rest[0], "webhook id")
+		if err != nil {
+			return err
+		}
+		deliveries, err := a.client.WebhookDeliveries(a.ctx, endpointID)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(deliveries, func(w io.Writer) {
+			row(w, "ID", "EVENT", "STATUS", "ATTEMPTS", "LAST CODE", "NEXT ATTEMPT", "LAST ERROR")
+			for _, d := range deliveries {
+				row(w, d.ID, d.EventType, d.Status, d.Attempts, d.LastResponseCode, d.NextAttemptAt, d.LastError)
+			}
+		})
+	case "redeliver":
+		rest := parseFlags(newFlags("webhooks"), args[1:], 2)
+		endpointID, err := parseID(rest[0], "webhook id")
+		if err != nil {
+			return err
+		}
+		deliveryID, err := parseID(rest[1], "delivery id")
+		if err != nil {
+			return err
+		}
+		delivery, err := a.client.Redeliver(a.ctx, endpointID, deliveryID)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(delivery, func(w io.Writer) {
+			fmt.Fprintf(w, "Delivery %d of %s queued for %s\n", delivery.ID, delivery.EventType, formatColumn(delivery.NextAttemptAt))
+		})
+	default:
+		return fmt.Errorf("unknown webhooks action %q: use list, deliveries or redeliver", args[0])
+	}
+}
+
+func (a *app) printAdjustment(adjustment model.BalanceAdjustment) error {
+	return a.printer.print(adjustment, func(w io.Writer) {
+		fmt.Fprintf(w, "Adjustment %d of %s %s for user %d is %s\n",
+			adjustment.ID, formatColumn(adjustment.Amount), adjustment.CryptoSymbol, adjustment.UserID, adjustment.Status)
+	})
+}
+
+func runAdjustments(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		flags := newFlags("adjustments")
+		status := flags.String("status", "", "only list pending, applied or rejected adjustments")
+		userID := flags.Int("for", 0, "only list adjustments of this user id")
+		limit := flags.Int("limit", 0, "maximum number of adjustments")
+		parseFlags(flags, args[1:], 0)
+		adjustments, err := a.client.Adjustments(a.ctx, client.AdjustmentFilter{Status: *status, UserID: *userID, Limit: *limit})
+		if err != nil {
+			return err
+		}
+		return a.printer.print(adjustments, func(w io.Writer) {
+			row(w, "ID", "USER", "CRYPTO", "AMOUNT", "REASON", "STATUS", "REQUESTED BY", "DECIDED BY", "CREATED")
+			for _, adj := range adjustments {
+				row(w, adj.ID, adj.UserID, adj.CryptoSymbol, adj.Amount, adj.ReasonCode, adj.Status, adj.RequestedBy, adj.DecidedBy, adj.CreatedAt)
+			}
+		})
+	case "create":
+		flags := newFlags("adjustments")
+		var req client.AdjustmentRequest
+		flags.IntVar(&req.UserID, "for", 0, "id of the user whose balance is adjusted")
+		flags.StringVar(&req.Crypto, "crypto", "", "symbol of the cryptocurrency")
+		flags.Float64Var(&req.Amount, "amount", 0, "amount to credit, or to debit when negative")
+		flags.StringVar(&req.ReasonCode, "reason", "", "reason code: "+strings.Join(model.AdjustmentReasons, ", "))
+		flags.StringVar(&req.Justification, "justification", "", "why the adjustment is needed")
+		code := flags.String("code", "", "two-factor code, needed for debits")
+		parseFlags(flags, args[1:], 0)
+		a.ctx = client.WithTOTPCode(a.ctx, *code)
+		if req.UserID <= 0 || req.Crypto == "" || req.Amount == 0 || req.ReasonCode == "" || req.Justification == "" {
+			return errors.New("-for, -crypto, -amount, -reason and -justification are required")
+		}
+		adjustment, err := a.client.Adjust(a.ctx, req)
+		if err != nil {
+			return err
+		}
+		return a.printAdjustment(adjustment)
+	case "approve", "reject":
+		flags := newFlags("adjustments")
+		note := flags.String("note", "", "comment recorded with the decision")
+		code := flags.String("code", "", "two-factor code, needed to approve a debit")
+		rest := parseFlags(flags, args[1:], 1)
+		a.ctx = client.WithTOTPCode(a.ctx, *code)
+		id, err := parseID(rest[0], "adjustment id")
+		if err != nil {
+			return err
+		}
+		var adjustment model.BalanceAdjustment
+		if args[0] == "approve" {
+			adjustment, err = a.client.ApproveAdjustment(a.ctx, id, *note)
+		} else {
+			adjustment, err = a.client.RejectAdjustment(a.ctx, id, *note)
+		}
+		if err != nil {
+			return err
+		}
+		return a.printAdjustment(adjustment)
+	default:
+		return fmt.Errorf("unknown adjustments action %q: use list, create, approve or reject", args[0])
+	}
+}
+
+func runRegister(a *app, args []string) error {
+	flags := newFlags("register")
+	var registration client.Registration
+	flags.StringVar(&registration.Username, "username", "", "username, 3 to 32 letters, digits, '.', '_' or '-'")
+	flags.StringVar(&registration.DisplayName, "name", "", "display name")
+	flags.StringVar(&registration.Email, "email", "", "email address")
+	flags.StringVar(&registration.QuoteCurrency, "quote", "", "currency to value balances in by default (default USD)")
+	flags.StringVar(&registration.Locale, "locale", "", "language tag such as en-US (default en-US)")
+	parseFlags(flags, args, 0)
+	if registration.Username == "" {
+		return errors.New("-username is required")
+	}
+
+	user, err := a.client.Register(a.ctx, registration)
+	if err != nil {
+		return err
+	}
+	return a.printer.print(user, func(w io.Writer) {
+		fmt.Fprintf(w, "Registered %s as user %d; run swapctl login -user %d to use it\n", user.Username, user.ID, user.ID)
+	})
+}
+
+func (a *app) printUser(user model.User) error {
+	return a.printer.print(user, func(w io.Writer) {
+		row(w, "ID", "USERNAME", "NAME", "EMAIL", "QUOTE", "LOCALE", "ROLE", "STATUS", "CREATED")
+		row(w, user.ID, user.Username, user.DisplayName, user.Email, user.QuoteCurrency, user.Locale, user.Role, user.Status, user.CreatedAt)
+	})
+}
+
+func runProfile(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+	userID := a.client.UserID()
+
+	switch args[0] {
+	case "show":
+		parseFlags(newFlags("profile"), args[1:], 0)
+		user, err := a.client.User(a.ctx, userID)
+		if err != nil {
+			return err
+		}
+		return a.printUser(user)
+	case "update":
+		flags := newFlags("profile")
+		name := flags.String("name", "", "display name")
+		email := flags.String("email", "", "email address, empty to remove it")
+		quote := flags.String("quote", "", "currency to value balances in by default")
+		locale := flags.String("locale", "", "language tag such as en-US")
+		parseFlags(flags, args[1:], 0)
+
+		// Only flags given on the command line are changed, so -email ""
+		// removes the email while leaving -email out keeps it.
+		var update client.ProfileUpdate
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name":
+				update.DisplayName = name
+			case "email":
+				update.Email = email
+			case "quote":
+				update.QuoteCurrency = quote
+			case "locale":
+				update.Locale = locale
+			}
+		})
+		if update == (client.ProfileUpdate{}) {
+			return errors.New("nothing to update: give -name, -email, -quote or -locale")
+		}
+
+		user, err := a.client.UpdateProfile(a.ctx, userID, update)
+		if err != nil {
+			return err
+		}
+		return a.printUser(user)
+	case "delete":
+		flags := newFlags("profile")
+		yes := flags.Bool("yes", false, "confirm that the account should be deleted")
+		parseFlags(flags, args[1:], 0)
+		if !*yes {
+			return errors.New("deleting an account cannot be undone: pass -yes to confirm")
+		}
+
+		err := a.client.DeleteUser(a.ctx, userID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Deleted user %d; run swapctl logout to forget it\n", userID)
+		return nil
+	default:
+		return fmt.Errorf("unknown profile action %q: use show, update or delete", args[0])
+	}
+}
+
+func (a *app) printRecoveryCodes(codes []string) error {
+	return a.printer.print(codes, func(w io.Writer) {
+		row(w, "RECOVERY CODE")
+		for _, code := range codes {
+			row(w, code)
+		}
+	})
+}
+
+func run2FA(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+
+	switch args[0] {
+	case "status":
+		parseFlags(newFlags("2fa"), args[1:], 0)
+		status, err := a.client.TwoFactor(a.ctx)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(status, func(w io.Writer) {
+			row(w, "ENABLED", "PENDING", "ENABLED AT", "RECOVERY CODES LEFT")
+			row(w, status.Enabled, status.Pending, status.EnabledAt, status.RecoveryCodesLeft)
+		})
+	case "enrol":
+		parseFlags(newFlags("2fa"), args[1:], 0)
+		enrolment, err := a.client.EnrolTwoFactor(a.ctx)
+		if err != nil {
+			return err
+		}
+		err = a.printer.print(enrolment, func(w io.Writer) {
+			row(w, "SECRET", "URI")
+			row(w, enrolment.Secret, enrolment.URI)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Add the secret to an authenticator app, then run swapctl 2fa confirm CODE")
+		return nil
+	case "confirm":
+		code := parseFlags(newFlags("2fa"), args[1:], 1)[0]
+		codes, err := a.client.ConfirmTwoFactor(a.ctx, code)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Two-factor authentication enabled. Keep these recovery codes somewhere safe; they are not shown again.")
+		return a.printRecoveryCodes(codes)
+	case "recovery-codes":
+		flags := newFlags("2fa")
+		code := flags.String("code", "", "current two-factor code")
+		parseFlags(flags, args[1:], 0)
+		codes, err := a.client.ResetRecoveryCodes(client.WithTOTPCode(a.ctx, *code))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Previous recovery codes no longer work. Keep these somewhere safe; they are not shown again.")
+		return a.printRecoveryCodes(codes)
+	case "disable":
+		flags := newFlags("2fa")
+		code := flags.String("code", "", "current two-factor code")
+		parseFlags(flags, args[1:], 0)
+		err := a.client.DisableTwoFactor(client.WithTOTPCode(a.ctx, *code))
+		if err != nil {
+			return err
+		}
+		return a.printer.print(client.Message{Message: "Two-factor authentication disabled"}, func(w io.Writer) {
+			fmt.Fprintln(w, "Two-factor authentication disabled")
+		})
+	default:
+		return fmt.Errorf("unknown 2fa action %q: use status, enrol, confirm, recovery-codes or disable", args[0])
+	}
+}
+
+func runUsers(a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing users action: use show, find, status or history")
+	}
+
+	switch args[0] {
+	case "show":
+		rest := parseFlags(newFlags("users"), args[1:], 1)
+		id, err := parseID(rest[0], "user id")
+		if err != nil {
+			return err
+		}
+		user, err := a.client.User(a.ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.printUser(user)
+	case "find":
+		flags := newFlags("users")
+		username := flags.String("username", "", "exact username")
+		email := flags.String("email", "", "exact email address")
+		parseFlags(flags, args[1:], 0)
+		if (*username == "") == (*email == "") {
+			return errors.New("give exactly one of -username or -email")
+		}
+		user, err := a.client.LookupUser(a.ctx, *username, *email)
+		if err != nil {
+			return err
+		}
+		return a.printUser(user)
+	case "status":
+		flags := newFlags("users")
+		status := flags.String("status", "", "new status: active, frozen, withdraw_only or closed")
+		reason := flags.String("reason", "", "why the status changes")
+		rest := parseFlags(flags, args[1:], 1)
+		id, err := parseID(rest[0], "user id")
+		if err != nil {
+			return err
+		}
+		if *status == "" || *reason == "" {
+			return errors.New("-status and -reason are required")
+		}
+		change, err := a.client.SetUserStatus(a.ctx, id, *status, *reason)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(change, func(w io.Writer) {
+			fmt.Fprintf(w, "User %d changed from %s to %s\n", change.UserID, change.FromStatus, change.ToStatus)
+		})
+	case "history":
+		rest := parseFlags(newFlags("users"), args[1:], 1)
+		id, err := parseID(rest[0], "user id")
+		if err != nil {
+			return err
+		}
+		history, err := a.client.UserStatusHistory(a.ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(history, func(w io.Writer) {
+			row(w, "ID", "TIME", "FROM", "TO", "CHANGED BY", "REASON")
+			for _, c := range history {
+				row(w, c.ID, c.CreatedAt, c.FromStatus, c.ToStatus, c.ChangedBy, c.Reason)
+			}
+		})
+	default:
+		return fmt.Errorf("unknown users action %q: use show, find, status or history", args[0])
+	}
+}
+
+func runAudit(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		flags := newFlags("audit")
+		var from, to timeFlag
+		var q client.AuditQuery
+		var target string
+		flags.StringVar(&q.Action, "action", "", "only list entries with this action, for example exchange.completed")
+		flags.IntVar(&q.ActorID, "actor", 0, "only list entries of this acting user id")
+		flags.StringVar(&target, "target", "", "only list entries about TYPE or TYPE:ID, for example adjustment:12")
+		flags.Var(&from, "from", "start of the range, RFC 3339 or YYYY-MM-DD")
+		flags.Var(&to, "to", "end of the range, RFC 3339 or YYYY-MM-DD")
+		flags.Int64Var(&q.Before, "before", 0, "only list entries older than this entry id")
+		flags.IntVar(&q.Limit, "limit", 0, "maximum number of entries")
+		parseFlags(flags, args[1:], 0)
+		q.From, q.To = from.Time, to.Time
+		q.TargetType, q.TargetID, _ = strings.Cut(target, ":")
+
+		entries, err := a.client.AuditLog(a.ctx, q)
+		if err != nil {
+			return err
+		}
+		return a.printer.print(entries, func(w io.Writer) {
+			row(w, "ID", "TIME", "ACTION", "ACTOR", "IP", "TARGET", "REQUEST ID")
+			for _, e := range entries {
+				target := e.TargetType
+				if e.TargetID != "" {
+					target += ":" + e.TargetID
+				}
+				row(w, e.ID, e.CreatedAt, e.Action, e.ActorID, e.ActorIP, target, e.RequestID)
+			}
+		})
+	case "verify":
+		parseFlags(newFlags("audit"), args[1:], 0)
+		verification, err := a.client.VerifyAuditLog(a.ctx)
+		if err != nil {
+			return err
+		}
+		err = a.printer.print(verification, func(w io.Writer) {
+			if verification.Valid {
+				fmt.Fprintf(w, "Audit log intact: %d entries, last hash %s\n", verification.Entries, verification.LastHash)
+			} else {
+				fmt.Fprintf(w, "Audit log broken at entry %d: %s\n", *verification.FirstInvalidID, verification.Reason)
+			}
+		})
+		if err == nil && !verification.Valid {
+			err = errors.New("audit log verification failed")
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown audit action %q: use list or verify", args[0])
+	}
+}
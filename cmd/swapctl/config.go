@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// profile is what login stores: the API to talk to and the user to act as.
+type profile struct {
+	URL    string `json:"url"`
+	UserID int    `json:"userId"`
+}
+
+func profilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %v", err)
+	}
+	return filepath.Join(dir, "swapctl", "config.json"), nil
+}
+
+// loadProfile reads the saved profile and applies the SWAPCTL_URL and
+// SWAPCTL_USER environment variables on top of it.
+func loadProfile() (profile, error) {
+	p := profile{URL: defaultURL}
+
+	path, err := profilePath()
+	if err != nil {
+		return p, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return p, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &p); err != nil {
+			return p, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+
+	if url := os.Getenv("SWAPCTL_URL"); url != "" {
+		p.URL = url
+	}
+	if user := os.Getenv("SWAPCTL_USER"); user != "" {
+		p.UserID, err = strconv.Atoi(user)
+		if err != nil {
+			return p, fmt.Errorf("invalid SWAPCTL_USER: %s", user)
+		}
+	}
+	return p, nil
+}
+
+func saveProfile(p profile) (string, error) {
+	path, err := profilePath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, nil
+}
+
+func removeProfile() error {
+	path, err := profilePath()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
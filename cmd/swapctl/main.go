@@ -0,0 +1,133 @@
+// Command swapctl exercises the swap-wallet HTTP API from the command line.
+//
+//	swapctl [-url URL] [-user ID] [-o table|json] <command> [flags] [args]
+//
+// Run swapctl without arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"swap-wallet/client"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(app *app, args []string) error
+}
+
+// app is the state shared by every command.
+type app struct {
+	ctx     context.Context
+	profile profile
+	client  *client.Client
+	printer printer
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "login", args: "-user ID [-url URL]", summary: "check the user against the API and save it as the default", run: runLogin},
+		{name: "logout", summary: "forget the saved user", run: runLogout},
+		{name: "register", args: "-username NAME [-name TEXT] [-email E] [-quote CUR] [-locale L]", summary: "create a user and print its id", run: runRegister},
+		{name: "profile", args: "[show | update [-name TEXT] [-email E] [-quote CUR] [-locale L] | delete -yes]", summary: "show, update or delete your own account", run: runProfile},
+		{name: "2fa", args: "[status | enrol | confirm CODE | recovery-codes -code CODE | disable -code CODE]", summary: "set up two-factor authentication for large exchanges", run: run2FA},
+		{name: "balances", args: "[-quote CUR]", summary: "list balances and the portfolio total", run: runBalances},
+		{name: "balance", args: "[-quote CUR] CRYPTO", summary: "show one balance", run: runBalance},
+		{name: "preview", args: "-source SYM -target SYM (-source-amount N | -target-amount N) [-max-slippage F]", summary: "quote an exchange", run: runPreview},
+		{name: "apply", args: "[-code CODE] TOKEN", summary: "apply a quote", run: runApply},
+		{name: "exchange", args: "(same flags as preview) [-yes] [-code CODE]", summary: "quote an exchange and apply it after confirmation", run: runExchange},
+		{name: "history", args: "[-quote CUR] [-granularity hourly|daily] [-from T] [-to T]", summary: "show the portfolio value over time", run: runHistory},
+		{name: "statement", args: "[-format csv|pdf] [-from T] [-to T] [-out FILE]", summary: "download an account statement", run: runStatement},
+		{name: "pnl", args: "[-method fifo|lifo|average]", summary: "show cost basis and profit-and-loss", run: runPnL},
+		{name: "schedules", args: "[list | pause ID | resume [-code CODE] ID]", summary: "list, pause or resume recurring exchanges", run: runSchedules},
+		{name: "webhooks", args: "[list | deliveries ID | redeliver ID DELIVERY_ID]", summary: "inspect webhook endpoints and redeliver events", run: runWebhooks},
+		{name: "adjustments", args: "[list [-status S] [-for USER] | create -for USER -crypto SYM -amount N -reason CODE -justification TEXT [-code C] | approve [-note TEXT] [-code C] ID | reject [-note TEXT] ID]", summary: "admin: adjust user balances with four-eyes approval", run: runAdjustments},
+		{name: "users", args: "[show USER | find (-username U | -email E) | status -status S -reason TEXT USER | history USER]", summary: "staff: look up users and freeze, restrict or close accounts", run: runUsers},
+		{name: "audit", args: "[list [-action A] [-actor USER] [-target TYPE:ID] [-from T] [-to T] [-before ID] | verify]", summary: "compliance: query and verify the audit log", run: runAudit},
+	}
+}
+
+func main() {
+	global := flag.NewFlagSet("swapctl", flag.ExitOnError)
+	url := global.String("url", "", "API base URL (default: saved by login, $SWAPCTL_URL or "+defaultURL+")")
+	userID := global.Int("user", 0, "user id to act as (default: saved by login or $SWAPCTL_USER)")
+	output := global.String("o", outputTable, "output format: table or json")
+	global.Usage = func() { usage(global) }
+	global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
+		usage(global)
+		os.Exit(2)
+	}
+	if *output != outputTable && *output != outputJSON {
+		fail(fmt.Errorf("invalid output format %q: use table or json", *output))
+	}
+
+	cmd, ok := findCommand(global.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "swapctl: unknown command %q\n\n", global.Arg(0))
+		usage(global)
+		os.Exit(2)
+	}
+
+	p, err := loadProfile()
+	if err != nil {
+		fail(err)
+	}
+	if *url != "" {
+		p.URL = *url
+	}
+	if *userID != 0 {
+		p.UserID = *userID
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{
+		ctx:     ctx,
+		profile: p,
+		client:  client.New(p.URL, client.WithUserID(p.UserID)),
+		printer: printer{format: *output, out: os.Stdout},
+	}
+	err = cmd.run(a, global.Args()[1:])
+	if client.IsCode(err, client.CodeInvalidUser) && p.UserID == 0 {
+		err = fmt.Errorf("no user: run swapctl login -user ID, or pass -user")
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: swapctl [-url URL] [-user ID] [-o table|json] <command> [flags] [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(out, "\nGlobal flags:")
+	global.PrintDefaults()
+	fmt.Fprintln(out, "\nRun swapctl <command> -h for the flags of a command.")
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "swapctl: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+type printer struct {
+	format string
+	out    io.Writer
+}
+
+// print writes value as indented JSON in json mode, and otherwise lets table
+// write tab-separated rows that are aligned into columns.
+func (p printer) print(value interface{}, table func(w io.Writer)) error {
+	if p.format == outputJSON {
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func row(w io.Writer, columns ...interface{}) {
+	for i, column := range columns {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, formatColumn(column))
+	}
+	fmt.Fprintln(w)
+}
+
+func formatColumn(column interface{}) string {
+	switch value := column.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		if value.IsZero() {
+			return "-"
+		}
+		return value.Local().Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if value == nil {
+			return "-"
+		}
+		return formatColumn(*value)
+	case *int:
+		if value == nil {
+			return "-"
+		}
+		return strconv.Itoa(*value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
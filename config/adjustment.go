@@ -0,0 +1,24 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	MinAdjustmentJustification = 10
+	MaxAdjustmentJustification = 2000
+	DefaultAdjustmentsListed   = 50
+	MaxAdjustmentsListed       = 500
+)
+
+// LoadAdjustmentApprovalThreshold returns the USD value above which a manual
+// balance adjustment needs a second admin's approval. It defaults to 0, which
+// applies every adjustment as soon as it is requested.
+func LoadAdjustmentApprovalThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("ADJUSTMENT_APPROVAL_THRESHOLD"), 64)
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
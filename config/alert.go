@@ -0,0 +1,8 @@
+package config
+
+const (
+	AlertEvaluationInterval = 30
+	DefaultAlertCooldown    = 3600
+	MinAlertCooldown        = 60
+	NotificationTimeout     = 5
+)
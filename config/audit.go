@@ -0,0 +1,19 @@
+package config
+
+const (
+	DefaultAuditEntries = 100
+	MaxAuditEntries     = 1000
+)
+
+// AuditedSettings returns the settings whose changes are recorded in the
+// audit log. Secrets and connection details are left out.
+func AuditedSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"SLIPPAGE_TOLERANCE":            LoadSlippageTolerance(),
+		"SLIPPAGE_ACTION":               LoadSlippageAction(),
+		"EXCHANGE_FEE_RATE":             LoadExchangeFeeRate(),
+		"ADJUSTMENT_APPROVAL_THRESHOLD": LoadAdjustmentApprovalThreshold(),
+		"TWO_FACTOR_EXCHANGE_THRESHOLD": LoadTwoFactorExchangeThreshold(),
+		"GRPC_PORT":                     LoadGRPCPort(),
+	}
+}
@@ -14,6 +14,11 @@ type Config struct {
 	REDIS_HOST     string
 	REDIS_PORT     string
 	REDIS_PASSWORD string
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SMTPFrom       string
 }
 
 func LoadConfig() Config {
@@ -26,6 +31,11 @@ func LoadConfig() Config {
 		REDIS_HOST:     os.Getenv("REDIS_HOST"),
 		REDIS_PORT:     os.Getenv("REDIS_PORT"),
 		REDIS_PASSWORD: os.Getenv("REDIS_PASSWORD"),
+		SMTPHost:       os.Getenv("SMTP_HOST"),
+		SMTPPort:       os.Getenv("SMTP_PORT"),
+		SMTPUsername:   os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:   os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:       os.Getenv("SMTP_FROM"),
 	}
 }
 
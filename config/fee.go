@@ -0,0 +1,16 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// LoadExchangeFeeRate returns the fraction of every exchange kept as a fee,
+// charged in the target asset. It defaults to no fee.
+func LoadExchangeFeeRate() float64 {
+	feeRate, err := strconv.ParseFloat(os.Getenv("EXCHANGE_FEE_RATE"), 64)
+	if err != nil || feeRate < 0 || feeRate >= 1 {
+		return 0
+	}
+	return feeRate
+}
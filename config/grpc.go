@@ -0,0 +1,14 @@
+package config
+
+import "os"
+
+const DefaultGRPCPort = "9090"
+
+// LoadGRPCPort returns the port the gRPC API listens on, separate from the
+// HTTP API's.
+func LoadGRPCPort() string {
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		return port
+	}
+	return DefaultGRPCPort
+}
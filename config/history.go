@@ -0,0 +1,24 @@
+package config
+
+const (
+	SnapshotInterval = 3600
+	MaxHistoryPoints = 1000
+)
+
+// HistoryQuoteCurrencies are the currencies every available cryptocurrency is
+// priced in when a snapshot is taken. Portfolio history can be valued in any
+// of these or in any cryptocurrency, which is crossed through USD.
+var HistoryQuoteCurrencies = []string{"USD", "EUR"}
+
+// CandleIntervals maps each OHLC resolution to its length in seconds. Every
+// recorded price updates one candle per resolution.
+var CandleIntervals = map[string]int{
+	"1m":  60,
+	"5m":  300,
+	"15m": 900,
+	"1h":  3600,
+	"4h":  14400,
+	"1d":  86400,
+}
+
+const MaxCandles = 1000
@@ -0,0 +1,10 @@
+package config
+
+const (
+	// Responses to requests with an Idempotency-Key are kept for
+	// IdempotencyTTL seconds. A request still running after
+	// IdempotencyLockTTL seconds no longer blocks retries.
+	IdempotencyTTL     = 24 * 60 * 60
+	IdempotencyLockTTL = 60
+	MaxIdempotencyKey  = 255
+)
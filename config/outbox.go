@@ -0,0 +1,10 @@
+package config
+
+const (
+	OutboxRelayInterval = 1
+	OutboxBatchSize     = 100
+	// OutboxStream is the Redis stream domain events are published to. It is
+	// trimmed to roughly OutboxStreamMaxLen entries.
+	OutboxStream       = "swap-wallet:events"
+	OutboxStreamMaxLen = 100000
+)
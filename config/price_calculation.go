@@ -4,3 +4,20 @@ const (
 	CryptoCompareAPI = "https://min-api.cryptocompare.com/data/generateAvg?fsym=%s&tsym=%s&e=coinbase"
 	Timeout          = 3
 )
+
+// MaxRouteHops bounds the number of legs a routed exchange may take.
+const MaxRouteHops = 3
+
+// PriceRecorderBuffer is how many observed prices may wait to be stored
+// before new observations are dropped.
+const PriceRecorderBuffer = 1024
+
+// QuoteTTL is how many seconds an issued quote can be applied for.
+const QuoteTTL = 60
+
+// A live quote stream issues a fresh quote every QuoteStreamInterval seconds
+// and ends after QuoteStreamMaxDuration seconds.
+const (
+	QuoteStreamInterval    = 5
+	QuoteStreamMaxDuration = 600
+)
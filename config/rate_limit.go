@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RateLimit is a token bucket holding up to Burst requests that refills at
+// Rate requests per second. A zero Burst means no limit.
+type RateLimit struct {
+	Burst int
+	Rate  float64
+}
+
+// RouteRateLimit limits a group of routes separately per calling user and per
+// client IP.
+type RouteRateLimit struct {
+	PerUser RateLimit
+	PerIP   RateLimit
+}
+
+// Rate limited route groups. Their limits default to defaultRateLimits and
+// can be changed with RATE_LIMIT_<GROUP>_USER and RATE_LIMIT_<GROUP>_IP set
+// to BURST/RATE, for example 20/1, or to 0 to turn the limit off.
+const (
+	RateLimitPreview     = "preview"
+	RateLimitQuoteStream = "quote_stream"
+	RateLimitStream      = "stream"
+	RateLimitBalances    = "balances"
+	RateLimitExchange    = "exchange"
+	RateLimitRegister    = "register"
+)
+
+var defaultRateLimits = map[string]RouteRateLimit{
+	// Every preview prices the pair and stores a quote in Redis.
+	RateLimitPreview: {
+		PerUser: RateLimit{Burst: 20, Rate: 1},
+		PerIP:   RateLimit{Burst: 60, Rate: 3},
+	},
+	// Every open quote stream prices its pair every few seconds.
+	RateLimitQuoteStream: {
+		PerUser: RateLimit{Burst: 5, Rate: 0.1},
+		PerIP:   RateLimit{Burst: 20, Rate: 0.5},
+	},
+	// Every open stream holds a connection and up to 50 price subscriptions.
+	RateLimitStream: {
+		PerUser: RateLimit{Burst: 5, Rate: 0.1},
+		PerIP:   RateLimit{Burst: 20, Rate: 0.5},
+	},
+	// Every balance request prices each of the user's assets.
+	RateLimitBalances: {
+		PerUser: RateLimit{Burst: 30, Rate: 1},
+		PerIP:   RateLimit{Burst: 90, Rate: 3},
+	},
+	RateLimitExchange: {
+		PerUser: RateLimit{Burst: 10, Rate: 0.5},
+		PerIP:   RateLimit{Burst: 30, Rate: 2},
+	},
+	// Registration has no calling user.
+	RateLimitRegister: {
+		PerIP: RateLimit{Burst: 5, Rate: 1.0 / 60},
+	},
+}
+
+// LoadRateLimits returns the limits of every route group, with any
+// overrides from the environment. Invalid overrides are ignored.
+func LoadRateLimits() map[string]RouteRateLimit {
+	limits := make(map[string]RouteRateLimit, len(defaultRateLimits))
+	for group, limit := range defaultRateLimits {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(group)
+		limit.PerUser = loadRateLimit(prefix+"_USER", limit.PerUser)
+		limit.PerIP = loadRateLimit(prefix+"_IP", limit.PerIP)
+		limits[group] = limit
+	}
+	return limits
+}
+
+func loadRateLimit(name string, fallback RateLimit) RateLimit {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback
+	}
+	if value == "0" {
+		return RateLimit{}
+	}
+
+	burst, rate, ok := strings.Cut(value, "/")
+	if !ok {
+		return fallback
+	}
+	limit := RateLimit{}
+	var err error
+	limit.Burst, err = strconv.Atoi(burst)
+	if err != nil || limit.Burst < 1 {
+		return fallback
+	}
+	limit.Rate, err = strconv.ParseFloat(rate, 64)
+	if err != nil || limit.Rate <= 0 {
+		return fallback
+	}
+	return limit
+}
@@ -0,0 +1,7 @@
+package config
+
+const (
+	SchedulerTickInterval = 30
+	MinScheduleInterval   = 3600
+	MaxScheduleFailures   = 3
+)
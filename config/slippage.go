@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultSlippageTolerance = 0.01
+	SlippageActionReject     = "reject"
+	SlippageActionRequote    = "requote"
+)
+
+// LoadSlippageTolerance returns the fraction by which the live rate may move
+// against the house before a quote is no longer honoured at its quoted rate.
+func LoadSlippageTolerance() float64 {
+	tolerance, err := strconv.ParseFloat(os.Getenv("SLIPPAGE_TOLERANCE"), 64)
+	if err != nil || tolerance < 0 {
+		return DefaultSlippageTolerance
+	}
+	return tolerance
+}
+
+func LoadSlippageAction() string {
+	if os.Getenv("SLIPPAGE_ACTION") == SlippageActionRequote {
+		return SlippageActionRequote
+	}
+	return SlippageActionReject
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	StreamPriceInterval = 5
+	// Connections are pinged every StreamPingInterval seconds and dropped if
+	// nothing is heard for StreamPongWait seconds.
+	StreamPingInterval = 30
+	StreamPongWait     = 60
+	StreamWriteWait    = 10
+	// StreamSendBuffer is how many messages may queue for one connection
+	// before it is considered too slow and disconnected.
+	StreamSendBuffer       = 64
+	MaxStreamSubscriptions = 50
+	// MaxStreamPairs bounds the distinct pairs all connections to an instance
+	// follow together, since each of them is priced every StreamPriceInterval.
+	MaxStreamPairs   = 500
+	StreamEventBlock = 5
+)
+
+// LoadStreamAllowedOrigins returns the browser origins, such as
+// https://app.example.com, that may open streams besides pages served from
+// the API's own host. They are read from STREAM_ALLOWED_ORIGINS, separated by
+// commas; "*" allows any origin.
+func LoadStreamAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("STREAM_ALLOWED_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	TOTPIssuer = "Swap Wallet"
+	TOTPDigits = 6
+	TOTPPeriod = 30
+	// TOTPSkew is the number of periods either side of now whose codes are
+	// still accepted, to allow for clock drift.
+	TOTPSkew = 1
+
+	RecoveryCodeCount = 10
+
+	// After MaxTOTPFailures invalid codes in a row, codes are refused for
+	// TOTPLockout seconds.
+	MaxTOTPFailures = 5
+	TOTPLockout     = 300
+)
+
+// LoadTwoFactorExchangeThreshold returns the USD value above which applying
+// an exchange needs a valid two-factor code. It defaults to 0, which never
+// asks for one.
+func LoadTwoFactorExchangeThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("TWO_FACTOR_EXCHANGE_THRESHOLD"), 64)
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
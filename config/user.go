@@ -0,0 +1,16 @@
+package config
+
+const (
+	MinStatusReason = 10
+	MaxStatusReason = 2000
+
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MaxDisplayName    = 100
+	MaxEmailLength    = 255
+	DefaultLocale     = "en-US"
+)
+
+// ProfileQuoteCurrencies are the fiat currencies users can choose to have
+// their balances valued in. Any listed cryptocurrency can be chosen as well.
+var ProfileQuoteCurrencies = []string{"USD", "EUR", "GBP", "JPY", "CHF"}
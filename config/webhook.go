@@ -0,0 +1,16 @@
+package config
+
+const (
+	WebhookDeliveryInterval = 5
+	WebhookDeliveryBatch    = 100
+	WebhookTimeout          = 10
+	// Claimed deliveries are attempted one after another, so a claim lasts
+	// long enough for a whole batch to time out.
+	WebhookClaimLease = WebhookDeliveryBatch*WebhookTimeout + 60
+	// A failed delivery is retried after WebhookRetryBase seconds, doubling on
+	// every attempt up to WebhookRetryMax, and given up after
+	// MaxWebhookAttempts attempts.
+	WebhookRetryBase   = 30
+	WebhookRetryMax    = 3600
+	MaxWebhookAttempts = 8
+)
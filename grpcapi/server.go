@@ -0,0 +1,305 @@
+// Package grpcapi serves the balance and exchange operations of BalanceService
+// over gRPC, with the same authentication and error semantics as the HTTP
+// handlers. Every error carries the service error code as the reason of an
+// ErrorInfo detail; a missing or unknown user id and invalid arguments map to
+// INVALID_ARGUMENT, slippage to ABORTED with a SlippageDetail, and other
+// failures as listed in errorCodes.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/middleware"
+	"swap-wallet/proto/walletpb"
+	"swap-wallet/service"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserIDMetadataKey carries the caller's user id, like the userId header of
+// the HTTP API.
+const UserIDMetadataKey = "userid"
+
+// RequestIDMetadataKey optionally carries the caller's request id, like the
+// X-Request-ID header of the HTTP API.
+const RequestIDMetadataKey = "x-request-id"
+
+// TOTPCodeMetadataKey carries a two-factor code for ApplyExchange, like the
+// X-TOTP-Code header of the HTTP API.
+const TOTPCodeMetadataKey = "x-totp-code"
+
+type userIDKey struct{}
+
+type Server struct {
+	walletpb.UnimplementedWalletServiceServer
+	balanceService *service.BalanceService
+	rateLimiter    *service.RateLimiter
+}
+
+// NewServer returns a gRPC server with the wallet service registered behind
+// the user id check.
+func NewServer(balanceService *service.BalanceService, rateLimiter *service.RateLimiter) *grpc.Server {
+	server := &Server{balanceService: balanceService, rateLimiter: rateLimiter}
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(server.authenticate))
+	walletpb.RegisterWalletServiceServer(grpcServer, server)
+	return grpcServer
+}
+
+func (s *Server) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if requestIDs := md.Get(RequestIDMetadataKey); len(requestIDs) > 0 {
+		ctx = middleware.WithRequestID(ctx, requestIDs[0])
+	} else {
+		ctx = middleware.WithRequestID(ctx, "")
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		ctx = middleware.WithClientIP(ctx, p.Addr.String())
+	}
+
+	values := md.Get(UserIDMetadataKey)
+	if len(values) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid User ID")
+	}
+
+	userID, err := s.balanceService.Authenticate(ctx, values[0])
+	if service.AsError(err).Code == service.CodeAccountClosed {
+		return nil, statusError(err)
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid User ID")
+	}
+
+	return handler(context.WithValue(ctx, userIDKey{}, userID), req)
+}
+
+func userID(ctx context.Context) int {
+	return ctx.Value(userIDKey{}).(int)
+}
+
+// rateLimit applies the limits of a route group, sharing the buckets of the
+// HTTP API. Rejections carry a RetryInfo detail.
+func (s *Server) rateLimit(ctx context.Context, group string) error {
+	result, err := s.rateLimiter.Allow(ctx, group, strconv.Itoa(userID(ctx)), middleware.ClientIPFrom(ctx))
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(statusError(err))
+	detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(time.Duration(result.RetryAfterSeconds()) * time.Second),
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// quoteCurrency returns quote, or the user's preferred quote currency when
+// quote is empty.
+func (s *Server) quoteCurrency(ctx context.Context, quote string) (string, error) {
+	if quote == "" {
+		return s.balanceService.PreferredQuoteCurrency(userID(ctx))
+	}
+	return strings.ToUpper(quote), nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, req *walletpb.GetBalanceRequest) (*walletpb.Balance, error) {
+	if err := s.rateLimit(ctx, config.RateLimitBalances); err != nil {
+		return nil, err
+	}
+
+	quote, err := s.quoteCurrency(ctx, req.Quote)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	balance, err := s.balanceService.GetUserBalanceInQuote(userID(ctx), req.Crypto, quote)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return balanceMessage(balance), nil
+}
+
+func (s *Server) ListBalances(ctx context.Context, req *walletpb.ListBalancesRequest) (*walletpb.Portfolio, error) {
+	if err := s.rateLimit(ctx, config.RateLimitBalances); err != nil {
+		return nil, err
+	}
+
+	quote, err := s.quoteCurrency(ctx, req.Quote)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	portfolio, err := s.balanceService.GetUserBalancesInQuote(userID(ctx), quote)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	response := &walletpb.Portfolio{
+		Quote: portfolio.Quote,
+		Total: portfolio.Total,
+	}
+	for _, balance := range portfolio.Balances {
+		response.Balances = append(response.Balances, balanceMessage(balance))
+	}
+	return response, nil
+}
+
+func (s *Server) PreviewExchange(ctx context.Context, req *walletpb.PreviewExchangeRequest) (*walletpb.Quote, error) {
+	if req.Source == "" || req.Target == "" || req.Amount == nil {
+		return nil, status.Error(codes.InvalidArgument, "source, target and exactly one of source_amount or target_amount are required")
+	}
+
+	fixedSide, amount := service.FixedSideSource, req.GetSourceAmount()
+	if _, ok := req.Amount.(*walletpb.PreviewExchangeRequest_TargetAmount); ok {
+		fixedSide, amount = service.FixedSideTarget, req.GetTargetAmount()
+	}
+	if amount <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %sAmount", fixedSide)
+	}
+	if req.MaxSlippage < 0 || req.MaxSlippage > 1 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid maxSlippage")
+	}
+	if err := s.rateLimit(ctx, config.RateLimitPreview); err != nil {
+		return nil, err
+	}
+
+	quote, err := s.balanceService.GetExchangePreview(ctx, userID(ctx), req.Source, req.Target, amount, fixedSide, req.MaxSlippage)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return quoteMessage(quote), nil
+}
+
+func (s *Server) ApplyExchange(ctx context.Context, req *walletpb.ApplyExchangeRequest) (*walletpb.ApplyExchangeResponse, error) {
+	if err := s.rateLimit(ctx, config.RateLimitExchange); err != nil {
+		return nil, err
+	}
+
+	var totpCode string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(TOTPCodeMetadataKey); len(values) > 0 {
+		totpCode = values[0]
+	}
+
+	err := s.balanceService.FinalizeExchange(ctx, userID(ctx), req.Token, totpCode)
+
+	var slippageErr *service.SlippageError
+	if errors.As(err, &slippageErr) {
+		detail := &walletpb.SlippageDetail{
+			QuotedRate: slippageErr.QuotedRate,
+			LiveRate:   slippageErr.LiveRate,
+		}
+		if slippageErr.Quote != nil {
+			detail.Quote = quoteMessage(*slippageErr.Quote)
+		}
+		st, detailErr := status.New(codes.Aborted, slippageErr.Error()).WithDetails(detail)
+		if detailErr != nil {
+			return nil, status.Error(codes.Aborted, slippageErr.Error())
+		}
+		return nil, st.Err()
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletpb.ApplyExchangeResponse{Message: "Conversion finalized successfully"}, nil
+}
+
+func balanceMessage(balance service.CryptoBalanceType) *walletpb.Balance {
+	return &walletpb.Balance{
+		Crypto:         balance.CryptoName,
+		CryptoBalance:  balance.CryptoBalance,
+		Quote:          balance.Quote,
+		QuoteBalance:   balance.QuoteBalance,
+		Price:          balance.Price,
+		PriceTimestamp: timestamppb.New(balance.PriceTimestamp),
+	}
+}
+
+func quoteMessage(quote service.Quote) *walletpb.Quote {
+	message := &walletpb.Quote{
+		SourceCrypto: quote.SourceCrypto,
+		TargetCrypto: quote.TargetCrypto,
+		SourceAmount: quote.SourceAmount,
+		TargetAmount: quote.TargetAmount,
+		Fee:          quote.Fee,
+		Rate:         quote.Rate,
+		FixedSide:    quote.FixedSide,
+		MaxSlippage:  quote.MaxSlippage,
+		Token:        quote.Token,
+		ExpiresAt:    timestamppb.New(quote.ExpiresAt),
+	}
+	for _, leg := range quote.Legs {
+		message.Legs = append(message.Legs, &walletpb.QuoteLeg{
+			SourceCrypto: leg.SourceCrypto,
+			TargetCrypto: leg.TargetCrypto,
+			SourceAmount: leg.SourceAmount,
+			TargetAmount: leg.TargetAmount,
+			Rate:         leg.Rate,
+			Spread:       leg.Spread,
+			FeeRate:      leg.FeeRate,
+			Fee:          leg.Fee,
+		})
+	}
+	return message
+}
+
+// errorCodes maps service error codes to gRPC codes. Codes missing here are
+// reported as Internal.
+var errorCodes = map[string]codes.Code{
+	service.CodeInvalidRequest:   codes.InvalidArgument,
+	service.CodeInvalidUser:      codes.InvalidArgument,
+	service.CodeInvalidQuote:     codes.InvalidArgument,
+	service.CodeUnknownAsset:     codes.InvalidArgument,
+	service.CodeNotFound:         codes.NotFound,
+	service.CodeSlippageExceeded: codes.Aborted,
+	service.CodeQuoteExpired:     codes.FailedPrecondition,
+	service.CodeInsufficientFund: codes.FailedPrecondition,
+	service.CodeAssetDisabled:    codes.FailedPrecondition,
+	service.CodeNoRoute:          codes.FailedPrecondition,
+	service.CodePriceUnavailable: codes.Unavailable,
+	service.CodeRateLimited:      codes.ResourceExhausted,
+
+	service.CodeAccountClosed:     codes.PermissionDenied,
+	service.CodeAccountRestricted: codes.PermissionDenied,
+
+	service.CodeTwoFactorRequired: codes.PermissionDenied,
+	service.CodeInvalidTwoFactor:  codes.PermissionDenied,
+	service.CodeTwoFactorLocked:   codes.PermissionDenied,
+}
+
+// statusError converts err to a gRPC status carrying the service error code
+// as the reason of an ErrorInfo detail. Internal errors are logged and their
+// cause is not returned.
+func statusError(err error) error {
+	serviceErr := service.AsError(err)
+	code, ok := errorCodes[serviceErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	if code == codes.Internal {
+		log.Printf("gRPC request failed: %v", err)
+	}
+
+	st, detailErr := status.New(code, serviceErr.Message).WithDetails(&errdetails.ErrorInfo{
+		Reason: serviceErr.Code,
+		Domain: "swap-wallet",
+	})
+	if detailErr != nil {
+		return status.Error(code, serviceErr.Message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type AdjustmentHandler struct {
+	adjustmentService *service.AdjustmentService
+	balanceService    *service.BalanceService
+}
+
+type AdjustmentsParams struct {
+	UserParams
+	Status string `query:"status" enum:"pending,applied,rejected" doc:"Only list adjustments with this status"`
+	User   int    `query:"user" minimum:"1" doc:"Only list adjustments of this user"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"500" doc:"Maximum number of adjustments (default 50)"`
+}
+
+var (
+	CreateAdjustmentDoc = openapi.Operation{
+		Summary:     "Credit or debit a user's balance",
+		Description: "Admins only. Debits need the admin's X-TOTP-Code. Adjustments worth more than the approval threshold are recorded as pending and answered with 202 until a second admin approves them.",
+		Tag:         "Admin",
+		Params:      IdempotentTwoFactorParams{},
+		Body:        service.CreateAdjustmentRequest{},
+		Response:    model.BalanceAdjustment{},
+		Status:      http.StatusCreated,
+		Responses: map[int]openapi.Response{
+			http.StatusAccepted:  {Description: "Adjustment awaits a second admin's approval", Body: model.BalanceAdjustment{}},
+			http.StatusForbidden: TwoFactorRejectedResponse,
+		},
+	}
+	GetAdjustmentsDoc = openapi.Operation{
+		Summary:  "List balance adjustments",
+		Tag:      "Admin",
+		Params:   AdjustmentsParams{},
+		Response: []model.BalanceAdjustment{},
+	}
+	GetAdjustmentDoc = openapi.Operation{
+		Summary:  "Get a balance adjustment",
+		Tag:      "Admin",
+		Params:   IDParams{},
+		Response: model.BalanceAdjustment{},
+	}
+	ApproveAdjustmentDoc = openapi.Operation{
+		Summary:     "Approve and apply a pending balance adjustment",
+		Description: "The approving admin must differ from the one who requested the adjustment, and give their X-TOTP-Code to approve a debit.",
+		Tag:         "Admin",
+		Params:      TwoFactorIDParams{},
+		Body:        service.AdjustmentDecision{},
+		Response:    model.BalanceAdjustment{},
+		Responses: map[int]openapi.Response{
+			http.StatusForbidden: TwoFactorRejectedResponse,
+		},
+	}
+	RejectAdjustmentDoc = openapi.Operation{
+		Summary:  "Reject a pending balance adjustment",
+		Tag:      "Admin",
+		Params:   IDParams{},
+		Body:     service.AdjustmentDecision{},
+		Response: model.BalanceAdjustment{},
+	}
+)
+
+func NewAdjustmentHandler(adjustmentService *service.AdjustmentService, balanceService *service.BalanceService) *AdjustmentHandler {
+	return &AdjustmentHandler{
+		adjustmentService: adjustmentService,
+		balanceService:    balanceService,
+	}
+}
+
+func (h *AdjustmentHandler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var requestData service.CreateAdjustmentRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	adjustment, err := h.adjustmentService.CreateAdjustment(r.Context(), adminId, requestData, r.Header.Get("X-TOTP-Code"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if adjustment.Status == model.AdjustmentPending {
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(adjustment)
+}
+
+func (h *AdjustmentHandler) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params AdjustmentsParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	adjustments, err := h.adjustmentService.GetAdjustments(r.Context(), adminId, params.Status, params.User, params.Limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustments)
+}
+
+func (h *AdjustmentHandler) GetAdjustment(w http.ResponseWriter, r *http.Request) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid adjustment ID")
+		return
+	}
+
+	adjustment, err := h.adjustmentService.GetAdjustment(r.Context(), adminId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustment)
+}
+
+func (h *AdjustmentHandler) ApproveAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decideAdjustment(w, r, func(ctx context.Context, adminID, adjustmentID int, decision service.AdjustmentDecision) (model.BalanceAdjustment, error) {
+		return h.adjustmentService.ApproveAdjustment(ctx, adminID, adjustmentID, decision, r.Header.Get("X-TOTP-Code"))
+	})
+}
+
+func (h *AdjustmentHandler) RejectAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decideAdjustment(w, r, h.adjustmentService.RejectAdjustment)
+}
+
+func (h *AdjustmentHandler) decideAdjustment(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, adminID, adjustmentID int, decision service.AdjustmentDecision) (model.BalanceAdjustment, error)) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid adjustment ID")
+		return
+	}
+
+	var decision service.AdjustmentDecision
+	err = json.NewDecoder(r.Body).Decode(&decision)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	adjustment, err := decide(r.Context(), adminId, params.ID, decision)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustment)
+}
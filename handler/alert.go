@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type AlertHandler struct {
+	alertService   *service.AlertService
+	balanceService *service.BalanceService
+}
+
+var (
+	CreateAlertDoc = openapi.Operation{
+		Summary:  "Create a price alert",
+		Tag:      "Alerts",
+		Params:   IdempotentUserParams{},
+		Body:     service.CreateAlertRequest{},
+		Response: model.PriceAlert{},
+		Status:   http.StatusCreated,
+	}
+	GetAlertsDoc = openapi.Operation{
+		Summary:  "List price alerts",
+		Tag:      "Alerts",
+		Params:   UserParams{},
+		Response: []model.PriceAlert{},
+	}
+	DeleteAlertDoc = openapi.Operation{
+		Summary:  "Delete a price alert",
+		Tag:      "Alerts",
+		Params:   IDParams{},
+		Response: MessageResponse{},
+	}
+)
+
+func NewAlertHandler(alertService *service.AlertService, balanceService *service.BalanceService) *AlertHandler {
+	return &AlertHandler{
+		alertService:   alertService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *AlertHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var requestData service.CreateAlertRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	alert, err := h.alertService.CreateAlert(r.Context(), userId, requestData)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(alert)
+}
+
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	alerts, err := h.alertService.GetUserAlerts(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+func (h *AlertHandler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid alert ID")
+		return
+	}
+
+	err = h.alertService.DeleteAlert(userId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Message: "Alert deleted",
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+)
+
+type AuditHandler struct {
+	auditService   *service.AuditService
+	balanceService *service.BalanceService
+}
+
+type AuditParams struct {
+	UserParams
+	TimeRangeParams
+	Action     string `query:"action" doc:"Only list entries with this action, for example exchange.completed"`
+	Actor      int    `query:"actor" minimum:"1" doc:"Only list entries of this acting user"`
+	TargetType string `query:"targetType" doc:"Only list entries about this kind of record, for example adjustment"`
+	TargetID   string `query:"targetId" doc:"Only list entries about this record; use with targetType"`
+	Before     int64  `query:"before" minimum:"1" doc:"Only list entries older than this entry id, to page back"`
+	Limit      int    `query:"limit" minimum:"1" maximum:"1000" doc:"Maximum number of entries (default 100)"`
+}
+
+var (
+	GetAuditLogDoc = openapi.Operation{
+		Summary:     "List audit log entries",
+		Description: "Compliance staff and admins only. Entries are listed newest first; pass the smallest id returned as before to page back.",
+		Tag:         "Audit",
+		Params:      AuditParams{},
+		Response:    []model.AuditEntry{},
+	}
+	VerifyAuditLogDoc = openapi.Operation{
+		Summary:     "Verify the audit log's hash chain",
+		Description: "Compliance staff and admins only. Recomputes every entry's hash and reports the first entry that does not match.",
+		Tag:         "Audit",
+		Params:      UserParams{},
+		Response:    model.AuditVerification{},
+	}
+)
+
+func NewAuditHandler(auditService *service.AuditService, balanceService *service.BalanceService) *AuditHandler {
+	return &AuditHandler{
+		auditService:   auditService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params AuditParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	from, to, err := parseTimeRange(params.TimeRangeParams, time.Time{}, time.Time{})
+	if err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	entries, err := h.auditService.GetEntries(r.Context(), userId, service.AuditQuery{
+		Action:     params.Action,
+		ActorID:    params.Actor,
+		TargetType: params.TargetType,
+		TargetID:   params.TargetID,
+		From:       from,
+		To:         to,
+		BeforeID:   params.Before,
+		Limit:      params.Limit,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (h *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	verification, err := h.auditService.Verify(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
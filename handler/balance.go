@@ -1,143 +1,401 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
+	"swap-wallet/middleware"
+	"swap-wallet/openapi"
 	"swap-wallet/service"
+	"time"
 )
 
 type BalanceHandler struct {
 	balanceService *service.BalanceService
 }
 
+// UserParams identifies the caller of every authenticated route.
+type UserParams struct {
+	UserID int `header:"userId" required:"true" doc:"Id of the calling user"`
+}
+
+// QuoteParams selects the currency balances are valued in.
+type QuoteParams struct {
+	Quote string `query:"quote" doc:"Currency to value balances in, for example EUR, BTC or USDT (default USD)"`
+}
+
+// PreferredQuoteParams selects the currency balances are valued in, falling
+// back to the caller's preferred quote currency.
+type PreferredQuoteParams struct {
+	Quote string `query:"quote" doc:"Currency to value balances in, for example EUR, BTC or USDT (default: the user's quoteCurrency, USD unless changed)"`
+}
+
+type BalanceParams struct {
+	UserParams
+	PreferredQuoteParams
+	Crypto string `query:"crypto" required:"true" doc:"Symbol of the cryptocurrency"`
+}
+
+type BalancesParams struct {
+	UserParams
+	PreferredQuoteParams
+}
+
+// PreviewQuery describes the exchange to quote.
+type PreviewQuery struct {
+	Source       string   `query:"source" required:"true" doc:"Symbol to spend"`
+	Target       string   `query:"target" required:"true" doc:"Symbol to receive"`
+	SourceAmount *float64 `query:"sourceAmount" doc:"Exact amount to spend; give this or targetAmount"`
+	TargetAmount *float64 `query:"targetAmount" doc:"Exact amount to receive; give this or sourceAmount"`
+	MaxSlippage  float64  `query:"maxSlippage" minimum:"0" maximum:"1" doc:"Fraction of adverse rate movement accepted when applying"`
+}
+
+type PreviewParams struct {
+	UserParams
+	PreviewQuery
+}
+
 type FinalizeRequest struct {
-	UserID int    `json:"userId"`
-	Token  string `json:"token"`
+	Token string `json:"token" required:"true" doc:"Token of the quote to apply"`
+}
+
+type BalanceResponse struct {
+	Crypto         string    `json:"crypto"`
+	CryptoBalance  float64   `json:"cryptoBalance"`
+	USDBalance     float64   `json:"USDBalance" doc:"Value in USD whatever the quote, kept for older clients"`
+	Quote          string    `json:"quote"`
+	QuoteBalance   float64   `json:"quoteBalance"`
+	Price          float64   `json:"price"`
+	PriceTimestamp time.Time `json:"priceTimestamp"`
+}
+
+type QuoteResponse struct {
+	ConvertedAmount float64            `json:"convertedAmount"`
+	SourceAmount    float64            `json:"sourceAmount"`
+	TargetAmount    float64            `json:"targetAmount"`
+	Fee             float64            `json:"fee"`
+	Rate            float64            `json:"rate"`
+	FixedSide       string             `json:"fixedSide"`
+	Legs            []service.QuoteLeg `json:"legs"`
+	Token           string             `json:"token"`
+	ExpiresAt       time.Time          `json:"expiresAt"`
+}
+
+// SlippageResponse is the error envelope with the rates that caused the
+// rejection and, when the house requotes, a fresh quote.
+type SlippageResponse struct {
+	Error      openapi.ErrorBody `json:"error"`
+	QuotedRate float64           `json:"quotedRate"`
+	LiveRate   float64           `json:"liveRate"`
+	Quote      *QuoteResponse    `json:"quote,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
 }
 
+var (
+	GetUserBalanceDoc = openapi.Operation{
+		Summary:  "Get the balance of one cryptocurrency",
+		Tag:      "Balances",
+		Params:   BalanceParams{},
+		Response: BalanceResponse{},
+		Responses: map[int]openapi.Response{
+			http.StatusTooManyRequests: RateLimitedResponse,
+		},
+	}
+	GetAllUserBalancesDoc = openapi.Operation{
+		Summary:  "Get all balances",
+		Tag:      "Balances",
+		Params:   BalancesParams{},
+		Response: []service.CryptoBalanceType{},
+		Responses: map[int]openapi.Response{
+			http.StatusTooManyRequests: RateLimitedResponse,
+		},
+	}
+	GetPortfolioDoc = openapi.Operation{
+		Summary:  "Get all balances and the portfolio total",
+		Tag:      "Balances",
+		Params:   BalancesParams{},
+		Response: service.Portfolio{},
+		Responses: map[int]openapi.Response{
+			http.StatusTooManyRequests: RateLimitedResponse,
+		},
+	}
+	GetExchangePreviewDoc = openapi.Operation{
+		Summary:  "Quote an exchange",
+		Tag:      "Exchanges",
+		Params:   PreviewParams{},
+		Response: QuoteResponse{},
+		Responses: map[int]openapi.Response{
+			http.StatusUnprocessableEntity: {Description: "An asset is disabled or there is no route between them (asset_disabled, no_route)", Body: openapi.ErrorResponse{}},
+			http.StatusTooManyRequests:     RateLimitedResponse,
+			http.StatusServiceUnavailable:  {Description: "A price needed for the quote is unavailable (price_unavailable)", Body: openapi.ErrorResponse{}},
+		},
+	}
+	FinalizeExchangeDoc = openapi.Operation{
+		Summary:     "Apply a quoted exchange",
+		Description: "Exchanges worth more than the two-factor threshold need an X-TOTP-Code. A quote rejected for its code can be applied again with one.",
+		Tag:         "Exchanges",
+		Params:      IdempotentTwoFactorParams{},
+		Body:        FinalizeRequest{},
+		Response:    MessageResponse{},
+		Responses: map[int]openapi.Response{
+			http.StatusForbidden:           TwoFactorRejectedResponse,
+			http.StatusConflict:            {Description: "The rate moved beyond the allowed slippage", Body: SlippageResponse{}},
+			http.StatusGone:                {Description: "The quote expired or was already applied (quote_expired)", Body: openapi.ErrorResponse{}},
+			http.StatusUnprocessableEntity: {Description: "The balance cannot cover the exchange (insufficient_funds)", Body: openapi.ErrorResponse{}},
+			http.StatusTooManyRequests:     RateLimitedResponse,
+		},
+	}
+)
+
 func NewBalanceHandler(balanceService *service.BalanceService) *BalanceHandler {
 	return &BalanceHandler{balanceService: balanceService}
 }
 
 func (h *BalanceHandler) GetUserBalance(w http.ResponseWriter, r *http.Request) {
-	userId, err := h.checkUserExists(r.Header.Get("userId"))
+	userId, err := h.checkUserExists(r.Context(), r.Header.Get("userId"))
 	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		writeUserError(w, r, err)
 		return
 	}
 
-	query := r.URL.Query()
-	crypto := query.Get("crypto")
-
-	cryptoBalance, usdBalance, err := h.balanceService.GetUserBalanceWithUsd(userId, crypto)
+	var params BalanceParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	quote, err := h.preferredQuoteCurrency(userId, params.PreferredQuoteParams)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"crypto":        crypto,
-		"cryptoBalance": cryptoBalance,
-		"USDBalance":    usdBalance,
+	balance, err := h.balanceService.GetUserBalanceInQuote(userId, params.Crypto, quote)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(BalanceResponse{
+		Crypto:         params.Crypto,
+		CryptoBalance:  balance.CryptoBalance,
+		USDBalance:     balance.USDBalance,
+		Quote:          quote,
+		QuoteBalance:   balance.QuoteBalance,
+		Price:          balance.Price,
+		PriceTimestamp: balance.PriceTimestamp,
+	})
 }
 
+// GetAllUserBalances lists the caller's balances as an array, as it did
+// before the portfolio total was added; GetPortfolio returns the total too.
 func (h *BalanceHandler) GetAllUserBalances(w http.ResponseWriter, r *http.Request) {
-	userId, err := h.checkUserExists(r.Header.Get("userId"))
-	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+	portfolio, ok := h.portfolio(w, r)
+	if !ok {
 		return
 	}
-	balances, err := h.balanceService.GetUserBalancesWithUsd(userId)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portfolio.Balances)
+}
+
+func (h *BalanceHandler) GetPortfolio(w http.ResponseWriter, r *http.Request) {
+	portfolio, ok := h.portfolio(w, r)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(balances)
+	json.NewEncoder(w).Encode(portfolio)
 }
 
-func (h *BalanceHandler) checkUserExists(userId string) (int, error) {
-	userID, err := strconv.Atoi(userId)
+// portfolio values the caller's balances in the requested quote currency,
+// writing the error response when that fails.
+func (h *BalanceHandler) portfolio(w http.ResponseWriter, r *http.Request) (service.Portfolio, bool) {
+	userId, err := h.checkUserExists(r.Context(), r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return service.Portfolio{}, false
+	}
+
+	var params BalancesParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return service.Portfolio{}, false
+	}
 
+	quote, err := h.preferredQuoteCurrency(userId, params.PreferredQuoteParams)
 	if err != nil {
-		return -1, err
+		writeError(w, r, err)
+		return service.Portfolio{}, false
 	}
 
-	userExists := h.balanceService.UserExists(userID)
-	if !userExists {
-		return -1, err
+	portfolio, err := h.balanceService.GetUserBalancesInQuote(userId, quote)
+	if err != nil {
+		writeError(w, r, err)
+		return service.Portfolio{}, false
 	}
-	return userID, nil
+	return portfolio, true
 }
 
-func (h *BalanceHandler) GetExchangePreviewHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := h.checkUserExists(r.Header.Get("userId"))
-	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
-		return
+// quoteCurrency returns the currency balances are valued in, defaulting to
+// USD.
+func quoteCurrency(params QuoteParams) string {
+	quote := strings.ToUpper(params.Quote)
+	if quote == "" {
+		return service.DefaultQuoteCurrency
 	}
+	return quote
+}
 
-	sourceAmountStr := r.URL.Query().Get("sourceAmount")
-	source := r.URL.Query().Get("source")
-	target := r.URL.Query().Get("target")
-	if sourceAmountStr == "" || source == "" || target == "" {
-		http.Error(w, "Missing query parameters", http.StatusBadRequest)
-		return
+// preferredQuoteCurrency returns the requested currency, or the user's
+// preferred quote currency when none is requested.
+func (h *BalanceHandler) preferredQuoteCurrency(userId int, params PreferredQuoteParams) (string, error) {
+	if params.Quote != "" {
+		return strings.ToUpper(params.Quote), nil
+	}
+	return h.balanceService.PreferredQuoteCurrency(userId)
+}
+
+func (h *BalanceHandler) checkUserExists(ctx context.Context, userId string) (int, error) {
+	return checkUserExists(ctx, h.balanceService, userId)
+}
+
+// checkUserExists authenticates the caller by user id. Successes and
+// failures are recorded in the audit log.
+func checkUserExists(ctx context.Context, balanceService *service.BalanceService, userId string) (int, error) {
+	return balanceService.Authenticate(ctx, userId)
+}
+
+// previewRequest is a validated exchange preview.
+type previewRequest struct {
+	source      string
+	target      string
+	amount      float64
+	fixedSide   string
+	maxSlippage float64
+}
+
+// parsePreviewRequest reads the PreviewQuery parameters and checks that
+// exactly one positive amount is given.
+func parsePreviewRequest(r *http.Request) (previewRequest, error) {
+	var query PreviewQuery
+	if err := openapi.DecodeParams(r, &query); err != nil {
+		return previewRequest{}, err
+	}
+
+	req := previewRequest{
+		source:      query.Source,
+		target:      query.Target,
+		maxSlippage: query.MaxSlippage,
+	}
+	if (query.SourceAmount == nil) == (query.TargetAmount == nil) || req.source == "" || req.target == "" {
+		return req, fmt.Errorf("Missing query parameters: source, target and exactly one of sourceAmount or targetAmount are required")
 	}
 
-	sourceAmount, err := strconv.ParseFloat(sourceAmountStr, 64)
+	if query.SourceAmount != nil {
+		req.fixedSide, req.amount = service.FixedSideSource, *query.SourceAmount
+	} else {
+		req.fixedSide, req.amount = service.FixedSideTarget, *query.TargetAmount
+	}
+	if req.amount <= 0 {
+		return req, fmt.Errorf("Invalid %sAmount", req.fixedSide)
+	}
+
+	if req.maxSlippage < 0 || req.maxSlippage > 1 {
+		return req, fmt.Errorf("Invalid maxSlippage")
+	}
+
+	return req, nil
+}
+
+func (h *BalanceHandler) GetExchangePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := h.checkUserExists(r.Context(), r.Header.Get("userId"))
 	if err != nil {
-		http.Error(w, "Invalid sourceAmount", http.StatusBadRequest)
+		writeUserError(w, r, err)
 		return
 	}
 
-	convertedAmount, token, err := h.balanceService.GetExchangePreview(source, target, sourceAmount)
-
+	req, err := parsePreviewRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInvalidRequest(w, r, err.Error())
 		return
 	}
 
-	response := map[string]interface{}{
-		"convertedAmount": convertedAmount,
-		"token":           token,
+	quote, err := h.balanceService.GetExchangePreview(r.Context(), userId, req.source, req.target, req.amount, req.fixedSide, req.maxSlippage)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(quoteResponse(quote))
 }
 
 func (h *BalanceHandler) FinalizeExchangeHandler(w http.ResponseWriter, r *http.Request) {
-	userId, err := h.checkUserExists(r.Header.Get("userId"))
+	userId, err := h.checkUserExists(r.Context(), r.Header.Get("userId"))
 	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		writeUserError(w, r, err)
 		return
 	}
 
-	var requestData struct {
-		Token string `json:"token"`
-	}
-
+	var requestData FinalizeRequest
 	err = json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeInvalidRequest(w, r, "Invalid request body")
 		return
 	}
 
-	err = h.balanceService.FinalizeExchange(userId, requestData.Token)
+	err = h.balanceService.FinalizeExchange(r.Context(), userId, requestData.Token, r.Header.Get("X-TOTP-Code"))
+	var slippageErr *service.SlippageError
+	if errors.As(err, &slippageErr) {
+		response := SlippageResponse{
+			Error: openapi.ErrorBody{
+				Code:      service.CodeSlippageExceeded,
+				Message:   slippageErr.Error(),
+				RequestID: middleware.RequestIDFrom(r.Context()),
+			},
+			QuotedRate: slippageErr.QuotedRate,
+			LiveRate:   slippageErr.LiveRate,
+		}
+		if slippageErr.Quote != nil {
+			requote := quoteResponse(*slippageErr.Quote)
+			response.Quote = &requote
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Conversion finalized successfully",
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{
+		Message: "Conversion finalized successfully",
 	})
 }
+
+func quoteResponse(quote service.Quote) QuoteResponse {
+	return QuoteResponse{
+		ConvertedAmount: quote.TargetAmount,
+		SourceAmount:    quote.SourceAmount,
+		TargetAmount:    quote.TargetAmount,
+		Fee:             quote.Fee,
+		Rate:            quote.Rate,
+		FixedSide:       quote.FixedSide,
+		Legs:            quote.Legs,
+		Token:           quote.Token,
+		ExpiresAt:       quote.ExpiresAt,
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"swap-wallet/middleware"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+// errorStatus maps service error codes to HTTP statuses. Codes missing here
+// are reported as 500.
+var errorStatus = map[string]int{
+	service.CodeInvalidRequest:   http.StatusBadRequest,
+	service.CodeInvalidUser:      http.StatusBadRequest,
+	service.CodeInvalidQuote:     http.StatusBadRequest,
+	service.CodeUnknownAsset:     http.StatusBadRequest,
+	service.CodeNotFound:         http.StatusNotFound,
+	service.CodeSlippageExceeded: http.StatusConflict,
+	service.CodeQuoteExpired:     http.StatusGone,
+	service.CodeInsufficientFund: http.StatusUnprocessableEntity,
+	service.CodeAssetDisabled:    http.StatusUnprocessableEntity,
+	service.CodeNoRoute:          http.StatusUnprocessableEntity,
+	service.CodePriceUnavailable: http.StatusServiceUnavailable,
+
+	service.CodeRequestInProgress:    http.StatusConflict,
+	service.CodeIdempotencyKeyReused: http.StatusUnprocessableEntity,
+	service.CodeRateLimited:          http.StatusTooManyRequests,
+
+	service.CodeForbidden:      http.StatusForbidden,
+	service.CodeAlreadyDecided: http.StatusConflict,
+
+	service.CodeAccountClosed:     http.StatusForbidden,
+	service.CodeAccountRestricted: http.StatusForbidden,
+	service.CodeAlreadyExists:     http.StatusConflict,
+	service.CodeAccountNotEmpty:   http.StatusConflict,
+
+	service.CodeTwoFactorRequired: http.StatusForbidden,
+	service.CodeInvalidTwoFactor:  http.StatusForbidden,
+	service.CodeTwoFactorLocked:   http.StatusForbidden,
+}
+
+func errorStatusFor(code string) int {
+	if status, ok := errorStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// clientError converts err for the client. Service errors keep their code
+// and message; anything else is logged with the request id and becomes an
+// internal_error that does not reveal its cause.
+func clientError(r *http.Request, err error) *service.Error {
+	serviceErr := service.AsError(err)
+	if serviceErr.Code == service.CodeInternal {
+		log.Printf("Request %s failed: %v", middleware.RequestIDFrom(r.Context()), err)
+	}
+	return serviceErr
+}
+
+// writeError reports err as a JSON error with the status of its code.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := clientError(r, err)
+	openapi.WriteError(w, r, errorStatusFor(serviceErr.Code), serviceErr.Code, serviceErr.Message, nil)
+}
+
+func writeInvalidRequest(w http.ResponseWriter, r *http.Request, message string) {
+	openapi.WriteError(w, r, http.StatusBadRequest, service.CodeInvalidRequest, message, nil)
+}
+
+// writeUserError reports a failed checkUserExists. Closed accounts are
+// reported as such; any other failure is an invalid user id.
+func writeUserError(w http.ResponseWriter, r *http.Request, err error) {
+	if service.AsError(err).Code == service.CodeAccountClosed {
+		writeError(w, r, err)
+		return
+	}
+	openapi.WriteError(w, r, http.StatusBadRequest, service.CodeInvalidUser, "Invalid User ID", nil)
+}
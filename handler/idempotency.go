@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"swap-wallet/config"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyParams documents the optional Idempotency-Key header of routes
+// wrapped by Idempotent.
+type IdempotencyParams struct {
+	IdempotencyKey string `header:"Idempotency-Key" doc:"Unique key of this request; retries with the same key return the original response instead of repeating it"`
+}
+
+type IdempotentUserParams struct {
+	UserParams
+	IdempotencyParams
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// twoFactorChallenge reports whether a recorded response asks for a
+// two-factor code. The client is expected to retry with a code under the
+// same key, so such responses must not be replayed.
+func (r *responseRecorder) twoFactorChallenge() bool {
+	if r.status != http.StatusForbidden {
+		return false
+	}
+	var response openapi.ErrorResponse
+	if json.Unmarshal(r.body.Bytes(), &response) != nil {
+		return false
+	}
+	switch response.Error.Code {
+	case service.CodeTwoFactorRequired, service.CodeInvalidTwoFactor, service.CodeTwoFactorLocked:
+		return true
+	}
+	return false
+}
+
+// Idempotent makes next safe to retry. A request carrying an Idempotency-Key
+// runs once per user, route and key; repeats of it within a day get the
+// stored response with an Idempotent-Replayed header. Server errors and
+// two-factor challenges are not stored, so retrying after one runs the
+// request again.
+func Idempotent(idempotencyService *service.IdempotencyService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > config.MaxIdempotencyKey {
+			writeInvalidRequest(w, r, fmt.Sprintf("Idempotency-Key must be at most %d characters", config.MaxIdempotencyKey))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeInvalidRequest(w, r, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+		fingerprint := hex.EncodeToString(hash[:])
+		scope := r.Header.Get("userId") + ":" + r.Method + ":" + r.URL.Path + ":" + key
+
+		stored, err := idempotencyService.Begin(scope, fingerprint)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if stored != nil {
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError || recorder.twoFactorChallenge() {
+			err = idempotencyService.Abandon(scope)
+		} else {
+			err = idempotencyService.Complete(scope, service.StoredResponse{
+				Fingerprint: fingerprint,
+				Status:      recorder.status,
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to record response for idempotency key %q: %v", key, err)
+		}
+	}
+}
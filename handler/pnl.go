@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type PnLHandler struct {
+	pnlService     *service.PnLService
+	balanceService *service.BalanceService
+}
+
+type PnLParams struct {
+	UserParams
+	Method string `query:"method" enum:"fifo,lifo,average" doc:"Cost basis method (default fifo)"`
+}
+
+var GetUserPnLDoc = openapi.Operation{
+	Summary:  "Get cost basis and realized and unrealized profit and loss",
+	Tag:      "Balances",
+	Params:   PnLParams{},
+	Response: service.PortfolioPnL{},
+}
+
+func NewPnLHandler(pnlService *service.PnLService, balanceService *service.BalanceService) *PnLHandler {
+	return &PnLHandler{
+		pnlService:     pnlService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *PnLHandler) GetUserPnL(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params PnLParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	method := strings.ToLower(params.Method)
+	if method == "" {
+		method = service.CostBasisFIFO
+	}
+	if method != service.CostBasisFIFO && method != service.CostBasisLIFO && method != service.CostBasisAverage {
+		writeInvalidRequest(w, r, "Invalid method: use fifo, lifo or average")
+		return
+	}
+
+	pnl, err := h.pnlService.GetUserPnL(userId, method)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pnl)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+)
+
+type PortfolioHandler struct {
+	historyService *service.HistoryService
+	balanceService *service.BalanceService
+}
+
+// TimeRangeParams bound a time series. Both accept RFC 3339 times or
+// YYYY-MM-DD dates.
+type TimeRangeParams struct {
+	From string `query:"from" doc:"Start of the range, RFC 3339 time or YYYY-MM-DD date"`
+	To   string `query:"to" doc:"End of the range, RFC 3339 time or YYYY-MM-DD date"`
+}
+
+type PortfolioHistoryParams struct {
+	UserParams
+	QuoteParams
+	TimeRangeParams
+	Granularity string `query:"granularity" enum:"hourly,daily" doc:"Spacing of the points (default daily)"`
+}
+
+var GetPortfolioHistoryDoc = openapi.Operation{
+	Summary:  "Get the portfolio value over time",
+	Tag:      "Balances",
+	Params:   PortfolioHistoryParams{},
+	Response: service.PortfolioHistory{},
+}
+
+func NewPortfolioHandler(historyService *service.HistoryService, balanceService *service.BalanceService) *PortfolioHandler {
+	return &PortfolioHandler{
+		historyService: historyService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *PortfolioHandler) GetPortfolioHistory(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params PortfolioHistoryParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	granularity := params.Granularity
+	if granularity == "" {
+		granularity = service.GranularityDaily
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	if granularity == service.GranularityHourly {
+		from = to.AddDate(0, 0, -7)
+	}
+	from, to, err = parseTimeRange(params.TimeRangeParams, from, to)
+	if err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	history, err := h.historyService.GetPortfolioHistory(userId, quoteCurrency(params.QuoteParams), granularity, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// parseTimeRange reads the optional from and to parameters, given either as
+// RFC 3339 times or as dates (midnight UTC), falling back to the given
+// defaults.
+func parseTimeRange(params TimeRangeParams, from, to time.Time) (time.Time, time.Time, error) {
+	var err error
+	if params.From != "" {
+		from, err = parseTime(params.From)
+		if err != nil {
+			return from, to, fmt.Errorf("Invalid from: expected RFC 3339 time or YYYY-MM-DD date")
+		}
+	}
+	if params.To != "" {
+		to, err = parseTime(params.To)
+		if err != nil {
+			return from, to, fmt.Errorf("Invalid to: expected RFC 3339 time or YYYY-MM-DD date")
+		}
+	}
+	return from, to, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02", value)
+	}
+	return parsed, err
+}
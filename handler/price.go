@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+)
+
+type PriceHandler struct {
+	historyService *service.HistoryService
+}
+
+type CandleParams struct {
+	QuoteParams
+	TimeRangeParams
+	Symbol   string `path:"symbol" doc:"Symbol of the cryptocurrency"`
+	Interval string `query:"interval" enum:"1m,5m,15m,1h,4h,1d" doc:"Candle resolution (default 1h)"`
+}
+
+var GetCandlesDoc = openapi.Operation{
+	Summary:  "Get OHLC candles of observed prices",
+	Tag:      "Prices",
+	Params:   CandleParams{},
+	Response: []model.Candle{},
+}
+
+func NewPriceHandler(historyService *service.HistoryService) *PriceHandler {
+	return &PriceHandler{historyService: historyService}
+}
+
+func (h *PriceHandler) GetCandles(w http.ResponseWriter, r *http.Request) {
+	var params CandleParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	symbol := strings.ToUpper(params.Symbol)
+
+	interval := params.Interval
+	if interval == "" {
+		interval = "1h"
+	}
+	seconds, ok := config.CandleIntervals[interval]
+	if !ok {
+		writeInvalidRequest(w, r, "Invalid interval: use 1m, 5m, 15m, 1h, 4h or 1d")
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-100 * time.Duration(seconds) * time.Second)
+	from, to, err := parseTimeRange(params.TimeRangeParams, from, to)
+	if err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	candles, err := h.historyService.GetCandles(symbol, quoteCurrency(params.QuoteParams), interval, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candles)
+}
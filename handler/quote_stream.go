@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"swap-wallet/config"
+	"swap-wallet/middleware"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+)
+
+type QuoteStreamParams struct {
+	StreamUserParams
+	PreviewQuery
+}
+
+// PreviewResponse is a priced exchange that was not issued as a quote, so it
+// carries no token to apply. ExpiresAt is when the stream replaces it with a
+// fresh preview.
+type PreviewResponse struct {
+	ConvertedAmount float64            `json:"convertedAmount"`
+	SourceAmount    float64            `json:"sourceAmount"`
+	TargetAmount    float64            `json:"targetAmount"`
+	Fee             float64            `json:"fee"`
+	Rate            float64            `json:"rate"`
+	FixedSide       string             `json:"fixedSide"`
+	Legs            []service.QuoteLeg `json:"legs"`
+	ExpiresAt       time.Time          `json:"expiresAt"`
+}
+
+var StreamQuotesDoc = openapi.Operation{
+	Summary:     "Stream live exchange previews as Server-Sent Events",
+	Description: "Each preview event carries a PreviewResponse whose expiresAt is when the next preview replaces it. Previews cannot be applied: to apply the streamed price, call /exchange/preview with the same parameters for a quote and pass its token to /exchange/apply before the quote's expiresAt. Pricing failures arrive as error events carrying an error code and the stream closes with an end event.",
+	Tag:         "Exchanges",
+	Params:      QuoteStreamParams{},
+	Response:    PreviewResponse{},
+	ContentType: "text/event-stream",
+	Responses: map[int]openapi.Response{
+		http.StatusTooManyRequests: RateLimitedResponse,
+	},
+}
+
+// StreamQuotesHandler streams live pricing of the preview given in the query
+// string as Server-Sent Events, for clients that cannot use WebSockets.
+// Previews are not issued as quotes, so streaming stores nothing; each one
+// expires when the next is sent, and the client asks /exchange/preview for a
+// quote to apply once the user decides to exchange.
+// Pricing failures are sent as error events and the stream carries on.
+func (h *BalanceHandler) StreamQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := h.checkUserExists(r.Context(), streamUserID(r))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	req, err := parsePreviewRequest(r)
+	if err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		openapi.WriteError(w, r, http.StatusInternalServerError, service.CodeInternal, "Streaming unsupported", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", config.QuoteStreamInterval*1000)
+
+	ticker := time.NewTicker(config.QuoteStreamInterval * time.Second)
+	defer ticker.Stop()
+	deadline := time.NewTimer(config.QuoteStreamMaxDuration * time.Second)
+	defer deadline.Stop()
+
+	for id := 1; ; id++ {
+		preview, err := h.balanceService.PriceExchange(userId, req.source, req.target, req.amount, req.fixedSide, req.maxSlippage)
+		if err != nil {
+			serviceErr := clientError(r, err)
+			writeEvent(w, id, "error", openapi.ErrorBody{
+				Code:      serviceErr.Code,
+				Message:   serviceErr.Message,
+				RequestID: middleware.RequestIDFrom(r.Context()),
+			})
+		} else {
+			writeEvent(w, id, "preview", PreviewResponse{
+				ConvertedAmount: preview.TargetAmount,
+				SourceAmount:    preview.SourceAmount,
+				TargetAmount:    preview.TargetAmount,
+				Fee:             preview.Fee,
+				Rate:            preview.Rate,
+				FixedSide:       preview.FixedSide,
+				Legs:            preview.Legs,
+				ExpiresAt:       time.Now().Add(config.QuoteStreamInterval * time.Second).UTC(),
+			})
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			writeEvent(w, id+1, "end", map[string]string{"message": "Quote stream expired"})
+			flusher.Flush()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, id int, event string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, encoded)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"swap-wallet/middleware"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+// Rate limit headers describe the most constrained bucket the request took
+// from: its size, the requests left in it and the seconds until it is full.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimitedResponse documents requests rejected by RateLimited.
+var RateLimitedResponse = openapi.Response{
+	Description: "Too many requests from the user or IP; retry after the Retry-After header's seconds (rate_limited)",
+	Body:        openapi.ErrorResponse{},
+}
+
+// RateLimited limits next with the limits of a route group, per calling user
+// and per client IP. Requests over a limit get a 429 with a Retry-After
+// header. Malformed user ids are only limited by IP; they fail on their own.
+func RateLimited(rateLimiter *service.RateLimiter, group string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := streamUserID(r)
+		if _, err := strconv.Atoi(userId); err != nil {
+			userId = ""
+		}
+
+		result, err := rateLimiter.Allow(r.Context(), group, userId, middleware.ClientIPFrom(r.Context()))
+		if result.Limit > 0 {
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			w.Header().Set(RateLimitResetHeader, strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+		}
+		if err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(result.RetryAfterSeconds()))
+			writeError(w, r, err)
+			return
+		}
+
+		next(w, r)
+	}
+}
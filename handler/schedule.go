@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+	balanceService  *service.BalanceService
+}
+
+// IDParams address one of the caller's resources by id.
+type IDParams struct {
+	UserParams
+	ID int `path:"id"`
+}
+
+var (
+	CreateScheduleDoc = openapi.Operation{
+		Summary:     "Create a recurring exchange",
+		Description: "Exchanges worth more than the two-factor threshold need an X-TOTP-Code when the schedule is created; its runs need none.",
+		Tag:         "Schedules",
+		Params:      IdempotentTwoFactorParams{},
+		Body:        service.CreateScheduleRequest{},
+		Response:    model.Schedule{},
+		Status:      http.StatusCreated,
+	}
+	GetSchedulesDoc = openapi.Operation{
+		Summary:  "List recurring exchanges",
+		Tag:      "Schedules",
+		Params:   UserParams{},
+		Response: []model.Schedule{},
+	}
+	PauseScheduleDoc = openapi.Operation{
+		Summary:  "Pause a recurring exchange",
+		Tag:      "Schedules",
+		Params:   IDParams{},
+		Response: MessageResponse{},
+	}
+	ResumeScheduleDoc = openapi.Operation{
+		Summary:     "Resume a recurring exchange",
+		Description: "Schedules above the two-factor threshold need a code, which approves their runs again.",
+		Tag:         "Schedules",
+		Params:      TwoFactorIDParams{},
+		Response:    MessageResponse{},
+	}
+	DeleteScheduleDoc = openapi.Operation{
+		Summary:  "Delete a recurring exchange",
+		Tag:      "Schedules",
+		Params:   IDParams{},
+		Response: MessageResponse{},
+	}
+)
+
+func NewScheduleHandler(scheduleService *service.ScheduleService, balanceService *service.BalanceService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+		balanceService:  balanceService,
+	}
+}
+
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var requestData service.CreateScheduleRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(r.Context(), userId, requestData, r.Header.Get("X-TOTP-Code"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (h *ScheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	schedules, err := h.scheduleService.GetUserSchedules(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func (h *ScheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	h.changeSchedule(w, r, h.scheduleService.PauseSchedule, "Schedule paused")
+}
+
+func (h *ScheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	resume := func(userID, scheduleID int) error {
+		return h.scheduleService.ResumeSchedule(r.Context(), userID, scheduleID, r.Header.Get("X-TOTP-Code"))
+	}
+	h.changeSchedule(w, r, resume, "Schedule resumed")
+}
+
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	h.changeSchedule(w, r, h.scheduleService.DeleteSchedule, "Schedule deleted")
+}
+
+func (h *ScheduleHandler) changeSchedule(w http.ResponseWriter, r *http.Request, change func(userID, scheduleID int) error, message string) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid schedule ID")
+		return
+	}
+
+	err = change(userId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Message: message,
+	})
+}
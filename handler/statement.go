@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+)
+
+type StatementHandler struct {
+	statementService *service.StatementService
+	balanceService   *service.BalanceService
+}
+
+type StatementParams struct {
+	UserParams
+	TimeRangeParams
+	Format string `query:"format" enum:"csv,pdf" doc:"Document format (default csv)"`
+}
+
+var GetStatementDoc = openapi.Operation{
+	Summary:     "Download an account statement",
+	Description: "Returns text/csv or, with format=pdf, application/pdf. The period defaults to the last month.",
+	Tag:         "Statements",
+	Params:      StatementParams{},
+	ContentType: "text/csv",
+}
+
+func NewStatementHandler(statementService *service.StatementService, balanceService *service.BalanceService) *StatementHandler {
+	return &StatementHandler{
+		statementService: statementService,
+		balanceService:   balanceService,
+	}
+}
+
+func (h *StatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params StatementParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+	format := params.Format
+	if format == "" {
+		format = service.StatementCSV
+	}
+	if format != service.StatementCSV && format != service.StatementPDF {
+		writeInvalidRequest(w, r, "Invalid format: use csv or pdf")
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, -1, 0)
+	from, to, err = parseTimeRange(params.TimeRangeParams, from, to)
+	if err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	statement, err := h.statementService.GetStatement(userId, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "text/csv"
+	if format == service.StatementPDF {
+		contentType = "application/pdf"
+		err = service.WriteStatementPDF(&body, statement)
+	} else {
+		err = service.WriteStatementCSV(&body, statement)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type StreamHandler struct {
+	hub            *service.StreamHub
+	balanceService *service.BalanceService
+	upgrader       websocket.Upgrader
+	allowedOrigins []string
+}
+
+// StreamUserParams identify the caller of a streaming route. Browsers cannot
+// set headers on WebSocket or EventSource requests, so the user id may be
+// passed in the query string instead.
+type StreamUserParams struct {
+	UserID      int `header:"userId" doc:"Id of the calling user"`
+	QueryUserID int `query:"userId" doc:"Id of the calling user, when the header cannot be set"`
+}
+
+var StreamDoc = openapi.Operation{
+	Summary:     "Stream prices and balance updates over a WebSocket",
+	Description: "Send {\"action\": \"subscribe\", \"channel\": \"prices\", \"pairs\": [\"BTC/USD\"]} or {\"action\": \"subscribe\", \"channel\": \"balances\"}; unsubscribe takes the same form.",
+	Tag:         "Streaming",
+	Params:      StreamUserParams{},
+	Status:      http.StatusSwitchingProtocols,
+	Responses: map[int]openapi.Response{
+		http.StatusForbidden:       {Description: "The request came from a browser page of an origin that is not allowed"},
+		http.StatusTooManyRequests: RateLimitedResponse,
+	},
+}
+
+// streamUserID returns the user id from the userId header, or from the query
+// string when the header is absent.
+func streamUserID(r *http.Request) string {
+	if userId := r.Header.Get("userId"); userId != "" {
+		return userId
+	}
+	return r.URL.Query().Get("userId")
+}
+
+// streamRequest is a message sent by a streaming client, for example
+// {"action": "subscribe", "channel": "prices", "pairs": ["BTC/USD"]}.
+type streamRequest struct {
+	Action  string   `json:"action"`
+	Channel string   `json:"channel"`
+	Pairs   []string `json:"pairs"`
+}
+
+func NewStreamHandler(hub *service.StreamHub, balanceService *service.BalanceService) *StreamHandler {
+	h := &StreamHandler{
+		hub:            hub,
+		balanceService: balanceService,
+		allowedOrigins: config.LoadStreamAllowedOrigins(),
+	}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+// checkOrigin lets browsers open streams only from pages served by the API's
+// own host or from an allowed origin, so other sites cannot open a stream on
+// a visitor's behalf. Clients other than browsers send no Origin header.
+func (h *StreamHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	originURL, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(originURL.Host, r.Host)
+}
+
+// Stream upgrades the request to a WebSocket on which the user can subscribe
+// to price tickers and to their own balance updates. Browsers cannot set
+// headers on WebSocket requests, so the user id may also be passed as the
+// userId query parameter.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, streamUserID(r))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade stream connection: %v", err)
+		return
+	}
+
+	client := h.hub.Register(userId)
+	go h.writeMessages(conn, client)
+	h.readMessages(conn, client)
+}
+
+// readMessages handles subscription requests until the connection fails or
+// goes quiet for longer than the pong wait.
+func (h *StreamHandler) readMessages(conn *websocket.Conn, client *service.StreamClient) {
+	defer h.hub.Unregister(client)
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(config.StreamPongWait * time.Second))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(config.StreamPongWait * time.Second))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var request streamRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			client.Enqueue(service.StreamMessage{Type: "error", Message: "invalid message"})
+			continue
+		}
+
+		switch request.Action {
+		case "subscribe":
+			err = client.Subscribe(request.Channel, request.Pairs)
+		case "unsubscribe":
+			err = client.Unsubscribe(request.Channel, request.Pairs)
+		default:
+			client.Enqueue(service.StreamMessage{Type: "error", Message: "unknown action: " + request.Action})
+			continue
+		}
+
+		if err != nil {
+			client.Enqueue(service.StreamMessage{Type: "error", Message: err.Error()})
+			continue
+		}
+		client.Enqueue(service.StreamMessage{Type: request.Action + "d", Data: request})
+	}
+}
+
+// writeMessages sends queued messages and heartbeat pings. It closes the
+// connection once the client's queue is closed, either because the client
+// disconnected or because it fell too far behind.
+func (h *StreamHandler) writeMessages(conn *websocket.Conn, client *service.StreamClient) {
+	ticker := time.NewTicker(config.StreamPingInterval * time.Second)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.Send:
+			conn.SetWriteDeadline(time.Now().Add(config.StreamWriteWait * time.Second))
+			if !ok {
+				if client.TooSlow() {
+					conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				}
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(config.StreamWriteWait * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
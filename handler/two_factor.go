@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+	balanceService   *service.BalanceService
+}
+
+// TwoFactorParams carry the code of actions that need two-factor
+// authentication.
+type TwoFactorParams struct {
+	TOTPCode string `header:"X-TOTP-Code" doc:"Current code of the caller's authenticator app, or one of their recovery codes"`
+}
+
+type TwoFactorUserParams struct {
+	UserParams
+	TwoFactorParams
+}
+
+type TwoFactorIDParams struct {
+	IDParams
+	TwoFactorParams
+}
+
+type IdempotentTwoFactorParams struct {
+	IdempotentUserParams
+	TwoFactorParams
+}
+
+// TwoFactorRejectedResponse documents requests rejected for their
+// two-factor code.
+var TwoFactorRejectedResponse = openapi.Response{
+	Description: "A two-factor code is missing or invalid, or too many were invalid (two_factor_required, invalid_two_factor_code, two_factor_locked)",
+	Body:        openapi.ErrorResponse{},
+}
+
+// The two-factor routes are not idempotent, so secrets and recovery codes
+// are never stored for replay.
+var (
+	GetTwoFactorDoc = openapi.Operation{
+		Summary:  "Get the caller's two-factor status",
+		Tag:      "Two-factor",
+		Params:   UserParams{},
+		Response: service.TwoFactorStatus{},
+	}
+	EnrolTwoFactorDoc = openapi.Operation{
+		Summary:     "Start two-factor enrolment",
+		Description: "Returns a new TOTP secret for an authenticator app, replacing any unconfirmed one. Two-factor authentication is enabled once a code of it is confirmed.",
+		Tag:         "Two-factor",
+		Params:      UserParams{},
+		Response:    service.TwoFactorEnrolment{},
+		Status:      http.StatusCreated,
+	}
+	ConfirmTwoFactorDoc = openapi.Operation{
+		Summary:     "Confirm two-factor enrolment",
+		Description: "Enables two-factor authentication and returns the caller's recovery codes. Invalid codes count towards the two-factor lockout.",
+		Tag:         "Two-factor",
+		Params:      UserParams{},
+		Body:        service.ConfirmTwoFactorRequest{},
+		Response:    service.RecoveryCodes{},
+		Responses: map[int]openapi.Response{
+			http.StatusForbidden: TwoFactorRejectedResponse,
+		},
+	}
+	ResetRecoveryCodesDoc = openapi.Operation{
+		Summary:     "Replace the caller's recovery codes",
+		Description: "Needs a two-factor code. The previous recovery codes stop working.",
+		Tag:         "Two-factor",
+		Params:      TwoFactorUserParams{},
+		Response:    service.RecoveryCodes{},
+	}
+	DisableTwoFactorDoc = openapi.Operation{
+		Summary:     "Disable two-factor authentication",
+		Description: "Needs a two-factor code. The caller's secret and recovery codes are discarded.",
+		Tag:         "Two-factor",
+		Params:      TwoFactorUserParams{},
+		Response:    MessageResponse{},
+	}
+)
+
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, balanceService *service.BalanceService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		balanceService:   balanceService,
+	}
+}
+
+func (h *TwoFactorHandler) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	status, err := h.twoFactorService.Status(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *TwoFactorHandler) EnrolTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	enrolment, err := h.twoFactorService.Enrol(r.Context(), userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(enrolment)
+}
+
+func (h *TwoFactorHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var requestData service.ConfirmTwoFactorRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmEnrolment(r.Context(), userId, requestData.Code)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *TwoFactorHandler) ResetRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	codes, err := h.twoFactorService.ResetRecoveryCodes(r.Context(), userId, r.Header.Get("X-TOTP-Code"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *TwoFactorHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	err = h.twoFactorService.Disable(r.Context(), userId, r.Header.Get("X-TOTP-Code"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Two-factor authentication disabled"})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type UserHandler struct {
+	userService    *service.UserService
+	balanceService *service.BalanceService
+}
+
+// UserLookupParams finds a user by exactly one of username or email.
+type UserLookupParams struct {
+	UserParams
+	Username string `query:"username" doc:"Exact username"`
+	Email    string `query:"email" doc:"Exact email address"`
+}
+
+var (
+	RegisterUserDoc = openapi.Operation{
+		Summary:     "Register a user",
+		Description: "Needs no userId. New users have the user role, an active status and no balances. Usernames and emails must be unique.",
+		Tag:         "Users",
+		Params:      IdempotencyParams{},
+		Body:        service.RegisterUserRequest{},
+		Response:    model.User{},
+		Status:      http.StatusCreated,
+		Responses: map[int]openapi.Response{
+			http.StatusTooManyRequests: RateLimitedResponse,
+		},
+	}
+	GetUserDoc = openapi.Operation{
+		Summary:     "Get a user's account and profile",
+		Description: "Users can get their own account; admins and compliance users anyone's.",
+		Tag:         "Users",
+		Params:      IDParams{},
+		Response:    model.User{},
+	}
+	LookupUserDoc = openapi.Operation{
+		Summary:     "Find a user by username or email",
+		Description: "Admins and compliance only. Deleted users are not found.",
+		Tag:         "Users",
+		Params:      UserLookupParams{},
+		Response:    model.User{},
+	}
+	UpdateUserDoc = openapi.Operation{
+		Summary:     "Update a user's profile",
+		Description: "Users can update their own profile; admins anyone's. Fields left out keep their value.",
+		Tag:         "Users",
+		Params:      IDParams{},
+		Body:        service.UpdateProfileRequest{},
+		Response:    model.User{},
+	}
+	DeleteUserDoc = openapi.Operation{
+		Summary:     "Delete a user's account",
+		Description: "Users can delete their own account; admins anyone's. The account must not hold any balance (account_not_empty). Its history is kept, its schedules are paused and its alerts and webhooks deactivated, and it can no longer sign in.",
+		Tag:         "Users",
+		Params:      IDParams{},
+		Response:    MessageResponse{},
+	}
+	SetUserStatusDoc = openapi.Operation{
+		Summary:     "Change a user's account status",
+		Description: "Admins only. Frozen and withdraw-only users cannot quote or exchange; closed users cannot sign in. The reason is kept in the user's status history.",
+		Tag:         "Admin",
+		Params:      IDParams{},
+		Body:        service.SetUserStatusRequest{},
+		Response:    model.UserStatusChange{},
+	}
+	GetUserStatusHistoryDoc = openapi.Operation{
+		Summary:     "List a user's status changes",
+		Description: "Admins and compliance only. Newest first.",
+		Tag:         "Admin",
+		Params:      IDParams{},
+		Response:    []model.UserStatusChange{},
+	}
+)
+
+func NewUserHandler(userService *service.UserService, balanceService *service.BalanceService) *UserHandler {
+	return &UserHandler{
+		userService:    userService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	var requestData service.RegisterUserRequest
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.Register(r.Context(), requestData)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	callerId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid user ID")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), callerId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) LookupUser(w http.ResponseWriter, r *http.Request) {
+	callerId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params UserLookupParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, err.Error())
+		return
+	}
+
+	user, err := h.userService.LookupUser(r.Context(), callerId, params.Username, params.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	callerId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid user ID")
+		return
+	}
+
+	var requestData service.UpdateProfileRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), callerId, params.ID, requestData)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	callerId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid user ID")
+		return
+	}
+
+	err = h.userService.DeleteUser(r.Context(), callerId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Message: "User deleted",
+	})
+}
+
+func (h *UserHandler) SetUserStatus(w http.ResponseWriter, r *http.Request) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid user ID")
+		return
+	}
+
+	var requestData service.SetUserStatusRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	change, err := h.userService.SetStatus(r.Context(), adminId, params.ID, requestData)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+func (h *UserHandler) GetUserStatusHistory(w http.ResponseWriter, r *http.Request) {
+	adminId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid user ID")
+		return
+	}
+
+	history, err := h.userService.GetStatusHistory(r.Context(), adminId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
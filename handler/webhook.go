@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
+	"swap-wallet/service"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+	balanceService *service.BalanceService
+}
+
+type DeliveryParams struct {
+	IDParams
+	DeliveryID int `path:"deliveryId"`
+}
+
+var (
+	CreateWebhookDoc = openapi.Operation{
+		Summary:     "Register a webhook endpoint",
+		Description: "Needs an X-TOTP-Code, so the caller must have two-factor authentication enabled. The response carries the endpoint's signing secret, which is not shown again.",
+		Tag:         "Webhooks",
+		Params:      IdempotentTwoFactorParams{},
+		Body:        service.CreateWebhookRequest{},
+		Response:    model.WebhookEndpoint{},
+		Status:      http.StatusCreated,
+		Responses: map[int]openapi.Response{
+			http.StatusForbidden: TwoFactorRejectedResponse,
+		},
+	}
+	GetWebhooksDoc = openapi.Operation{
+		Summary:  "List webhook endpoints",
+		Tag:      "Webhooks",
+		Params:   UserParams{},
+		Response: []model.WebhookEndpoint{},
+	}
+	DeleteWebhookDoc = openapi.Operation{
+		Summary:  "Delete a webhook endpoint",
+		Tag:      "Webhooks",
+		Params:   IDParams{},
+		Response: MessageResponse{},
+	}
+	GetDeliveriesDoc = openapi.Operation{
+		Summary:  "List recent deliveries to a webhook endpoint",
+		Tag:      "Webhooks",
+		Params:   IDParams{},
+		Response: []model.WebhookDelivery{},
+	}
+	RedeliverDoc = openapi.Operation{
+		Summary:  "Queue a webhook delivery again",
+		Tag:      "Webhooks",
+		Params:   DeliveryParams{},
+		Response: model.WebhookDelivery{},
+	}
+)
+
+func NewWebhookHandler(webhookService *service.WebhookService, balanceService *service.BalanceService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		balanceService: balanceService,
+	}
+}
+
+func (h *WebhookHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var requestData service.CreateWebhookRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeInvalidRequest(w, r, "Invalid request body")
+		return
+	}
+
+	endpoint, err := h.webhookService.CreateEndpoint(r.Context(), userId, requestData, r.Header.Get("X-TOTP-Code"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+func (h *WebhookHandler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	endpoints, err := h.webhookService.GetUserEndpoints(userId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+func (h *WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid webhook ID")
+		return
+	}
+
+	err = h.webhookService.DeleteEndpoint(userId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{
+		Message: "Webhook deleted",
+	})
+}
+
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params IDParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid webhook ID")
+		return
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(userId, params.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	userId, err := checkUserExists(r.Context(), h.balanceService, r.Header.Get("userId"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+
+	var params DeliveryParams
+	if err := openapi.DecodeParams(r, &params); err != nil {
+		writeInvalidRequest(w, r, "Invalid webhook or delivery ID")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(userId, params.ID, params.DeliveryID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
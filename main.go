@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"swap-wallet/config"
+	"swap-wallet/grpcapi"
 	handlers "swap-wallet/handler"
+	"swap-wallet/middleware"
+	"swap-wallet/model"
+	"swap-wallet/openapi"
 	"swap-wallet/repository"
 	"swap-wallet/service"
 	"swap-wallet/util"
@@ -29,14 +35,120 @@ func main() {
 	balanceRepo := repository.NewBalanceRepository(db)
 	cryptoRepo := repository.NewCryptocurrencyRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	balanceService := service.NewBalanceService(balanceRepo, cryptoRepo, userRepo, redisClient)
+	positionRepo := repository.NewPositionRepository(db)
+	historyRepo := repository.NewHistoryRepository(db)
+	priceProvider := service.NewPriceProvider(historyRepo)
+	go priceProvider.RunRecorder(context.Background())
+	ledgerRepo := repository.NewLedgerRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	auditService := service.NewAuditService(auditRepo, userRepo, ledgerRepo, cryptoRepo)
+	util.CheckErr(auditService.RecordSettings(context.Background(), config.AuditedSettings()))
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo, priceProvider, auditService)
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookService := service.NewWebhookService(webhookRepo, twoFactorService)
+	go webhookService.RunDeliveries(context.Background())
+	outboxRepo := repository.NewOutboxRepository(db)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, service.NewRedisStreamBroker(redisClient))
+	go outboxRelay.RunRelay(context.Background())
+	eventService := service.NewEventService(outboxRepo, ledgerRepo, cryptoRepo, webhookService)
+	balanceService := service.NewBalanceService(balanceRepo, cryptoRepo, userRepo, positionRepo, priceProvider, eventService, auditService, twoFactorService, redisClient)
+	auditHandler := handlers.NewAuditHandler(auditService, balanceService)
 	balanceHandler := handlers.NewBalanceHandler(balanceService)
+	pnlService := service.NewPnLService(positionRepo, balanceService)
+	pnlHandler := handlers.NewPnLHandler(pnlService, balanceService)
+
+	historyService := service.NewHistoryService(historyRepo, cryptoRepo, priceProvider)
+	portfolioHandler := handlers.NewPortfolioHandler(historyService, balanceService)
+	priceHandler := handlers.NewPriceHandler(historyService)
+	go historyService.RunSnapshots(context.Background())
+
+	statementService := service.NewStatementService(ledgerRepo, balanceRepo, cryptoRepo, userRepo)
+	statementHandler := handlers.NewStatementHandler(statementService, balanceService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, balanceService)
+
+	notifiers := map[string]service.Notifier{
+		model.ChannelWebhook: service.NewWebhookNotifier(),
+	}
+	if cfg.SMTPHost != "" {
+		notifiers[model.ChannelEmail] = service.NewEmailNotifier(cfg)
+	}
+	alertRepo := repository.NewAlertRepository(db)
+	alertService := service.NewAlertService(alertRepo, priceProvider, notifiers)
+	alertHandler := handlers.NewAlertHandler(alertService, balanceService)
+	go alertService.RunEvaluator(context.Background())
+
+	scheduleRepo := repository.NewScheduleRepository(db)
+	scheduleService := service.NewScheduleService(scheduleRepo, balanceService)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService, balanceService)
+	go scheduleService.RunScheduler(context.Background())
+
+	streamHub := service.NewStreamHub(priceProvider, redisClient)
+	streamHandler := handlers.NewStreamHandler(streamHub, balanceService)
+	go streamHub.RunPrices(context.Background())
+	go streamHub.RunBalances(context.Background())
+
+	adjustmentService := service.NewAdjustmentService(balanceRepo, cryptoRepo, userRepo, positionRepo, priceProvider, eventService, auditService, twoFactorService)
+	adjustmentHandler := handlers.NewAdjustmentHandler(adjustmentService, balanceService)
+
+	userService := service.NewUserService(userRepo, cryptoRepo, auditService)
+	userHandler := handlers.NewUserHandler(userService, balanceService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, balanceService)
+
+	rateLimiter := service.NewRateLimiter(redisClient)
+
+	grpcListener, err := net.Listen("tcp", ":"+config.LoadGRPCPort())
+	util.CheckErr(err)
+	go grpcapi.NewServer(balanceService, rateLimiter).Serve(grpcListener)
+
+	idempotencyService := service.NewIdempotencyService(redisClient)
 
 	router := mux.NewRouter()
-	router.HandleFunc("/balance", balanceHandler.GetUserBalance).Methods("GET")
-	router.HandleFunc("/balances", balanceHandler.GetAllUserBalances).Methods("GET")
-	router.HandleFunc("/exchange/preview", balanceHandler.GetExchangePreviewHandler).Methods("GET")
-	router.HandleFunc("/exchange/apply", balanceHandler.FinalizeExchangeHandler).Methods("POST")
-	http.ListenAndServe(":8080", router)
+	api := openapi.New(router, "Swap Wallet API", "1.0.0")
+	api.HandleFunc("GET", "/balance", handlers.RateLimited(rateLimiter, config.RateLimitBalances, balanceHandler.GetUserBalance), handlers.GetUserBalanceDoc)
+	api.HandleFunc("GET", "/balances", handlers.RateLimited(rateLimiter, config.RateLimitBalances, balanceHandler.GetAllUserBalances), handlers.GetAllUserBalancesDoc)
+	api.HandleFunc("GET", "/portfolio", handlers.RateLimited(rateLimiter, config.RateLimitBalances, balanceHandler.GetPortfolio), handlers.GetPortfolioDoc)
+	api.HandleFunc("GET", "/exchange/preview", handlers.RateLimited(rateLimiter, config.RateLimitPreview, balanceHandler.GetExchangePreviewHandler), handlers.GetExchangePreviewDoc)
+	api.HandleFunc("GET", "/exchange/quotes/stream", handlers.RateLimited(rateLimiter, config.RateLimitQuoteStream, balanceHandler.StreamQuotesHandler), handlers.StreamQuotesDoc)
+	api.HandleFunc("POST", "/exchange/apply", handlers.RateLimited(rateLimiter, config.RateLimitExchange, handlers.Idempotent(idempotencyService, balanceHandler.FinalizeExchangeHandler)), handlers.FinalizeExchangeDoc)
+	api.HandleFunc("GET", "/stream", handlers.RateLimited(rateLimiter, config.RateLimitStream, streamHandler.Stream), handlers.StreamDoc)
+	api.HandleFunc("GET", "/alerts", alertHandler.GetAlerts, handlers.GetAlertsDoc)
+	api.HandleFunc("POST", "/alerts", handlers.Idempotent(idempotencyService, alertHandler.CreateAlert), handlers.CreateAlertDoc)
+	api.HandleFunc("DELETE", "/alerts/{id}", alertHandler.DeleteAlert, handlers.DeleteAlertDoc)
+	api.HandleFunc("GET", "/webhooks", webhookHandler.GetEndpoints, handlers.GetWebhooksDoc)
+	api.HandleFunc("POST", "/webhooks", handlers.Idempotent(idempotencyService, webhookHandler.CreateEndpoint), handlers.CreateWebhookDoc)
+	api.HandleFunc("DELETE", "/webhooks/{id}", webhookHandler.DeleteEndpoint, handlers.DeleteWebhookDoc)
+	api.HandleFunc("GET", "/webhooks/{id}/deliveries", webhookHandler.GetDeliveries, handlers.GetDeliveriesDoc)
+	api.HandleFunc("POST", "/webhooks/{id}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver, handlers.RedeliverDoc)
+	api.HandleFunc("GET", "/pnl", pnlHandler.GetUserPnL, handlers.GetUserPnLDoc)
+	api.HandleFunc("GET", "/portfolio/history", portfolioHandler.GetPortfolioHistory, handlers.GetPortfolioHistoryDoc)
+	api.HandleFunc("GET", "/prices/{symbol}/candles", priceHandler.GetCandles, handlers.GetCandlesDoc)
+	api.HandleFunc("GET", "/statements", statementHandler.GetStatement, handlers.GetStatementDoc)
+	api.HandleFunc("GET", "/schedules", scheduleHandler.GetSchedules, handlers.GetSchedulesDoc)
+	api.HandleFunc("POST", "/schedules", handlers.Idempotent(idempotencyService, scheduleHandler.CreateSchedule), handlers.CreateScheduleDoc)
+	api.HandleFunc("POST", "/schedules/{id}/pause", scheduleHandler.PauseSchedule, handlers.PauseScheduleDoc)
+	api.HandleFunc("POST", "/schedules/{id}/resume", scheduleHandler.ResumeSchedule, handlers.ResumeScheduleDoc)
+	api.HandleFunc("DELETE", "/schedules/{id}", scheduleHandler.DeleteSchedule, handlers.DeleteScheduleDoc)
+	api.HandleFunc("GET", "/admin/adjustments", adjustmentHandler.GetAdjustments, handlers.GetAdjustmentsDoc)
+	api.HandleFunc("POST", "/admin/adjustments", handlers.Idempotent(idempotencyService, adjustmentHandler.CreateAdjustment), handlers.CreateAdjustmentDoc)
+	api.HandleFunc("GET", "/admin/adjustments/{id}", adjustmentHandler.GetAdjustment, handlers.GetAdjustmentDoc)
+	api.HandleFunc("POST", "/admin/adjustments/{id}/approve", adjustmentHandler.ApproveAdjustment, handlers.ApproveAdjustmentDoc)
+	api.HandleFunc("POST", "/admin/adjustments/{id}/reject", adjustmentHandler.RejectAdjustment, handlers.RejectAdjustmentDoc)
+	api.HandleFunc("POST", "/users", handlers.RateLimited(rateLimiter, config.RateLimitRegister, handlers.Idempotent(idempotencyService, userHandler.RegisterUser)), handlers.RegisterUserDoc)
+	api.HandleFunc("GET", "/users/lookup", userHandler.LookupUser, handlers.LookupUserDoc)
+	api.HandleFunc("GET", "/users/{id}", userHandler.GetUser, handlers.GetUserDoc)
+	api.HandleFunc("PATCH", "/users/{id}", userHandler.UpdateUser, handlers.UpdateUserDoc)
+	api.HandleFunc("DELETE", "/users/{id}", userHandler.DeleteUser, handlers.DeleteUserDoc)
+	api.HandleFunc("POST", "/admin/users/{id}/status", userHandler.SetUserStatus, handlers.SetUserStatusDoc)
+	api.HandleFunc("GET", "/admin/users/{id}/status-history", userHandler.GetUserStatusHistory, handlers.GetUserStatusHistoryDoc)
+	api.HandleFunc("GET", "/2fa", twoFactorHandler.GetTwoFactor, handlers.GetTwoFactorDoc)
+	api.HandleFunc("POST", "/2fa/enrol", twoFactorHandler.EnrolTwoFactor, handlers.EnrolTwoFactorDoc)
+	api.HandleFunc("POST", "/2fa/confirm", twoFactorHandler.ConfirmTwoFactor, handlers.ConfirmTwoFactorDoc)
+	api.HandleFunc("POST", "/2fa/recovery-codes", twoFactorHandler.ResetRecoveryCodes, handlers.ResetRecoveryCodesDoc)
+	api.HandleFunc("POST", "/2fa/disable", twoFactorHandler.DisableTwoFactor, handlers.DisableTwoFactorDoc)
+	api.HandleFunc("GET", "/audit", auditHandler.GetAuditLog, handlers.GetAuditLogDoc)
+	api.HandleFunc("GET", "/audit/verify", auditHandler.VerifyAuditLog, handlers.VerifyAuditLogDoc)
+	router.HandleFunc("/openapi.json", api.ServeSpec).Methods("GET")
+	http.ListenAndServe(":8080", middleware.RequestID(middleware.ClientIP(router)))
 
 }
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type clientIPKey struct{}
+
+// ClientIP stores the address the request came from, for the audit log. It
+// uses the connection's remote address; the service is not deployed behind a
+// proxy, so forwarding headers are not trusted.
+func ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), r.RemoteAddr)))
+	})
+}
+
+// WithClientIP returns ctx carrying the host part of addr as the client IP.
+func WithClientIP(ctx context.Context, addr string) context.Context {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return context.WithValue(ctx, clientIPKey{}, host)
+}
+
+// ClientIPFrom returns the IP ClientIP stored in ctx, or "" when there is
+// none.
+func ClientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader carries the id of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// validRequestID limits ids accepted from clients so they are safe to log
+// and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every request with an id, reusing a valid X-Request-ID sent
+// by the client or generating one, and returns it in the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithRequestID(r.Context(), r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, RequestIDFrom(ctx))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithRequestID returns ctx carrying id as the request id, or a generated one
+// when id is not valid.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the id RequestID stored in ctx, or "" when there is
+// none.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
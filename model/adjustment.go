@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	AdjustmentPending  = "pending"
+	AdjustmentApplied  = "applied"
+	AdjustmentRejected = "rejected"
+)
+
+// AdjustmentReasons are the reason codes an admin can give for a manual
+// balance adjustment.
+var AdjustmentReasons = []string{
+	"deposit_correction",
+	"withdrawal_correction",
+	"fee_refund",
+	"goodwill",
+	"chargeback",
+	"migration",
+}
+
+// BalanceAdjustment is a manual credit (positive Amount) or debit (negative
+// Amount) of a user's balance requested by an admin. Adjustments above the
+// approval threshold stay pending until a second admin decides on them.
+type BalanceAdjustment struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	CryptoSymbol  string     `json:"crypto_symbol"`
+	Amount        float64    `json:"amount"`
+	ReasonCode    string     `json:"reason_code"`
+	Justification string     `json:"justification"`
+	Status        string     `json:"status"`
+	RequestedBy   int        `json:"requested_by"`
+	DecidedBy     *int       `json:"decided_by"`
+	DecisionNote  string     `json:"decision_note,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
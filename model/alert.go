@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+const (
+	AlertAbove         = "above"
+	AlertBelow         = "below"
+	AlertPercentChange = "percent_change"
+
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// PriceAlert fires when the price of CryptoSymbol in QuoteSymbol crosses
+// Threshold, or for percent_change alerts when it moves Threshold percent away
+// from ReferencePrice, which is reset to the current price on every trigger.
+type PriceAlert struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	CryptoSymbol    string     `json:"crypto_symbol"`
+	QuoteSymbol     string     `json:"quote_symbol"`
+	Condition       string     `json:"condition"`
+	Threshold       float64    `json:"threshold"`
+	ReferencePrice  float64    `json:"reference_price"`
+	Channel         string     `json:"channel"`
+	Target          string     `json:"target"`
+	CooldownSeconds int        `json:"cooldown_seconds"`
+	IsActive        bool       `json:"is_active"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
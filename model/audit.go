@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditAuthSucceeded       = "auth.succeeded"
+	AuditAuthFailed          = "auth.failed"
+	AuditAuthDenied          = "auth.denied"
+	AuditQuoteCreated        = "quote.created"
+	AuditExchangeCompleted   = "exchange.completed"
+	AuditAdjustmentRequested = "adjustment.requested"
+	AuditAdjustmentApproved  = "adjustment.approved"
+	AuditAdjustmentRejected  = "adjustment.rejected"
+	AuditConfigChanged       = "config.changed"
+	AuditUserStatusChanged   = "user.status_changed"
+	AuditUserRegistered      = "user.registered"
+	AuditUserProfileUpdated  = "user.profile_updated"
+	AuditUserDeleted         = "user.deleted"
+	AuditTwoFactorEnabled    = "two_factor.enabled"
+	AuditTwoFactorDisabled   = "two_factor.disabled"
+	AuditRecoveryCodesReset  = "two_factor.recovery_codes_reset"
+	AuditTwoFactorFailed     = "two_factor.failed"
+)
+
+// AuditEntry records who did what to which record, with the record's state
+// before and after as JSON. Hash covers every other field and PrevHash, the
+// hash of the entry before it, so editing or removing an entry breaks the
+// chain from there on.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Action     string          `json:"action"`
+	ActorID    *int            `json:"actor_id"`
+	ActorIP    string          `json:"actor_ip"`
+	RequestID  string          `json:"request_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+// FirstInvalidID is the first entry whose hash does not match, if any.
+type AuditVerification struct {
+	Valid          bool   `json:"valid"`
+	Entries        int    `json:"entries"`
+	LastHash       string `json:"last_hash"`
+	FirstInvalidID *int64 `json:"first_invalid_id,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
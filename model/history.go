@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type BalanceSnapshot struct {
+	UserID       int       `json:"user_id"`
+	CryptoSymbol string    `json:"crypto_symbol"`
+	Balance      int64     `json:"balance"`
+	Scale        int       `json:"scale"`
+	TakenAt      time.Time `json:"taken_at"`
+}
+
+type PricePoint struct {
+	CryptoSymbol string    `json:"crypto_symbol"`
+	QuoteSymbol  string    `json:"quote_symbol"`
+	Price        float64   `json:"price"`
+	ObservedAt   time.Time `json:"observed_at"`
+}
+
+type Candle struct {
+	CryptoSymbol string    `json:"crypto_symbol"`
+	QuoteSymbol  string    `json:"quote_symbol"`
+	Resolution   string    `json:"resolution"`
+	BucketStart  time.Time `json:"time"`
+	Open         float64   `json:"open"`
+	High         float64   `json:"high"`
+	Low          float64   `json:"low"`
+	Close        float64   `json:"close"`
+	Samples      int       `json:"samples"`
+}
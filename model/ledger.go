@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	LedgerExchange   = "exchange"
+	LedgerFee        = "fee"
+	LedgerDeposit    = "deposit"
+	LedgerWithdrawal = "withdrawal"
+	LedgerAdjustment = "adjustment"
+)
+
+// Exchange is a completed conversion. Fee is expressed in the target asset.
+type Exchange struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	SourceCrypto string    `json:"source_crypto"`
+	TargetCrypto string    `json:"target_crypto"`
+	SourceAmount float64   `json:"source_amount"`
+	TargetAmount float64   `json:"target_amount"`
+	Fee          float64   `json:"fee"`
+	Rate         float64   `json:"rate"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// LedgerEntry is a single signed change to a balance, in the asset's smallest
+// unit, together with the balance it left behind.
+type LedgerEntry struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	CryptoSymbol string    `json:"crypto_symbol"`
+	Kind         string    `json:"kind"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balance_after"`
+	ExchangeID   *int      `json:"exchange_id"`
+	AdjustmentID *int      `json:"adjustment_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
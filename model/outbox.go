@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes and published to the broker afterwards. Payload is the JSON
+// event envelope.
+type OutboxEvent struct {
+	ID          int64      `json:"id"`
+	EventID     string     `json:"event_id"`
+	EventType   string     `json:"event_type"`
+	UserID      int        `json:"user_id"`
+	Payload     string     `json:"payload"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at"`
+}
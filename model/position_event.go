@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	PositionAcquisition = "acquisition"
+	PositionDisposal    = "disposal"
+
+	PositionSourceAdjustment = "adjustment"
+	PositionSourceExchange   = "exchange"
+)
+
+// PositionEvent records an asset entering or leaving a user's holdings
+// together with its USD value at that moment, from which cost basis lots are
+// derived.
+type PositionEvent struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	CryptoSymbol string    `json:"crypto_symbol"`
+	Kind         string    `json:"kind"`
+	Amount       float64   `json:"amount"`
+	USDValue     float64   `json:"usd_value"`
+	Source       string    `json:"source"`
+	CreatedAt    time.Time `json:"created_at"`
+}
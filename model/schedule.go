@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	ScheduleStatusActive = "active"
+	ScheduleStatusPaused = "paused"
+
+	RecurrenceInterval = "interval"
+	RecurrenceDaily    = "daily"
+	RecurrenceWeekly   = "weekly"
+)
+
+type Schedule struct {
+	ID                  int        `json:"id"`
+	UserID              int        `json:"user_id"`
+	SourceCrypto        string     `json:"source_crypto"`
+	TargetCrypto        string     `json:"target_crypto"`
+	SourceAmount        float64    `json:"source_amount"`
+	Recurrence          string     `json:"recurrence"`
+	IntervalSeconds     int        `json:"interval_seconds"`
+	Weekday             int        `json:"weekday"`
+	Hour                int        `json:"hour"`
+	Minute              int        `json:"minute"`
+	Status              string     `json:"status"`
+	NextRunAt           time.Time  `json:"next_run_at"`
+	LastRunAt           *time.Time `json:"last_run_at"`
+	LastResult          string     `json:"last_result"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	TwoFactorApprovedAt *time.Time `json:"two_factor_approved_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+type TradingPair struct {
+	ID            int      `json:"id"`
+	BaseCryptoID  int      `json:"base_crypto_id"`
+	QuoteCryptoID int      `json:"quote_crypto_id"`
+	BaseSymbol    string   `json:"base_symbol"`
+	QuoteSymbol   string   `json:"quote_symbol"`
+	FeeRate       *float64 `json:"fee_rate"`
+	Spread        float64  `json:"spread"`
+	IsEnabled     bool     `json:"is_enabled"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// TwoFactor is a user's TOTP enrolment. It is pending until EnabledAt is
+// set by confirming a first code.
+type TwoFactor struct {
+	UserID         int        `json:"user_id"`
+	Secret         string     `json:"-"`
+	EnabledAt      *time.Time `json:"enabled_at"`
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
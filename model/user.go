@@ -1,6 +1,51 @@
 package model
 
+import "time"
+
+const (
+	RoleUser       = "user"
+	RoleAdmin      = "admin"
+	RoleCompliance = "compliance"
+
+	// An active user can do everything. Frozen and withdraw-only users can
+	// still sign in and read their accounts but cannot quote or exchange;
+	// withdraw-only users will be allowed to withdraw. Closed users cannot
+	// sign in at all.
+	UserActive       = "active"
+	UserFrozen       = "frozen"
+	UserWithdrawOnly = "withdraw_only"
+	UserClosed       = "closed"
+)
+
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+	ID            int        `json:"id"`
+	Username      string     `json:"username"`
+	Role          string     `json:"role,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	DisplayName   string     `json:"display_name,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	QuoteCurrency string     `json:"quote_currency,omitempty"`
+	Locale        string     `json:"locale,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserProfile holds the fields of a user that the user can change.
+type UserProfile struct {
+	DisplayName   string
+	Email         string
+	QuoteCurrency string
+	Locale        string
+}
+
+// UserStatusChange records an admin changing a user's status and why.
+type UserStatusChange struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ChangedBy  int       `json:"changed_by"`
+	CreatedAt  time.Time `json:"created_at"`
 }
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+const (
+	EventExchangeCompleted = "exchange.completed"
+	EventBalanceChanged    = "balance.changed"
+	EventSchedulePaused    = "schedule.paused"
+
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEndpoint receives the user's events of EventTypes. Secret signs every
+// payload and is only returned when the endpoint is created.
+type WebhookEndpoint struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one endpoint. Pending deliveries are
+// attempted once NextAttemptAt has passed.
+type WebhookDelivery struct {
+	ID               int        `json:"id"`
+	EndpointID       int        `json:"endpoint_id"`
+	EventID          string     `json:"event_id"`
+	EventType        string     `json:"event_type"`
+	Payload          string     `json:"payload"`
+	Status           string     `json:"status"`
+	Attempts         int        `json:"attempts"`
+	NextAttemptAt    time.Time  `json:"next_attempt_at"`
+	LastResponseCode *int       `json:"last_response_code"`
+	LastError        string     `json:"last_error"`
+	DeliveredAt      *time.Time `json:"delivered_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
@@ -0,0 +1,271 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document generated
+// from the typed params, body and response structs of each route, and
+// validates incoming requests against the same description.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"swap-wallet/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+const maxBodyBytes = 1 << 20
+
+// Operation documents one route. Params is a struct whose fields carry
+// query, path or header tags; Body and Response are values of the request and
+// response body types. Status defaults to 200 and ContentType to
+// application/json.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Params      interface{}
+	Body        interface{}
+	Response    interface{}
+	Status      int
+	ContentType string
+	// Responses documents additional outcomes by status code.
+	Responses map[int]Response
+}
+
+type Response struct {
+	Description string
+	Body        interface{}
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      string       `json:"code" required:"true" doc:"Stable identifier of the error, for example insufficient_funds"`
+	Message   string       `json:"message" required:"true" doc:"Human readable description; may change between releases"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty" doc:"Id of the request, also returned in the X-Request-ID header"`
+}
+
+type Document struct {
+	OpenAPI    string                        `json:"openapi"`
+	Info       Info                          `json:"info"`
+	Paths      map[string]map[string]*pathOp `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type pathOp struct {
+	Summary     string                  `json:"summary,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	OperationID string                  `json:"operationId"`
+	Parameters  []Parameter             `json:"parameters,omitempty"`
+	RequestBody *requestBody            `json:"requestBody,omitempty"`
+	Responses   map[string]responseBody `json:"responses"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type responseBody struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// API registers routes on a router together with their description.
+type API struct {
+	router    *mux.Router
+	generator *generator
+	document  Document
+}
+
+func New(router *mux.Router, title, version string) *API {
+	api := &API{
+		router:    router,
+		generator: newGenerator(),
+		document: Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]map[string]*pathOp{},
+		},
+	}
+	api.document.Components.Schemas = api.generator.components
+	return api
+}
+
+// HandleFunc registers handler for method and path behind a middleware that
+// validates the request against op, and adds op to the document.
+func (a *API) HandleFunc(method, path string, handler http.HandlerFunc, op Operation) *mux.Route {
+	var params []paramField
+	if op.Params != nil {
+		params = a.generator.paramFields(indirect(reflect.TypeOf(op.Params)))
+	}
+	var bodySchema *Schema
+	if op.Body != nil {
+		bodySchema = a.generator.schemaFor(reflect.TypeOf(op.Body))
+	}
+
+	a.describe(method, path, op, params, bodySchema)
+
+	validated := func(w http.ResponseWriter, r *http.Request) {
+		errs := validateParams(r, params)
+		if bodySchema != nil {
+			errs = append(errs, a.validateBody(r, bodySchema)...)
+		}
+		if len(errs) > 0 {
+			WriteError(w, r, http.StatusBadRequest, "invalid_request", "Request validation failed", errs)
+			return
+		}
+		handler(w, r)
+	}
+	return a.router.HandleFunc(path, validated).Methods(method)
+}
+
+func validateParams(r *http.Request, params []paramField) []FieldError {
+	var errs []FieldError
+	for _, field := range params {
+		value, ok := paramValue(r, field.parameter)
+		if !ok {
+			if field.parameter.Required {
+				errs = append(errs, FieldError{Field: field.parameter.Name, Message: "is required"})
+			}
+			continue
+		}
+		if err := validateParam(value, field.parameter.Schema); err != nil {
+			errs = append(errs, FieldError{Field: field.parameter.Name, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// validateBody checks the JSON body against schema and leaves it readable
+// for the handler.
+func (a *API) validateBody(r *http.Request, schema *Schema) []FieldError {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	r.Body.Close()
+	if err != nil || len(body) > maxBodyBytes {
+		return []FieldError{{Field: "body", Message: "could not be read or is too large"}}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []FieldError{{Field: "body", Message: "must be valid JSON"}}
+	}
+
+	var errs []FieldError
+	a.generator.validateValue(schema, value, "body", &errs)
+	return errs
+}
+
+// WriteError writes an ErrorResponse with the given status, tagged with the
+// id of request r.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string, details []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: middleware.RequestIDFrom(r.Context()),
+	}})
+}
+
+func (a *API) describe(method, path string, op Operation, params []paramField, bodySchema *Schema) {
+	described := &pathOp{
+		Summary:     op.Summary,
+		Description: op.Description,
+		OperationID: operationID(method, path),
+		Responses:   map[string]responseBody{},
+	}
+	if op.Tag != "" {
+		described.Tags = []string{op.Tag}
+	}
+	for _, field := range params {
+		described.Parameters = append(described.Parameters, field.parameter)
+	}
+	if bodySchema != nil {
+		described.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]mediaType{"application/json": {Schema: bodySchema}},
+		}
+	}
+
+	status, contentType := op.Status, op.ContentType
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	success := responseBody{Description: http.StatusText(status)}
+	if op.Response != nil || contentType != "application/json" {
+		schema := &Schema{Type: "string", Format: "binary"}
+		if op.Response != nil {
+			schema = a.generator.schemaFor(reflect.TypeOf(op.Response))
+		}
+		success.Content = map[string]mediaType{contentType: {Schema: schema}}
+	}
+	described.Responses[strconv.Itoa(status)] = success
+
+	errorSchema := a.generator.schemaFor(reflect.TypeOf(ErrorResponse{}))
+	described.Responses["400"] = responseBody{
+		Description: "Invalid request",
+		Content:     map[string]mediaType{"application/json": {Schema: errorSchema}},
+	}
+	described.Responses["default"] = responseBody{
+		Description: "Error",
+		Content:     map[string]mediaType{"application/json": {Schema: errorSchema}},
+	}
+	for code, response := range op.Responses {
+		documented := responseBody{Description: response.Description}
+		if response.Body != nil {
+			documented.Content = map[string]mediaType{
+				"application/json": {Schema: a.generator.schemaFor(reflect.TypeOf(response.Body))},
+			}
+		}
+		described.Responses[strconv.Itoa(code)] = documented
+	}
+
+	if a.document.Paths[path] == nil {
+		a.document.Paths[path] = map[string]*pathOp{}
+	}
+	a.document.Paths[path][strings.ToLower(method)] = described
+}
+
+// operationID derives an id such as getWebhooksIdDeliveries from the method
+// and path.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, "{}")
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// ServeSpec serves the OpenAPI document as JSON.
+func (a *API) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.document)
+}
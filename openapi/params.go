@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Parameter is an OpenAPI parameter object.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// paramLocations are the struct tags that place a field in the query string,
+// the path or a header, checked in this order.
+var paramLocations = []string{"query", "path", "header"}
+
+type paramField struct {
+	index     []int
+	parameter Parameter
+}
+
+// paramFields lists the parameters declared by a params struct. Embedded
+// structs contribute their fields, so common parameters can be shared.
+func (g *generator) paramFields(t reflect.Type) []paramField {
+	var fields []paramField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			for _, embedded := range g.paramFields(indirect(field.Type)) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+
+		for _, in := range paramLocations {
+			name := field.Tag.Get(in)
+			if name == "" {
+				continue
+			}
+			schema := g.fieldSchema(field)
+			schema.Description = ""
+			fields = append(fields, paramField{
+				index: []int{i},
+				parameter: Parameter{
+					Name:        name,
+					In:          in,
+					Description: field.Tag.Get("doc"),
+					Required:    in == "path" || field.Tag.Get("required") == "true",
+					Schema:      schema,
+				},
+			})
+			break
+		}
+	}
+	return fields
+}
+
+// paramValue returns the raw value of a parameter and whether it was given.
+func paramValue(r *http.Request, parameter Parameter) (string, bool) {
+	switch parameter.In {
+	case "path":
+		value, ok := mux.Vars(r)[parameter.Name]
+		return value, ok
+	case "header":
+		value := r.Header.Get(parameter.Name)
+		return value, value != ""
+	default:
+		values, ok := r.URL.Query()[parameter.Name]
+		if !ok || len(values) == 0 || values[0] == "" {
+			return "", false
+		}
+		return values[0], true
+	}
+}
+
+// validateParam checks a raw parameter value against its schema.
+func validateParam(value string, schema *Schema) error {
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		return checkRange(float64(n), schema)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		return checkRange(n, schema)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+	case "string":
+		return checkEnum(value, schema)
+	}
+	return nil
+}
+
+// DecodeParams fills the fields of the params struct dst points to from the
+// request's query string, path and headers, as declared by their query, path
+// and header tags. Parameters that are absent leave their field untouched.
+func DecodeParams(r *http.Request, dst interface{}) error {
+	value := reflect.ValueOf(dst).Elem()
+	for _, field := range newGenerator().paramFields(value.Type()) {
+		raw, ok := paramValue(r, field.parameter)
+		if !ok {
+			continue
+		}
+		err := setValue(value.FieldByIndex(field.index), raw)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %v", field.parameter.Name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported parameter type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object the API uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// generator derives schemas from Go types. Named structs become components
+// referenced by name; the json tag gives a field's name and the required,
+// enum, minimum, maximum, format and doc tags describe it further.
+type generator struct {
+	components map[string]*Schema
+}
+
+func newGenerator() *generator {
+	return &generator{components: map[string]*Schema{}}
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			// Register before recursing so self-referencing types terminate.
+			g.components[t.Name()] = &Schema{}
+			*g.components[t.Name()] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := g.structSchema(indirect(field.Type))
+			for propName, prop := range embedded.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.fieldSchema(field)
+		if field.Tag.Get("required") == "true" {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// fieldSchema is the schema of a field's type refined by its tags.
+func (g *generator) fieldSchema(field reflect.StructField) *Schema {
+	schema := g.schemaFor(field.Type)
+	if schema.Ref != "" {
+		return schema
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		// On a list the enum constrains its items.
+		if schema.Type == "array" && schema.Items.Ref == "" {
+			schema.Items.Enum = strings.Split(enum, ",")
+		} else {
+			schema.Enum = strings.Split(enum, ",")
+		}
+	}
+	if minimum, err := strconv.ParseFloat(field.Tag.Get("minimum"), 64); err == nil {
+		schema.Minimum = &minimum
+	}
+	if maximum, err := strconv.ParseFloat(field.Tag.Get("maximum"), 64); err == nil {
+		schema.Maximum = &maximum
+	}
+	if format := field.Tag.Get("format"); format != "" {
+		schema.Format = format
+	}
+	schema.Description = field.Tag.Get("doc")
+	return schema
+}
+
+// resolve follows a component reference.
+func (g *generator) resolve(schema *Schema) *Schema {
+	if schema.Ref == "" {
+		return schema
+	}
+	return g.components[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
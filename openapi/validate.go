@@ -0,0 +1,118 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FieldError describes one invalid part of a request. Field is a parameter
+// name, or a dotted path into the body prefixed with "body".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func checkRange(n float64, schema *Schema) error {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		return fmt.Errorf("must be at least %g", *schema.Minimum)
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		return fmt.Errorf("must be at most %g", *schema.Maximum)
+	}
+	return nil
+}
+
+func checkEnum(value string, schema *Schema) error {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+	for _, allowed := range schema.Enum {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(schema.Enum, ", "))
+}
+
+// validateValue checks a decoded JSON value against schema and appends a
+// FieldError for every violation. Properties the schema does not declare are
+// allowed.
+func (g *generator) validateValue(schema *Schema, value interface{}, path string, errs *[]FieldError) {
+	schema = g.resolve(schema)
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, FieldError{Field: path + "." + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := schema.Properties[name]; ok {
+				g.validateValue(prop, object[name], path+"."+name, errs)
+			} else if schema.AdditionalProperties != nil {
+				g.validateValue(schema.AdditionalProperties, object[name], path+"."+name, errs)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			g.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if err := checkEnum(s, schema); err != nil {
+			fail("%v", err)
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be a %s", schema.Type)
+			return
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			fail("must be an integer")
+			return
+		}
+		if err := checkRange(n, schema); err != nil {
+			fail("%v", err)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be true or false")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+)
+
+// AdjustmentFilter narrows the adjustments listed. Zero values match
+// everything.
+type AdjustmentFilter struct {
+	Status string
+	UserID int
+	Limit  int
+}
+
+const adjustmentColumns = `id, user_id, crypto_symbol, amount, reason_code, justification, status,
+		requested_by, decided_by, decision_note, decided_at, created_at`
+
+func scanAdjustment(row interface{ Scan(...interface{}) error }) (model.BalanceAdjustment, error) {
+	var adjustment model.BalanceAdjustment
+	var decidedBy sql.NullInt64
+	var decidedAt sql.NullTime
+	err := row.Scan(
+		&adjustment.ID,
+		&adjustment.UserID,
+		&adjustment.CryptoSymbol,
+		&adjustment.Amount,
+		&adjustment.ReasonCode,
+		&adjustment.Justification,
+		&adjustment.Status,
+		&adjustment.RequestedBy,
+		&decidedBy,
+		&adjustment.DecisionNote,
+		&decidedAt,
+		&adjustment.CreatedAt,
+	)
+	if err != nil {
+		return adjustment, err
+	}
+	if decidedBy.Valid {
+		id := int(decidedBy.Int64)
+		adjustment.DecidedBy = &id
+	}
+	if decidedAt.Valid {
+		adjustment.DecidedAt = &decidedAt.Time
+	}
+	return adjustment, nil
+}
+
+// CreateAdjustment records adjustment with its status. An applied adjustment
+// changes the balance in the same transaction, writing an adjustment ledger
+// entry that points back at it; a pending one waits for DecideAdjustment.
+// Each of inTx runs afterwards inside the same transaction.
+func (r *BalanceRepository) CreateAdjustment(adjustment model.BalanceAdjustment, inTx ...func(tx *sql.Tx, adjustment model.BalanceAdjustment) error) (created model.BalanceAdjustment, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.BalanceAdjustment{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO balance_adjustments (user_id, crypto_symbol, amount, reason_code, justification, status, requested_by, decided_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $6 = $8 THEN NULL ELSE NOW() END)
+		RETURNING ` + adjustmentColumns
+	created, err = scanAdjustment(tx.QueryRow(query, adjustment.UserID, adjustment.CryptoSymbol, adjustment.Amount,
+		adjustment.ReasonCode, adjustment.Justification, adjustment.Status, adjustment.RequestedBy, model.AdjustmentPending))
+	if err != nil {
+		return model.BalanceAdjustment{}, fmt.Errorf("failed to record balance adjustment: %v", err)
+	}
+
+	if created.Status == model.AdjustmentApplied {
+		err = r.adjustBalance(tx, created.UserID, created.CryptoSymbol, created.Amount, model.LedgerAdjustment, ledgerRef{adjustmentID: &created.ID})
+		if err != nil {
+			return model.BalanceAdjustment{}, fmt.Errorf("failed to apply balance adjustment: %w", err)
+		}
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, created)
+		if err != nil {
+			return model.BalanceAdjustment{}, err
+		}
+	}
+
+	return created, nil
+}
+
+// DecideAdjustment records decidedBy's decision, applied or rejected, on a
+// pending adjustment. Applying changes the balance in the same transaction,
+// so an approval that would leave the balance negative fails and leaves the
+// adjustment pending. Each of inTx runs afterwards inside the same
+// transaction.
+func (r *BalanceRepository) DecideAdjustment(id, decidedBy int, status, note string, inTx ...func(tx *sql.Tx, adjustment model.BalanceAdjustment) error) (decided model.BalanceAdjustment, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.BalanceAdjustment{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		UPDATE balance_adjustments
+		SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW()
+		WHERE id = $1 AND status = $5
+		RETURNING ` + adjustmentColumns
+	decided, err = scanAdjustment(tx.QueryRow(query, id, status, decidedBy, note, model.AdjustmentPending))
+	if err == sql.ErrNoRows {
+		var current string
+		err = tx.QueryRow(`SELECT status FROM balance_adjustments WHERE id = $1`, id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return model.BalanceAdjustment{}, fmt.Errorf("balance adjustment %d: %w", id, ErrNotFound)
+		}
+		if err != nil {
+			return model.BalanceAdjustment{}, fmt.Errorf("failed to get balance adjustment: %v", err)
+		}
+		return model.BalanceAdjustment{}, fmt.Errorf("balance adjustment %d: %w as %s", id, ErrAlreadyDecided, current)
+	}
+	if err != nil {
+		return model.BalanceAdjustment{}, fmt.Errorf("failed to record balance adjustment decision: %v", err)
+	}
+
+	if decided.Status == model.AdjustmentApplied {
+		err = r.adjustBalance(tx, decided.UserID, decided.CryptoSymbol, decided.Amount, model.LedgerAdjustment, ledgerRef{adjustmentID: &decided.ID})
+		if err != nil {
+			return model.BalanceAdjustment{}, fmt.Errorf("failed to apply balance adjustment: %w", err)
+		}
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, decided)
+		if err != nil {
+			return model.BalanceAdjustment{}, err
+		}
+	}
+
+	return decided, nil
+}
+
+func (r *BalanceRepository) GetAdjustment(id int) (model.BalanceAdjustment, error) {
+	query := `SELECT ` + adjustmentColumns + ` FROM balance_adjustments WHERE id = $1`
+
+	adjustment, err := scanAdjustment(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return model.BalanceAdjustment{}, fmt.Errorf("balance adjustment %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return model.BalanceAdjustment{}, fmt.Errorf("failed to get balance adjustment: %v", err)
+	}
+	return adjustment, nil
+}
+
+// GetAdjustments lists the adjustments matching filter, newest first.
+func (r *BalanceRepository) GetAdjustments(filter AdjustmentFilter) ([]model.BalanceAdjustment, error) {
+	query := `
+		SELECT ` + adjustmentColumns + `
+		FROM balance_adjustments
+		WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR user_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(query, filter.Status, filter.UserID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list balance adjustments: %v", err)
+	}
+	defer rows.Close()
+
+	adjustments := []model.BalanceAdjustment{}
+	for rows.Next() {
+		adjustment, err := scanAdjustment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan balance adjustment: %v", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list balance adjustments: %v", err)
+	}
+	return adjustments, nil
+}
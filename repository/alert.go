@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+	"time"
+)
+
+type AlertRepository struct {
+	db *sql.DB
+}
+
+func NewAlertRepository(db *sql.DB) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const alertColumns = `id, user_id, crypto_symbol, quote_symbol, condition, threshold, reference_price,
+		channel, target, cooldown_seconds, is_active, last_triggered_at, created_at`
+
+func scanAlert(row interface{ Scan(...interface{}) error }) (model.PriceAlert, error) {
+	var alert model.PriceAlert
+	var lastTriggeredAt sql.NullTime
+	err := row.Scan(
+		&alert.ID,
+		&alert.UserID,
+		&alert.CryptoSymbol,
+		&alert.QuoteSymbol,
+		&alert.Condition,
+		&alert.Threshold,
+		&alert.ReferencePrice,
+		&alert.Channel,
+		&alert.Target,
+		&alert.CooldownSeconds,
+		&alert.IsActive,
+		&lastTriggeredAt,
+		&alert.CreatedAt,
+	)
+	if err != nil {
+		return alert, err
+	}
+	if lastTriggeredAt.Valid {
+		alert.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+	return alert, nil
+}
+
+func (r *AlertRepository) CreateAlert(alert model.PriceAlert) (model.PriceAlert, error) {
+	query := `
+		INSERT INTO price_alerts (user_id, crypto_symbol, quote_symbol, condition, threshold,
+			reference_price, channel, target, cooldown_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + alertColumns
+
+	created, err := scanAlert(r.db.QueryRow(query,
+		alert.UserID, alert.CryptoSymbol, alert.QuoteSymbol, alert.Condition, alert.Threshold,
+		alert.ReferencePrice, alert.Channel, alert.Target, alert.CooldownSeconds,
+	))
+	if err != nil {
+		return model.PriceAlert{}, fmt.Errorf("failed to create alert: %v", err)
+	}
+	return created, nil
+}
+
+func (r *AlertRepository) queryAlerts(query string, args ...interface{}) ([]model.PriceAlert, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []model.PriceAlert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+func (r *AlertRepository) GetUserAlerts(userID int) ([]model.PriceAlert, error) {
+	query := `SELECT ` + alertColumns + ` FROM price_alerts WHERE user_id = $1 ORDER BY id`
+	return r.queryAlerts(query, userID)
+}
+
+func (r *AlertRepository) GetActiveAlerts() ([]model.PriceAlert, error) {
+	query := `SELECT ` + alertColumns + ` FROM price_alerts WHERE is_active ORDER BY crypto_symbol, quote_symbol`
+	return r.queryAlerts(query)
+}
+
+// MarkTriggered starts the cooldown of an alert and stores the price that
+// percent-change alerts measure the next move from.
+func (r *AlertRepository) MarkTriggered(alertID int, triggeredAt time.Time, referencePrice float64) error {
+	query := `UPDATE price_alerts SET last_triggered_at = $1, reference_price = $2 WHERE id = $3`
+	_, err := r.db.Exec(query, triggeredAt, referencePrice, alertID)
+	if err != nil {
+		return fmt.Errorf("failed to mark alert as triggered: %v", err)
+	}
+	return nil
+}
+
+func (r *AlertRepository) DeleteAlert(userID int, alertID int) error {
+	res, err := r.db.Exec(`DELETE FROM price_alerts WHERE id = $1 AND user_id = $2`, alertID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert: %v", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("alert %d: %w", alertID, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"swap-wallet/model"
+	"time"
+)
+
+// auditLockKey serialises appends to the audit log, so every entry is
+// chained to the one committed before it.
+const auditLockKey = 7461756469
+
+// genesisHash is the PrevHash of the first audit entry.
+var genesisHash = strings.Repeat("0", 64)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditFilter narrows the audit entries listed. Zero values match everything;
+// BeforeID pages backwards from an earlier result.
+type AuditFilter struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+const auditColumns = `id, action, actor_id, actor_ip, request_id, target_type, target_id, before, after, created_at, prev_hash, hash`
+
+func scanAuditEntry(row interface{ Scan(...interface{}) error }) (model.AuditEntry, error) {
+	var entry model.AuditEntry
+	var actorID sql.NullInt64
+	var before, after sql.NullString
+	err := row.Scan(
+		&entry.ID,
+		&entry.Action,
+		&actorID,
+		&entry.ActorIP,
+		&entry.RequestID,
+		&entry.TargetType,
+		&entry.TargetID,
+		&before,
+		&after,
+		&entry.CreatedAt,
+		&entry.PrevHash,
+		&entry.Hash,
+	)
+	if err != nil {
+		return entry, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		entry.ActorID = &id
+	}
+	if before.Valid {
+		entry.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		entry.After = json.RawMessage(after.String)
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+	return entry, nil
+}
+
+// auditHash is the hex SHA-256 of the previous hash and the entry's fields,
+// encoded as JSON in a fixed order.
+func auditHash(entry model.AuditEntry) string {
+	fields, _ := json.Marshal(struct {
+		Action     string          `json:"action"`
+		ActorID    *int            `json:"actor_id"`
+		ActorIP    string          `json:"actor_ip"`
+		RequestID  string          `json:"request_id"`
+		TargetType string          `json:"target_type"`
+		TargetID   string          `json:"target_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		Action:     entry.Action,
+		ActorID:    entry.ActorID,
+		ActorIP:    entry.ActorIP,
+		RequestID:  entry.RequestID,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Before:     entry.Before,
+		After:      entry.After,
+		CreatedAt:  entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256([]byte(entry.PrevHash + "\n" + string(fields)))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyAuditEntry checks that entry follows result.LastHash and that its
+// hash matches its content, adding it to result. It reports false, with
+// result marked invalid, when either check fails.
+func verifyAuditEntry(result *model.AuditVerification, entry model.AuditEntry) bool {
+	reason := ""
+	if entry.PrevHash != result.LastHash {
+		reason = "previous hash does not match the preceding entry"
+	} else if auditHash(entry) != entry.Hash {
+		reason = "hash does not match the entry's content"
+	}
+	if reason != "" {
+		result.Valid = false
+		result.FirstInvalidID = &entry.ID
+		result.Reason = reason
+		return false
+	}
+
+	result.Entries++
+	result.LastHash = entry.Hash
+	return true
+}
+
+func nullJSON(value json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(value), Valid: value != nil}
+}
+
+// AppendTx chains entry to the latest audit entry and inserts it within tx.
+// It holds a transaction-scoped lock until tx ends, so callers should append
+// last, just before committing.
+func (r *AuditRepository) AppendTx(tx *sql.Tx, entry model.AuditEntry) (model.AuditEntry, error) {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditLockKey)
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to lock audit log: %v", err)
+	}
+
+	err = tx.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash = genesisHash
+	} else if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to get latest audit hash: %v", err)
+	}
+
+	// Postgres keeps microseconds, and the hash must match what is read back.
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.Hash = auditHash(entry)
+
+	query := `
+		INSERT INTO audit_log (action, actor_id, actor_ip, request_id, target_type, target_id, before, after, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+	err = tx.QueryRow(query, entry.Action, entry.ActorID, entry.ActorIP, entry.RequestID, entry.TargetType, entry.TargetID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.CreatedAt, entry.PrevHash, entry.Hash).Scan(&entry.ID)
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to append audit entry: %v", err)
+	}
+	return entry, nil
+}
+
+// Append records entry in a transaction of its own.
+func (r *AuditRepository) Append(entry model.AuditEntry) (appended model.AuditEntry, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return r.AppendTx(tx, entry)
+}
+
+// GetLatest returns the most recent entry with action, or ErrNotFound.
+func (r *AuditRepository) GetLatest(action string) (model.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_log WHERE action = $1 ORDER BY id DESC LIMIT 1`
+
+	entry, err := scanAuditEntry(r.db.QueryRow(query, action))
+	if err == sql.ErrNoRows {
+		return model.AuditEntry{}, fmt.Errorf("audit entry %s: %w", action, ErrNotFound)
+	}
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to get audit entry: %v", err)
+	}
+	return entry, nil
+}
+
+// GetEntries lists the entries matching filter, newest first.
+func (r *AuditRepository) GetEntries(filter AuditFilter) ([]model.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.ActorID > 0 {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		where("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %v", err)
+	}
+	return entries, nil
+}
+
+// Verify walks the whole audit log in order and recomputes every hash,
+// stopping at the first entry that does not chain to its predecessor or
+// whose content no longer matches its hash.
+func (r *AuditRepository) Verify() (model.AuditVerification, error) {
+	rows, err := r.db.Query(`SELECT ` + auditColumns + ` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return model.AuditVerification{}, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	defer rows.Close()
+
+	result := model.AuditVerification{Valid: true, LastHash: genesisHash}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return model.AuditVerification{}, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+
+		if !verifyAuditEntry(&result, entry) {
+			return result, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return model.AuditVerification{}, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"swap-wallet/model"
+)
+
+// auditChain returns n chained audit entries with ids from 1.
+func auditChain(n int) []model.AuditEntry {
+	actorID := 7
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	prevHash := genesisHash
+
+	entries := make([]model.AuditEntry, n)
+	for i := range entries {
+		entry := model.AuditEntry{
+			ID:         int64(i + 1),
+			Action:     model.AuditExchangeCompleted,
+			ActorID:    &actorID,
+			ActorIP:    "203.0.113.9",
+			RequestID:  "req-1",
+			TargetType: "exchange",
+			TargetID:   "42",
+			Before:     json.RawMessage(`{"BTC":"1"}`),
+			After:      json.RawMessage(`{"BTC":"0.5"}`),
+			CreatedAt:  created.Add(time.Duration(i) * time.Second),
+			PrevHash:   prevHash,
+		}
+		entry.Hash = auditHash(entry)
+		prevHash = entry.Hash
+		entries[i] = entry
+	}
+	return entries
+}
+
+func TestVerifyAuditEntry(t *testing.T) {
+	const (
+		reasonPrev    = "previous hash does not match the preceding entry"
+		reasonContent = "hash does not match the entry's content"
+	)
+
+	tests := []struct {
+		name        string
+		tamper      func([]model.AuditEntry) []model.AuditEntry
+		wantInvalid int64
+		wantReason  string
+	}{
+		{
+			name:   "intact chain",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry { return entries },
+		},
+		{
+			name: "created_at in another time zone",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].CreatedAt = entries[1].CreatedAt.In(time.FixedZone("UTC+2", 2*60*60))
+				return entries
+			},
+		},
+		{
+			name: "edited content",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].After = json.RawMessage(`{"BTC":"5"}`)
+				return entries
+			},
+			wantInvalid: 2,
+			wantReason:  reasonContent,
+		},
+		{
+			name: "edited actor",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[0].ActorID = nil
+				return entries
+			},
+			wantInvalid: 1,
+			wantReason:  reasonContent,
+		},
+		{
+			name: "edited and rehashed entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].TargetID = "43"
+				entries[1].Hash = auditHash(entries[1])
+				return entries
+			},
+			wantInvalid: 3,
+			wantReason:  reasonPrev,
+		},
+		{
+			name: "deleted entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			},
+			wantInvalid: 3,
+			wantReason:  reasonPrev,
+		},
+		{
+			name: "deleted first entry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				return entries[1:]
+			},
+			wantInvalid: 2,
+			wantReason:  reasonPrev,
+		},
+		{
+			name: "swapped entries",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1], entries[2] = entries[2], entries[1]
+				return entries
+			},
+			wantInvalid: 3,
+			wantReason:  reasonPrev,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := tt.tamper(auditChain(3))
+
+			result := model.AuditVerification{Valid: true, LastHash: genesisHash}
+			for _, entry := range entries {
+				if !verifyAuditEntry(&result, entry) {
+					break
+				}
+			}
+
+			if tt.wantReason == "" {
+				if !result.Valid || result.Entries != len(entries) || result.LastHash != entries[len(entries)-1].Hash {
+					t.Fatalf("verification = %+v, want a valid chain of %d entries", result, len(entries))
+				}
+				return
+			}
+			if result.Valid || result.FirstInvalidID == nil || *result.FirstInvalidID != tt.wantInvalid || result.Reason != tt.wantReason {
+				t.Fatalf("verification = %+v, want entry %d invalid: %s", result, tt.wantInvalid, tt.wantReason)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"swap-wallet/model"
 )
 
 type BalanceRepository struct {
@@ -19,28 +20,6 @@ func NewBalanceRepository(db *sql.DB) *BalanceRepository {
 	return &BalanceRepository{db: db}
 }
 
-func (r *BalanceRepository) GetBalanceAndScale(userID int, cryptoSymbol string) (int64, int, error) {
-	var balance int64
-	var scale int
-
-	query := `
-        SELECT COALESCE(b.balance, 0), c.scale
-        FROM cryptocurrencies c
-        LEFT JOIN balances b ON b.crypto_id = c.id AND b.user_id = $1
-        WHERE c.symbol = $2
-    `
-
-	err := r.db.QueryRow(query, userID, cryptoSymbol).Scan(&balance, &scale)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, 0, fmt.Errorf("cryptocurrency not found for symbol: %s", cryptoSymbol)
-		}
-		return -1, -1, err
-	}
-
-	return balance, scale, nil
-}
-
 func (r *BalanceRepository) GetUserBalance(userID int, crypto string) (int64, error) {
 	var balance int64
 	query := `
@@ -90,16 +69,6 @@ func (r *BalanceRepository) GetUserBalances(userID int) ([]CryptoBalance, error)
 	return userBalances, nil
 }
 
-func (r *BalanceRepository) GetBalanceForUser(userID int, cryptoID int) (int64, error) {
-	var balance int64
-	query := `SELECT balance FROM balances WHERE user_id = $1 AND crypto_id = $2`
-	err := r.db.QueryRow(query, userID, cryptoID).Scan(&balance)
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch balance: %v", err)
-	}
-	return balance, nil
-}
-
 func (r *BalanceRepository) UpdateBalance(tx *sql.Tx, userID int, cryptoSymbol string, newBalance int64) error {
 	query := `
 		INSERT INTO balances (user_id, crypto_id, balance)
@@ -115,10 +84,28 @@ func (r *BalanceRepository) UpdateBalance(tx *sql.Tx, userID int, cryptoSymbol s
 	return nil
 }
 
-func (r *BalanceRepository) ExchangeBalances(userID int, sourceCrypto, targetCrypto string, sourceAmount, targetAmount float64) error {
+// ExchangeLeg is a single conversion within an exchange. Amounts are in whole
+// units of each asset and are scaled to its smallest unit when applied. The
+// target amount is net of Fee, which is charged in the target asset.
+type ExchangeLeg struct {
+	SourceCrypto string
+	TargetCrypto string
+	SourceAmount float64
+	TargetAmount float64
+	Fee          float64
+}
+
+// ExchangeLegs records exchange and applies every one of its legs to the
+// user's balances in one transaction, so a routed exchange through
+// intermediate assets either completes entirely or leaves all balances
+// untouched. Each leg writes ledger entries for the debit, the gross credit
+// and the fee. Each of inTx runs afterwards inside the same transaction,
+// letting callers persist records that must commit together with the balance
+// changes.
+func (r *BalanceRepository) ExchangeLegs(exchange model.Exchange, legs []ExchangeLeg, inTx ...func(tx *sql.Tx, exchangeID int) error) (exchangeID int, err error) {
 	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
 	}
 	defer func() {
 		if p := recover(); p != nil {
@@ -131,44 +118,96 @@ func (r *BalanceRepository) ExchangeBalances(userID int, sourceCrypto, targetCry
 		}
 	}()
 
-	sourceBalance, err := r.GetUserBalance(userID, sourceCrypto)
+	query := `
+		INSERT INTO exchanges (user_id, source_crypto, target_crypto, source_amount, target_amount, fee, rate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	err = tx.QueryRow(query, exchange.UserID, exchange.SourceCrypto, exchange.TargetCrypto,
+		exchange.SourceAmount, exchange.TargetAmount, exchange.Fee, exchange.Rate).Scan(&exchangeID)
 	if err != nil {
-		return fmt.Errorf("failed to get source balance: %v", err)
+		return 0, fmt.Errorf("failed to record exchange: %v", err)
 	}
 
-	sourceScale, err := r.GetCryptoScale(tx, sourceCrypto)
-	if err != nil {
-		return fmt.Errorf("failed to get source crypto scale: %v", err)
-	}
+	for _, leg := range legs {
+		err = r.adjustBalance(tx, exchange.UserID, leg.SourceCrypto, -leg.SourceAmount, model.LedgerExchange, ledgerRef{exchangeID: &exchangeID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to update source balance: %w", err)
+		}
 
-	scaledSourceAmount := int64(sourceAmount * math.Pow(10, float64(sourceScale)))
+		err = r.adjustBalance(tx, exchange.UserID, leg.TargetCrypto, leg.TargetAmount+leg.Fee, model.LedgerExchange, ledgerRef{exchangeID: &exchangeID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to update target balance: %w", err)
+		}
 
-	if sourceBalance < scaledSourceAmount {
-		return fmt.Errorf("insufficient balance in source cryptocurrency")
+		if leg.Fee > 0 {
+			err = r.adjustBalance(tx, exchange.UserID, leg.TargetCrypto, -leg.Fee, model.LedgerFee, ledgerRef{exchangeID: &exchangeID})
+			if err != nil {
+				return 0, fmt.Errorf("failed to charge fee: %w", err)
+			}
+		}
 	}
 
-	newSourceBalance := sourceBalance - scaledSourceAmount
-	err = r.UpdateBalance(tx, userID, sourceCrypto, newSourceBalance)
-	if err != nil {
-		return fmt.Errorf("failed to update source balance: %v", err)
+	for _, fn := range inTx {
+		err = fn(tx, exchangeID)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	targetBalance, err := r.GetUserBalance(userID, targetCrypto)
+	return exchangeID, nil
+}
+
+// ledgerRef links a ledger entry to the exchange or adjustment that wrote it.
+type ledgerRef struct {
+	exchangeID   *int
+	adjustmentID *int
+}
+
+// adjustBalance adds amount, which may be negative, to the user's balance of
+// cryptoSymbol and records the change in the ledger. The balance row is locked
+// for the rest of the transaction.
+func (r *BalanceRepository) adjustBalance(tx *sql.Tx, userID int, cryptoSymbol string, amount float64, kind string, ref ledgerRef) error {
+	scale, err := r.GetCryptoScale(tx, cryptoSymbol)
 	if err != nil {
-		return fmt.Errorf("failed to get target balance: %v", err)
+		return fmt.Errorf("failed to get %s scale: %v", cryptoSymbol, err)
 	}
 
-	targetScale, err := r.GetCryptoScale(tx, targetCrypto)
-	if err != nil {
-		return fmt.Errorf("failed to get target crypto scale: %v", err)
+	var balance int64
+	query := `
+		SELECT b.balance
+		FROM balances b
+		JOIN cryptocurrencies c ON b.crypto_id = c.id
+		WHERE b.user_id = $1 AND c.symbol = $2
+		FOR UPDATE OF b
+	`
+	err = tx.QueryRow(query, userID, cryptoSymbol).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get %s balance: %v", cryptoSymbol, err)
+	}
+
+	units := int64(math.Round(amount * math.Pow(10, float64(scale))))
+	if units == 0 {
+		return nil
+	}
+
+	newBalance := balance + units
+	if newBalance < 0 {
+		return fmt.Errorf("%w in %s", ErrInsufficientBalance, cryptoSymbol)
 	}
 
-	scaledTargetAmount := int64(targetAmount * math.Pow(10, float64(targetScale)))
+	err = r.UpdateBalance(tx, userID, cryptoSymbol, newBalance)
+	if err != nil {
+		return err
+	}
 
-	newTargetBalance := targetBalance + scaledTargetAmount
-	err = r.UpdateBalance(tx, userID, targetCrypto, newTargetBalance)
+	ledgerQuery := `
+		INSERT INTO ledger_entries (user_id, crypto_symbol, kind, amount, balance_after, exchange_id, adjustment_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.Exec(ledgerQuery, userID, cryptoSymbol, kind, units, newBalance, ref.exchangeID, ref.adjustmentID)
 	if err != nil {
-		return fmt.Errorf("failed to update target balance: %v", err)
+		return fmt.Errorf("failed to record ledger entry: %v", err)
 	}
 
 	return nil
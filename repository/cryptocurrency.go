@@ -47,6 +47,69 @@ func (r *CryptocurrencyRepository) GetCryptoScale(symbol string) (int, error) {
 	return scale, nil
 }
 
+func (r *CryptocurrencyRepository) GetCryptocurrencies() ([]model.Cryptocurrency, error) {
+	query := `SELECT id, name, symbol, is_available, scale FROM cryptocurrencies ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cryptos []model.Cryptocurrency
+	for rows.Next() {
+		var crypto model.Cryptocurrency
+		err := rows.Scan(&crypto.ID, &crypto.Name, &crypto.Symbol, &crypto.IsAvailable, &crypto.Scale)
+		if err != nil {
+			return nil, err
+		}
+		cryptos = append(cryptos, crypto)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cryptos, nil
+}
+
+// GetTradingPairs returns the enabled pairs whose assets are both available.
+func (r *CryptocurrencyRepository) GetTradingPairs() ([]model.TradingPair, error) {
+	query := `
+		SELECT p.id, p.base_crypto_id, p.quote_crypto_id, b.symbol, q.symbol, p.fee_rate, p.spread, p.is_enabled
+		FROM trading_pairs p
+		JOIN cryptocurrencies b ON b.id = p.base_crypto_id
+		JOIN cryptocurrencies q ON q.id = p.quote_crypto_id
+		WHERE p.is_enabled AND b.is_available AND q.is_available
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []model.TradingPair
+	for rows.Next() {
+		var pair model.TradingPair
+		var feeRate sql.NullFloat64
+		err := rows.Scan(&pair.ID, &pair.BaseCryptoID, &pair.QuoteCryptoID, &pair.BaseSymbol, &pair.QuoteSymbol, &feeRate, &pair.Spread, &pair.IsEnabled)
+		if err != nil {
+			return nil, err
+		}
+		if feeRate.Valid {
+			pair.FeeRate = &feeRate.Float64
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
 func (r *BalanceRepository) GetCryptoScale(tx *sql.Tx, cryptoSymbol string) (int, error) {
 	query := `SELECT scale FROM cryptocurrencies WHERE symbol = $1`
 	var scale int
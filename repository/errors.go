@@ -0,0 +1,25 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is wrapped by errors for records that do not exist or do
+	// not belong to the user.
+	ErrNotFound = errors.New("not found")
+	// ErrInsufficientBalance is wrapped by errors for balance changes that
+	// would leave a balance negative.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrAlreadyDecided is wrapped by errors for decisions on balance
+	// adjustments that are no longer pending.
+	ErrAlreadyDecided = errors.New("already decided")
+	// ErrUsernameTaken and ErrEmailTaken are wrapped by errors for user
+	// changes that would break the uniqueness of usernames or emails.
+	ErrUsernameTaken = errors.New("username taken")
+	ErrEmailTaken    = errors.New("email taken")
+	// ErrAccountNotEmpty is wrapped by errors for deleting a user who still
+	// holds a balance.
+	ErrAccountNotEmpty = errors.New("account not empty")
+	// ErrTwoFactorEnabled is wrapped by errors for starting a TOTP
+	// enrolment while one is already enabled.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+)
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type HistoryRepository struct {
+	db *sql.DB
+}
+
+func NewHistoryRepository(db *sql.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+// SnapshotBalances copies every current balance into balance_snapshots with
+// the same taken_at timestamp.
+func (r *HistoryRepository) SnapshotBalances(takenAt time.Time) error {
+	query := `
+		INSERT INTO balance_snapshots (user_id, crypto_id, balance, taken_at)
+		SELECT user_id, crypto_id, balance, $1 FROM balances
+	`
+	_, err := r.db.Exec(query, takenAt)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot balances: %v", err)
+	}
+	return nil
+}
+
+// GetUserSnapshots returns the user's snapshots taken between from and to,
+// preceded by the latest snapshot of each asset before from so that the
+// series has a starting value.
+func (r *HistoryRepository) GetUserSnapshots(userID int, from, to time.Time) ([]model.BalanceSnapshot, error) {
+	query := `
+		(SELECT s.user_id, c.symbol, s.balance, c.scale, s.taken_at
+		FROM balance_snapshots s
+		JOIN cryptocurrencies c ON c.id = s.crypto_id
+		WHERE s.user_id = $1 AND s.taken_at > $2 AND s.taken_at <= $3)
+		UNION ALL
+		(SELECT DISTINCT ON (s.crypto_id) s.user_id, c.symbol, s.balance, c.scale, s.taken_at
+		FROM balance_snapshots s
+		JOIN cryptocurrencies c ON c.id = s.crypto_id
+		WHERE s.user_id = $1 AND s.taken_at <= $2
+		ORDER BY s.crypto_id, s.taken_at DESC)
+		ORDER BY 5
+	`
+
+	rows, err := r.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []model.BalanceSnapshot
+	for rows.Next() {
+		var snapshot model.BalanceSnapshot
+		err := rows.Scan(&snapshot.UserID, &snapshot.CryptoSymbol, &snapshot.Balance, &snapshot.Scale, &snapshot.TakenAt)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// RecordPrice stores an observed price and folds it into the candle of every
+// resolution in config.CandleIntervals. Open and close follow the observation
+// times, so late or out-of-order observations land in the right place.
+func (r *HistoryRepository) RecordPrice(point model.PricePoint) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO price_history (crypto_symbol, quote_symbol, price, observed_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err = tx.Exec(query, point.CryptoSymbol, point.QuoteSymbol, point.Price, point.ObservedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record price: %v", err)
+	}
+
+	candleQuery := `
+		INSERT INTO price_candles (crypto_symbol, quote_symbol, resolution, bucket_start,
+			open, high, low, close, open_at, close_at, samples)
+		VALUES ($1, $2, $3, $4, $5, $5, $5, $5, $6, $6, 1)
+		ON CONFLICT (crypto_symbol, quote_symbol, resolution, bucket_start) DO UPDATE SET
+			open = CASE WHEN EXCLUDED.open_at < price_candles.open_at THEN EXCLUDED.open ELSE price_candles.open END,
+			open_at = LEAST(price_candles.open_at, EXCLUDED.open_at),
+			close = CASE WHEN EXCLUDED.close_at >= price_candles.close_at THEN EXCLUDED.close ELSE price_candles.close END,
+			close_at = GREATEST(price_candles.close_at, EXCLUDED.close_at),
+			high = GREATEST(price_candles.high, EXCLUDED.high),
+			low = LEAST(price_candles.low, EXCLUDED.low),
+			samples = price_candles.samples + 1
+	`
+	for resolution, seconds := range config.CandleIntervals {
+		bucketStart := point.ObservedAt.Truncate(time.Duration(seconds) * time.Second)
+		_, err = tx.Exec(candleQuery, point.CryptoSymbol, point.QuoteSymbol, resolution, bucketStart, point.Price, point.ObservedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update %s candle: %v", resolution, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *HistoryRepository) GetCandles(cryptoSymbol, quoteSymbol, resolution string, from, to time.Time) ([]model.Candle, error) {
+	query := `
+		SELECT crypto_symbol, quote_symbol, resolution, bucket_start, open, high, low, close, samples
+		FROM price_candles
+		WHERE crypto_symbol = $1 AND quote_symbol = $2 AND resolution = $3
+			AND bucket_start >= $4 AND bucket_start < $5
+		ORDER BY bucket_start
+	`
+
+	rows, err := r.db.Query(query, cryptoSymbol, quoteSymbol, resolution, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []model.Candle{}
+	for rows.Next() {
+		var candle model.Candle
+		err := rows.Scan(&candle.CryptoSymbol, &candle.QuoteSymbol, &candle.Resolution, &candle.BucketStart,
+			&candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Samples)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candles, nil
+}
+
+// GetPrices returns the prices of symbols in quote observed between from and
+// to, preceded by the latest price of each symbol before from.
+func (r *HistoryRepository) GetPrices(symbols []string, quote string, from, to time.Time) ([]model.PricePoint, error) {
+	query := `
+		(SELECT crypto_symbol, quote_symbol, price, observed_at
+		FROM price_history
+		WHERE crypto_symbol = ANY($1) AND quote_symbol = $2 AND observed_at > $3 AND observed_at <= $4)
+		UNION ALL
+		(SELECT DISTINCT ON (crypto_symbol) crypto_symbol, quote_symbol, price, observed_at
+		FROM price_history
+		WHERE crypto_symbol = ANY($1) AND quote_symbol = $2 AND observed_at <= $3
+		ORDER BY crypto_symbol, observed_at DESC)
+		ORDER BY 4
+	`
+
+	rows, err := r.db.Query(query, pq.Array(symbols), quote, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []model.PricePoint
+	for rows.Next() {
+		var point model.PricePoint
+		err := rows.Scan(&point.CryptoSymbol, &point.QuoteSymbol, &point.Price, &point.ObservedAt)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"swap-wallet/model"
+	"time"
+)
+
+type LedgerRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+const ledgerColumns = `id, user_id, crypto_symbol, kind, amount, balance_after, exchange_id, adjustment_id, created_at`
+
+func scanLedgerEntries(rows *sql.Rows) ([]model.LedgerEntry, error) {
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		var entry model.LedgerEntry
+		var exchangeID, adjustmentID sql.NullInt64
+		err := rows.Scan(&entry.ID, &entry.UserID, &entry.CryptoSymbol, &entry.Kind, &entry.Amount, &entry.BalanceAfter, &exchangeID, &adjustmentID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if exchangeID.Valid {
+			id := int(exchangeID.Int64)
+			entry.ExchangeID = &id
+		}
+		if adjustmentID.Valid {
+			id := int(adjustmentID.Int64)
+			entry.AdjustmentID = &id
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetEntriesSince returns the user's ledger entries created at or after from,
+// oldest first.
+func (r *LedgerRepository) GetEntriesSince(userID int, from time.Time) ([]model.LedgerEntry, error) {
+	query := `
+		SELECT ` + ledgerColumns + `
+		FROM ledger_entries
+		WHERE user_id = $1 AND created_at >= $2
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(query, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	return scanLedgerEntries(rows)
+}
+
+// GetExchangeEntries returns the ledger entries written by an exchange within
+// tx, in the order they were applied.
+func (r *LedgerRepository) GetExchangeEntries(tx *sql.Tx, exchangeID int) ([]model.LedgerEntry, error) {
+	query := `
+		SELECT ` + ledgerColumns + `
+		FROM ledger_entries
+		WHERE exchange_id = $1
+		ORDER BY id
+	`
+
+	rows, err := tx.Query(query, exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLedgerEntries(rows)
+}
+
+// GetAdjustmentEntries returns the ledger entries written by a balance
+// adjustment within tx.
+func (r *LedgerRepository) GetAdjustmentEntries(tx *sql.Tx, adjustmentID int) ([]model.LedgerEntry, error) {
+	query := `
+		SELECT ` + ledgerColumns + `
+		FROM ledger_entries
+		WHERE adjustment_id = $1
+		ORDER BY id
+	`
+
+	rows, err := tx.Query(query, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLedgerEntries(rows)
+}
@@ -7,10 +7,33 @@ import (
 )
 
 func CreateTables(db *sql.DB) {
+	// Usernames stay reserved after an account is deleted; emails are only
+	// unique among accounts that still exist. CREATE TABLE IF NOT EXISTS
+	// leaves existing tables alone, so columns added since a table was first
+	// released are also added with ADD COLUMN IF NOT EXISTS.
 	userTable := `CREATE TABLE IF NOT EXISTS users (
 		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) NOT NULL UNIQUE
-		);`
+		username VARCHAR(255) NOT NULL UNIQUE,
+		role VARCHAR(20) NOT NULL DEFAULT 'user',
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		display_name VARCHAR(100) NOT NULL DEFAULT '',
+		email VARCHAR(255),
+		quote_currency VARCHAR(10) NOT NULL DEFAULT 'USD',
+		locale VARCHAR(35) NOT NULL DEFAULT 'en-US',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		deleted_at TIMESTAMPTZ
+		);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS quote_currency VARCHAR(10) NOT NULL DEFAULT 'USD';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT 'en-US';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (email) WHERE deleted_at IS NULL;`
 
 	cryptoTable := `CREATE TABLE IF NOT EXISTS cryptocurrencies (
 		id SERIAL PRIMARY KEY,
@@ -30,6 +53,276 @@ func CreateTables(db *sql.DB) {
 		FOREIGN KEY (crypto_id) REFERENCES cryptocurrencies(id)
 	);`
 
+	exchangeTable := `CREATE TABLE IF NOT EXISTS exchanges (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		source_crypto VARCHAR(50) NOT NULL,
+		target_crypto VARCHAR(50) NOT NULL,
+		source_amount DOUBLE PRECISION NOT NULL,
+		target_amount DOUBLE PRECISION NOT NULL,
+		fee DOUBLE PRECISION NOT NULL DEFAULT 0,
+		rate DOUBLE PRECISION NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	// Adjustments are never deleted and only the decision on a pending one
+	// can be recorded, so the table is an audit trail of every manual change.
+	balanceAdjustmentTable := `CREATE TABLE IF NOT EXISTS balance_adjustments (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		crypto_symbol VARCHAR(50) NOT NULL,
+		amount DOUBLE PRECISION NOT NULL,
+		reason_code VARCHAR(50) NOT NULL,
+		justification TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		requested_by INT NOT NULL,
+		decided_by INT,
+		decision_note TEXT NOT NULL DEFAULT '',
+		decided_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (requested_by) REFERENCES users(id),
+		FOREIGN KEY (decided_by) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS balance_adjustments_status ON balance_adjustments (status, created_at);
+	CREATE OR REPLACE FUNCTION protect_balance_adjustments() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' OR OLD.status <> 'pending' THEN
+			RAISE EXCEPTION 'balance adjustment % is immutable', OLD.id;
+		END IF;
+		IF (NEW.user_id, NEW.crypto_symbol, NEW.amount, NEW.reason_code, NEW.justification, NEW.requested_by, NEW.created_at)
+			IS DISTINCT FROM (OLD.user_id, OLD.crypto_symbol, OLD.amount, OLD.reason_code, OLD.justification, OLD.requested_by, OLD.created_at) THEN
+			RAISE EXCEPTION 'only the decision on balance adjustment % can be recorded', OLD.id;
+		END IF;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS balance_adjustments_immutable ON balance_adjustments;
+	CREATE TRIGGER balance_adjustments_immutable BEFORE UPDATE OR DELETE ON balance_adjustments
+		FOR EACH ROW EXECUTE FUNCTION protect_balance_adjustments();`
+
+	ledgerEntryTable := `CREATE TABLE IF NOT EXISTS ledger_entries (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		crypto_symbol VARCHAR(50) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		amount BIGINT NOT NULL,
+		balance_after BIGINT NOT NULL,
+		exchange_id INT,
+		adjustment_id INT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (exchange_id) REFERENCES exchanges(id),
+		FOREIGN KEY (adjustment_id) REFERENCES balance_adjustments(id)
+	);
+	ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS adjustment_id INT REFERENCES balance_adjustments(id);
+	CREATE INDEX IF NOT EXISTS ledger_entries_user_created_at ON ledger_entries (user_id, created_at);`
+
+	tradingPairTable := `CREATE TABLE IF NOT EXISTS trading_pairs (
+		id SERIAL PRIMARY KEY,
+		base_crypto_id INT NOT NULL,
+		quote_crypto_id INT NOT NULL,
+		fee_rate DOUBLE PRECISION,
+		spread DOUBLE PRECISION NOT NULL DEFAULT 0,
+		is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		UNIQUE (base_crypto_id, quote_crypto_id),
+		FOREIGN KEY (base_crypto_id) REFERENCES cryptocurrencies(id),
+		FOREIGN KEY (quote_crypto_id) REFERENCES cryptocurrencies(id)
+	);`
+
+	positionEventTable := `CREATE TABLE IF NOT EXISTS position_events (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		crypto_symbol VARCHAR(50) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		amount DOUBLE PRECISION NOT NULL,
+		usd_value DOUBLE PRECISION NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	balanceSnapshotTable := `CREATE TABLE IF NOT EXISTS balance_snapshots (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		crypto_id INT NOT NULL,
+		balance BIGINT NOT NULL,
+		taken_at TIMESTAMPTZ NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (crypto_id) REFERENCES cryptocurrencies(id)
+	);
+	CREATE INDEX IF NOT EXISTS balance_snapshots_user_taken_at ON balance_snapshots (user_id, taken_at);`
+
+	priceHistoryTable := `CREATE TABLE IF NOT EXISTS price_history (
+		id BIGSERIAL PRIMARY KEY,
+		crypto_symbol VARCHAR(50) NOT NULL,
+		quote_symbol VARCHAR(50) NOT NULL,
+		price DOUBLE PRECISION NOT NULL,
+		observed_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS price_history_pair_observed_at ON price_history (crypto_symbol, quote_symbol, observed_at);`
+
+	priceCandleTable := `CREATE TABLE IF NOT EXISTS price_candles (
+		crypto_symbol VARCHAR(50) NOT NULL,
+		quote_symbol VARCHAR(50) NOT NULL,
+		resolution VARCHAR(10) NOT NULL,
+		bucket_start TIMESTAMPTZ NOT NULL,
+		open DOUBLE PRECISION NOT NULL,
+		high DOUBLE PRECISION NOT NULL,
+		low DOUBLE PRECISION NOT NULL,
+		close DOUBLE PRECISION NOT NULL,
+		open_at TIMESTAMPTZ NOT NULL,
+		close_at TIMESTAMPTZ NOT NULL,
+		samples INT NOT NULL,
+		PRIMARY KEY (crypto_symbol, quote_symbol, resolution, bucket_start)
+	);`
+
+	priceAlertTable := `CREATE TABLE IF NOT EXISTS price_alerts (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		crypto_symbol VARCHAR(50) NOT NULL,
+		quote_symbol VARCHAR(50) NOT NULL,
+		condition VARCHAR(20) NOT NULL,
+		threshold DOUBLE PRECISION NOT NULL,
+		reference_price DOUBLE PRECISION NOT NULL DEFAULT 0,
+		channel VARCHAR(20) NOT NULL,
+		target TEXT NOT NULL,
+		cooldown_seconds INT NOT NULL,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		last_triggered_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	outboxTable := `CREATE TABLE IF NOT EXISTS outbox_events (
+		id BIGSERIAL PRIMARY KEY,
+		event_id VARCHAR(64) NOT NULL UNIQUE,
+		event_type VARCHAR(50) NOT NULL,
+		user_id INT NOT NULL,
+		payload TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		published_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS outbox_events_unpublished_idx ON outbox_events (id) WHERE published_at IS NULL;`
+
+	webhookEndpointTable := `CREATE TABLE IF NOT EXISTS webhook_endpoints (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		url TEXT NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		event_types TEXT[] NOT NULL,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	webhookDeliveryTable := `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		endpoint_id INT NOT NULL,
+		event_id VARCHAR(64) NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_response_code INT,
+		last_error TEXT NOT NULL DEFAULT '',
+		delivered_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);`
+
+	scheduleTable := `CREATE TABLE IF NOT EXISTS schedules (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		source_crypto VARCHAR(50) NOT NULL,
+		target_crypto VARCHAR(50) NOT NULL,
+		source_amount DOUBLE PRECISION NOT NULL,
+		recurrence VARCHAR(20) NOT NULL,
+		interval_seconds INT NOT NULL DEFAULT 0,
+		weekday INT NOT NULL DEFAULT 0,
+		hour INT NOT NULL DEFAULT 0,
+		minute INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL,
+		next_run_at TIMESTAMPTZ NOT NULL,
+		last_run_at TIMESTAMPTZ,
+		last_result TEXT NOT NULL DEFAULT '',
+		consecutive_failures INT NOT NULL DEFAULT 0,
+		two_factor_approved_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+	ALTER TABLE schedules ADD COLUMN IF NOT EXISTS two_factor_approved_at TIMESTAMPTZ;`
+
+	userStatusChangeTable := `CREATE TABLE IF NOT EXISTS user_status_changes (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		from_status VARCHAR(20) NOT NULL,
+		to_status VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL,
+		changed_by INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (changed_by) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS user_status_changes_user ON user_status_changes (user_id, id);`
+
+	// A TOTP secret is pending until its first code is confirmed. Only the
+	// SHA-256 of each recovery code is kept.
+	twoFactorTable := `CREATE TABLE IF NOT EXISTS user_totp (
+		user_id INT PRIMARY KEY,
+		secret VARCHAR(64) NOT NULL,
+		enabled_at TIMESTAMPTZ,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		failed_attempts INT NOT NULL DEFAULT 0,
+		locked_until TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	recoveryCodeTable := `CREATE TABLE IF NOT EXISTS recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		code_hash CHAR(64) NOT NULL,
+		used_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS recovery_codes_user ON recovery_codes (user_id);`
+
+	// The audit log is append-only: each entry's hash covers the previous
+	// entry's hash, and triggers reject any update, delete or truncate.
+	auditLogTable := `CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		action VARCHAR(64) NOT NULL,
+		actor_id INT,
+		actor_ip VARCHAR(64) NOT NULL DEFAULT '',
+		request_id VARCHAR(64) NOT NULL DEFAULT '',
+		target_type VARCHAR(32) NOT NULL DEFAULT '',
+		target_id VARCHAR(64) NOT NULL DEFAULT '',
+		before TEXT,
+		after TEXT,
+		created_at TIMESTAMPTZ NOT NULL,
+		prev_hash CHAR(64) NOT NULL,
+		hash CHAR(64) NOT NULL UNIQUE
+	);
+	CREATE INDEX IF NOT EXISTS audit_log_action_created_at ON audit_log (action, created_at);
+	CREATE INDEX IF NOT EXISTS audit_log_actor_created_at ON audit_log (actor_id, created_at);
+	CREATE INDEX IF NOT EXISTS audit_log_target ON audit_log (target_type, target_id);
+	CREATE OR REPLACE FUNCTION protect_audit_log() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+	CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION protect_audit_log();
+	DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+	CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+		FOR EACH STATEMENT EXECUTE FUNCTION protect_audit_log();`
+
 	_, err := db.Exec(userTable)
 	util.CheckErr(err)
 	fmt.Println("User table created or already exists.")
@@ -41,4 +334,72 @@ func CreateTables(db *sql.DB) {
 	_, err = db.Exec(balanceTable)
 	util.CheckErr(err)
 	fmt.Println("Balance table created or already exists.")
+
+	_, err = db.Exec(exchangeTable)
+	util.CheckErr(err)
+	fmt.Println("Exchange table created or already exists.")
+
+	_, err = db.Exec(balanceAdjustmentTable)
+	util.CheckErr(err)
+	fmt.Println("Balance adjustment table created or already exists.")
+
+	_, err = db.Exec(ledgerEntryTable)
+	util.CheckErr(err)
+	fmt.Println("Ledger entry table created or already exists.")
+
+	_, err = db.Exec(tradingPairTable)
+	util.CheckErr(err)
+	fmt.Println("Trading pair table created or already exists.")
+
+	_, err = db.Exec(positionEventTable)
+	util.CheckErr(err)
+	fmt.Println("Position event table created or already exists.")
+
+	_, err = db.Exec(balanceSnapshotTable)
+	util.CheckErr(err)
+	fmt.Println("Balance snapshot table created or already exists.")
+
+	_, err = db.Exec(priceHistoryTable)
+	util.CheckErr(err)
+	fmt.Println("Price history table created or already exists.")
+
+	_, err = db.Exec(priceCandleTable)
+	util.CheckErr(err)
+	fmt.Println("Price candle table created or already exists.")
+
+	_, err = db.Exec(priceAlertTable)
+	util.CheckErr(err)
+	fmt.Println("Price alert table created or already exists.")
+
+	_, err = db.Exec(outboxTable)
+	util.CheckErr(err)
+	fmt.Println("Outbox table created or already exists.")
+
+	_, err = db.Exec(webhookEndpointTable)
+	util.CheckErr(err)
+	fmt.Println("Webhook endpoint table created or already exists.")
+
+	_, err = db.Exec(webhookDeliveryTable)
+	util.CheckErr(err)
+	fmt.Println("Webhook delivery table created or already exists.")
+
+	_, err = db.Exec(scheduleTable)
+	util.CheckErr(err)
+	fmt.Println("Schedule table created or already exists.")
+
+	_, err = db.Exec(userStatusChangeTable)
+	util.CheckErr(err)
+	fmt.Println("User status change table created or already exists.")
+
+	_, err = db.Exec(twoFactorTable)
+	util.CheckErr(err)
+	fmt.Println("Two-factor table created or already exists.")
+
+	_, err = db.Exec(recoveryCodeTable)
+	util.CheckErr(err)
+	fmt.Println("Recovery code table created or already exists.")
+
+	_, err = db.Exec(auditLogTable)
+	util.CheckErr(err)
+	fmt.Println("Audit log table created or already exists.")
 }
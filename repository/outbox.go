@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+
+	"github.com/lib/pq"
+)
+
+// outboxRelayLock is the advisory lock key held while relaying, so that with
+// several instances running only one publishes at a time and per-user order
+// is kept.
+const outboxRelayLock = 7263100
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Append writes an event to the outbox within tx, so it is recorded if and
+// only if the change it describes commits.
+func (r *OutboxRepository) Append(tx *sql.Tx, event model.OutboxEvent) error {
+	query := `
+		INSERT INTO outbox_events (event_id, event_type, user_id, payload)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := tx.Exec(query, event.EventID, event.EventType, event.UserID, event.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to outbox: %v", event.EventType, err)
+	}
+	return nil
+}
+
+// RelayBatch passes up to limit of the oldest unpublished events, in the
+// order they were written, to publish and marks the ids it returns as
+// published. It does nothing if another relay holds the lock.
+func (r *OutboxRepository) RelayBatch(limit int, publish func(events []model.OutboxEvent) []int64) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var locked bool
+	err = tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLock).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("failed to take outbox relay lock: %v", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	query := `
+		SELECT id, event_id, event_type, user_id, payload, created_at
+		FROM outbox_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+	rows, err := tx.Query(query, limit)
+	if err != nil {
+		return fmt.Errorf("failed to load outbox events: %v", err)
+	}
+
+	var events []model.OutboxEvent
+	for rows.Next() {
+		var event model.OutboxEvent
+		err = rows.Scan(&event.ID, &event.EventID, &event.EventType, &event.UserID, &event.Payload, &event.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	published := publish(events)
+	if len(published) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`UPDATE outbox_events SET published_at = NOW() WHERE id = ANY($1)`, pq.Array(published))
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox events published: %v", err)
+	}
+	return nil
+}
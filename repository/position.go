@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+)
+
+type PositionRepository struct {
+	db *sql.DB
+}
+
+func NewPositionRepository(db *sql.DB) *PositionRepository {
+	return &PositionRepository{db: db}
+}
+
+// RecordEvents stores position events within tx, so they commit or roll back
+// together with the balance changes they describe.
+func (r *PositionRepository) RecordEvents(tx *sql.Tx, events []model.PositionEvent) error {
+	query := `
+		INSERT INTO position_events (user_id, crypto_symbol, kind, amount, usd_value, source)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	for _, event := range events {
+		_, err := tx.Exec(query, event.UserID, event.CryptoSymbol, event.Kind, event.Amount, event.USDValue, event.Source)
+		if err != nil {
+			return fmt.Errorf("failed to record position event: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *PositionRepository) GetUserEvents(userID int) ([]model.PositionEvent, error) {
+	query := `
+		SELECT id, user_id, crypto_symbol, kind, amount, usd_value, source, created_at
+		FROM position_events
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.PositionEvent
+	for rows.Next() {
+		var event model.PositionEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.CryptoSymbol, &event.Kind, &event.Amount, &event.USDValue, &event.Source, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
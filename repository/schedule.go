@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+	"time"
+)
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository(db *sql.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+const scheduleColumns = `id, user_id, source_crypto, target_crypto, source_amount, recurrence,
+		interval_seconds, weekday, hour, minute, status, next_run_at, last_run_at,
+		last_result, consecutive_failures, two_factor_approved_at, created_at`
+
+func scanSchedule(row interface{ Scan(...interface{}) error }) (model.Schedule, error) {
+	var schedule model.Schedule
+	var lastRunAt, approvedAt sql.NullTime
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.UserID,
+		&schedule.SourceCrypto,
+		&schedule.TargetCrypto,
+		&schedule.SourceAmount,
+		&schedule.Recurrence,
+		&schedule.IntervalSeconds,
+		&schedule.Weekday,
+		&schedule.Hour,
+		&schedule.Minute,
+		&schedule.Status,
+		&schedule.NextRunAt,
+		&lastRunAt,
+		&schedule.LastResult,
+		&schedule.ConsecutiveFailures,
+		&approvedAt,
+		&schedule.CreatedAt,
+	)
+	if err != nil {
+		return schedule, err
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	if approvedAt.Valid {
+		schedule.TwoFactorApprovedAt = &approvedAt.Time
+	}
+	return schedule, nil
+}
+
+func (r *ScheduleRepository) CreateSchedule(schedule model.Schedule) (model.Schedule, error) {
+	query := `
+		INSERT INTO schedules (user_id, source_crypto, target_crypto, source_amount, recurrence,
+			interval_seconds, weekday, hour, minute, status, next_run_at, two_factor_approved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + scheduleColumns
+
+	created, err := scanSchedule(r.db.QueryRow(query,
+		schedule.UserID, schedule.SourceCrypto, schedule.TargetCrypto, schedule.SourceAmount,
+		schedule.Recurrence, schedule.IntervalSeconds, schedule.Weekday, schedule.Hour,
+		schedule.Minute, schedule.Status, schedule.NextRunAt, schedule.TwoFactorApprovedAt,
+	))
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("failed to create schedule: %v", err)
+	}
+	return created, nil
+}
+
+func (r *ScheduleRepository) GetSchedule(userID int, scheduleID int) (model.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1 AND user_id = $2`
+
+	schedule, err := scanSchedule(r.db.QueryRow(query, scheduleID, userID))
+	if err == sql.ErrNoRows {
+		return model.Schedule{}, fmt.Errorf("schedule %d: %w", scheduleID, ErrNotFound)
+	} else if err != nil {
+		return model.Schedule{}, err
+	}
+	return schedule, nil
+}
+
+func (r *ScheduleRepository) querySchedules(query string, args ...interface{}) ([]model.Schedule, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []model.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) GetUserSchedules(userID int) ([]model.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE user_id = $1 ORDER BY id`
+	return r.querySchedules(query, userID)
+}
+
+func (r *ScheduleRepository) GetDueSchedules(now time.Time) ([]model.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules
+		WHERE status = $1 AND next_run_at <= $2 ORDER BY next_run_at`
+	return r.querySchedules(query, model.ScheduleStatusActive, now)
+}
+
+// ClaimSchedule moves the next run forward only if no other worker has done so
+// already, so every occurrence is executed at most once.
+func (r *ScheduleRepository) ClaimSchedule(scheduleID int, currentRunAt, nextRunAt time.Time) (bool, error) {
+	query := `UPDATE schedules SET next_run_at = $1 WHERE id = $2 AND next_run_at = $3 AND status = $4`
+	res, err := r.db.Exec(query, nextRunAt, scheduleID, currentRunAt, model.ScheduleStatusActive)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim schedule: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RecordRun stores the outcome of a run. The run can only pause the
+// schedule: a schedule the user paused while it ran stays paused, and nothing
+// is recorded for one deleted meanwhile. Each of inTx runs afterwards inside
+// the same transaction, unless the schedule was deleted.
+func (r *ScheduleRepository) RecordRun(scheduleID int, runAt time.Time, result string, consecutiveFailures int, status string, inTx ...func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var current string
+	err = tx.QueryRow(`SELECT status FROM schedules WHERE id = $1 FOR UPDATE`, scheduleID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock schedule: %v", err)
+	}
+
+	query := `
+		UPDATE schedules
+		SET last_run_at = $1, last_result = $2, consecutive_failures = $3, status = $4
+		WHERE id = $5
+	`
+	_, err = tx.Exec(query, runAt, result, consecutiveFailures, statusAfterRun(current, status), scheduleID)
+	if err != nil {
+		return fmt.Errorf("failed to record schedule run: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statusAfterRun is the status of a schedule that was current when a run
+// ending in status was recorded. Runs may pause a schedule but never
+// reactivate one.
+func statusAfterRun(current, status string) string {
+	if status == model.ScheduleStatusPaused {
+		return model.ScheduleStatusPaused
+	}
+	return current
+}
+
+func (r *ScheduleRepository) UpdateStatus(userID int, scheduleID int, status string, nextRunAt time.Time) error {
+	query := `
+		UPDATE schedules
+		SET status = $1, next_run_at = $2, consecutive_failures = 0
+		WHERE id = $3 AND user_id = $4
+	`
+	res, err := r.db.Exec(query, status, nextRunAt, scheduleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule status: %v", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("schedule %d: %w", scheduleID, ErrNotFound)
+	}
+	return nil
+}
+
+// ApproveTwoFactor records that the user approved the schedule's runs with a
+// two-factor code at approvedAt.
+func (r *ScheduleRepository) ApproveTwoFactor(userID int, scheduleID int, approvedAt time.Time) error {
+	res, err := r.db.Exec(`UPDATE schedules SET two_factor_approved_at = $1 WHERE id = $2 AND user_id = $3`,
+		approvedAt, scheduleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to approve schedule: %v", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("schedule %d: %w", scheduleID, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *ScheduleRepository) DeleteSchedule(userID int, scheduleID int) error {
+	res, err := r.db.Exec(`DELETE FROM schedules WHERE id = $1 AND user_id = $2`, scheduleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %v", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("schedule %d: %w", scheduleID, ErrNotFound)
+	}
+	return nil
+}
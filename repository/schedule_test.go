@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+
+	"swap-wallet/model"
+)
+
+func TestStatusAfterRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		status  string
+		want    string
+	}{
+		{"active run of active schedule", model.ScheduleStatusActive, model.ScheduleStatusActive, model.ScheduleStatusActive},
+		{"run pauses active schedule", model.ScheduleStatusActive, model.ScheduleStatusPaused, model.ScheduleStatusPaused},
+		{"paused during run", model.ScheduleStatusPaused, model.ScheduleStatusActive, model.ScheduleStatusPaused},
+		{"paused during run that pauses", model.ScheduleStatusPaused, model.ScheduleStatusPaused, model.ScheduleStatusPaused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusAfterRun(tt.current, tt.status); got != tt.want {
+				t.Errorf("statusAfterRun(%s, %s) = %s, want %s", tt.current, tt.status, got, tt.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ func SeedPostgresData(db *sql.DB) {
 	seedCryptocurrencies(db)
 	seedUsers(db)
 	seedBalances(db)
+	seedTradingPairs(db)
 
 }
 
@@ -50,7 +51,11 @@ func seedUsers(db *sql.DB) {
 	util.CheckErr(err)
 
 	for _, user := range users {
-		_, err = db.Exec("INSERT INTO users (username) VALUES ($1)", user.Username)
+		role := user.Role
+		if role == "" {
+			role = model.RoleUser
+		}
+		_, err = db.Exec("INSERT INTO users (username, role) VALUES ($1, $2)", user.Username, role)
 		util.CheckErr(err)
 		fmt.Printf("Inserted user: %s\n", user.Username)
 	}
@@ -77,3 +82,25 @@ func seedBalances(db *sql.DB) {
 		fmt.Printf("Inserted balance for user_id: %d, crypto_id: %d\n", balance.UserID, balance.CryptoID)
 	}
 }
+
+func seedTradingPairs(db *sql.DB) {
+	file, err := os.Open("/app/data/trading_pairs.json")
+	util.CheckErr(err)
+	defer file.Close()
+
+	byteValue, err := ioutil.ReadAll(file)
+	util.CheckErr(err)
+
+	var pairs []model.TradingPair
+	err = json.Unmarshal(byteValue, &pairs)
+	util.CheckErr(err)
+
+	for _, pair := range pairs {
+		_, err = db.Exec(
+			"INSERT INTO trading_pairs (base_crypto_id, quote_crypto_id, fee_rate, spread, is_enabled) VALUES ($1, $2, $3, $4, $5)",
+			pair.BaseCryptoID, pair.QuoteCryptoID, pair.FeeRate, pair.Spread, pair.IsEnabled,
+		)
+		util.CheckErr(err)
+		fmt.Printf("Inserted trading pair: %d/%d\n", pair.BaseCryptoID, pair.QuoteCryptoID)
+	}
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+	"time"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db: db,
+	}
+}
+
+const twoFactorColumns = `user_id, secret, enabled_at, last_used_step, failed_attempts, locked_until, created_at`
+
+func scanTwoFactor(row interface{ Scan(...interface{}) error }) (model.TwoFactor, error) {
+	var twoFactor model.TwoFactor
+	var enabledAt, lockedUntil sql.NullTime
+	err := row.Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&enabledAt,
+		&twoFactor.LastUsedStep,
+		&twoFactor.FailedAttempts,
+		&lockedUntil,
+		&twoFactor.CreatedAt,
+	)
+	if err != nil {
+		return twoFactor, err
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+	if lockedUntil.Valid {
+		twoFactor.LockedUntil = &lockedUntil.Time
+	}
+	return twoFactor, nil
+}
+
+// Get returns the user's TOTP enrolment, pending or enabled.
+func (r *TwoFactorRepository) Get(userId int) (model.TwoFactor, error) {
+	query := `SELECT ` + twoFactorColumns + ` FROM user_totp WHERE user_id = $1`
+
+	twoFactor, err := scanTwoFactor(r.db.QueryRow(query, userId))
+	if err == sql.ErrNoRows {
+		return model.TwoFactor{}, fmt.Errorf("two-factor enrolment of user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.TwoFactor{}, fmt.Errorf("failed to get two-factor enrolment: %v", err)
+	}
+	return twoFactor, nil
+}
+
+// SetPending starts an enrolment with secret, replacing any pending one. A
+// lockout stays in force, so restarting cannot skip it. It fails with
+// ErrTwoFactorEnabled when the user already has TOTP enabled.
+func (r *TwoFactorRepository) SetPending(userId int, secret string) (model.TwoFactor, error) {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, failed_attempts = 0, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL
+		RETURNING ` + twoFactorColumns
+
+	twoFactor, err := scanTwoFactor(r.db.QueryRow(query, userId, secret))
+	if err == sql.ErrNoRows {
+		return model.TwoFactor{}, fmt.Errorf("user %d: %w", userId, ErrTwoFactorEnabled)
+	}
+	if err != nil {
+		return model.TwoFactor{}, fmt.Errorf("failed to start two-factor enrolment: %v", err)
+	}
+	return twoFactor, nil
+}
+
+// Enable confirms the pending enrolment with the code of step and stores
+// codeHashes as the user's recovery codes. Each of inTx runs afterwards
+// inside the same transaction.
+func (r *TwoFactorRepository) Enable(userId int, step int64, codeHashes []string, inTx ...func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	result, err := tx.Exec(`
+		UPDATE user_totp
+		SET enabled_at = NOW(), last_used_step = $2, failed_attempts = 0, locked_until = NULL
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`, userId, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("user %d: %w", userId, ErrTwoFactorEnabled)
+	}
+
+	err = replaceRecoveryCodes(tx, userId, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes, used or not, for
+// codeHashes. Each of inTx runs afterwards inside the same transaction.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userId int, codeHashes []string, inTx ...func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = replaceRecoveryCodes(tx, userId, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId int, codeHashes []string) error {
+	_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	for _, hash := range codeHashes {
+		_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userId, hash)
+		if err != nil {
+			return fmt.Errorf("failed to store recovery code: %v", err)
+		}
+	}
+	return nil
+}
+
+// Disable removes the user's TOTP enrolment and recovery codes. Each of inTx
+// runs afterwards inside the same transaction.
+func (r *TwoFactorRepository) Disable(userId int, inTx ...func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	_, err = tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseStep records that the code of step was used. It reports false when a
+// code of step or a later one was already used, so a code cannot be
+// replayed.
+func (r *TwoFactorRepository) UseStep(userId int, step int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE user_totp
+		SET last_used_step = $2, failed_attempts = 0
+		WHERE user_id = $1 AND last_used_step < $2
+	`, userId, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code use: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code use: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// UseRecoveryCode marks the user's unused recovery code with codeHash as
+// used. It reports false when there is no such code.
+func (r *TwoFactorRepository) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE recovery_codes
+		SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		)
+	`, userId, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	if affected == 1 {
+		_, err = r.db.Exec(`UPDATE user_totp SET failed_attempts = 0 WHERE user_id = $1`, userId)
+		if err != nil {
+			return false, fmt.Errorf("failed to reset two-factor failures: %v", err)
+		}
+	}
+	return affected == 1, nil
+}
+
+// CountRecoveryCodes returns how many of the user's recovery codes are
+// unused.
+func (r *TwoFactorRepository) CountRecoveryCodes(userId int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %v", err)
+	}
+	return count, nil
+}
+
+// RecordFailure counts an invalid code. Reaching maxFailures in a row locks
+// the user out of two-factor checks for lockout and starts the count again.
+func (r *TwoFactorRepository) RecordFailure(userId int, maxFailures int, lockout time.Duration) (model.TwoFactor, error) {
+	query := `
+		UPDATE user_totp
+		SET failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN NOW() + make_interval(secs => $3) ELSE locked_until END
+		WHERE user_id = $1
+		RETURNING ` + twoFactorColumns
+
+	twoFactor, err := scanTwoFactor(r.db.QueryRow(query, userId, maxFailures, lockout.Seconds()))
+	if err == sql.ErrNoRows {
+		return model.TwoFactor{}, fmt.Errorf("two-factor enrolment of user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.TwoFactor{}, fmt.Errorf("failed to record two-factor failure: %v", err)
+	}
+	return twoFactor, nil
+}
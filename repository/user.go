@@ -2,6 +2,11 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"swap-wallet/model"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository struct {
@@ -28,3 +33,350 @@ func (r *UserRepository) GetUsername(userId int) (string, error) {
 
 	return username, nil
 }
+
+const userColumns = `id, username, role, status, display_name, COALESCE(email, ''), quote_currency, locale,
+		created_at, updated_at, deleted_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (model.User, error) {
+	var user model.User
+	var deletedAt sql.NullTime
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Role,
+		&user.Status,
+		&user.DisplayName,
+		&user.Email,
+		&user.QuoteCurrency,
+		&user.Locale,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&deletedAt,
+	)
+	if err != nil {
+		return user, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return user, nil
+}
+
+// uniqueViolation converts a unique violation on users into ErrUsernameTaken
+// or ErrEmailTaken, and returns nil for any other error.
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return nil
+	}
+	if pqErr.Constraint == "users_email_key" {
+		return ErrEmailTaken
+	}
+	return ErrUsernameTaken
+}
+
+// GetUser returns the user with userId. Deleted users are not found.
+func (r *UserRepository) GetUser(userId int) (model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	user, err := scanUser(r.db.QueryRow(query, userId))
+	if err == sql.ErrNoRows {
+		return model.User{}, fmt.Errorf("user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to get user: %v", err)
+	}
+	return user, nil
+}
+
+// FindByUsername returns the user named username. Deleted users are not
+// found.
+func (r *UserRepository) FindByUsername(username string) (model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1 AND deleted_at IS NULL`
+
+	user, err := scanUser(r.db.QueryRow(query, username))
+	if err == sql.ErrNoRows {
+		return model.User{}, fmt.Errorf("user %s: %w", username, ErrNotFound)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to find user: %v", err)
+	}
+	return user, nil
+}
+
+// FindByEmail returns the user registered with email. Deleted users are not
+// found.
+func (r *UserRepository) FindByEmail(email string) (model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+
+	user, err := scanUser(r.db.QueryRow(query, email))
+	if err == sql.ErrNoRows {
+		return model.User{}, fmt.Errorf("user with email: %w", ErrNotFound)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to find user: %v", err)
+	}
+	return user, nil
+}
+
+// CreateUser inserts an active user with the user role and profile. A taken
+// username or email fails with ErrUsernameTaken or ErrEmailTaken. Each of
+// inTx runs afterwards inside the same transaction.
+func (r *UserRepository) CreateUser(username string, profile model.UserProfile, inTx ...func(tx *sql.Tx, user model.User) error) (created model.User, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO users (username, role, display_name, email, quote_currency, locale)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING ` + userColumns
+	created, err = scanUser(tx.QueryRow(query, username, model.RoleUser, profile.DisplayName, profile.Email, profile.QuoteCurrency, profile.Locale))
+	if err != nil {
+		if taken := uniqueViolation(err); taken != nil {
+			return model.User{}, taken
+		}
+		return model.User{}, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, created)
+		if err != nil {
+			return model.User{}, err
+		}
+	}
+
+	return created, nil
+}
+
+// UpdateProfile replaces the profile of the user. A taken email fails with
+// ErrEmailTaken. Each of inTx runs afterwards inside the same transaction
+// with the user before and after the change.
+func (r *UserRepository) UpdateProfile(userId int, profile model.UserProfile, inTx ...func(tx *sql.Tx, before, after model.User) error) (updated model.User, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userId))
+	if err == sql.ErrNoRows {
+		return model.User{}, fmt.Errorf("user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	query := `
+		UPDATE users
+		SET display_name = $2, email = NULLIF($3, ''), quote_currency = $4, locale = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + userColumns
+	updated, err = scanUser(tx.QueryRow(query, userId, profile.DisplayName, profile.Email, profile.QuoteCurrency, profile.Locale))
+	if err != nil {
+		if taken := uniqueViolation(err); taken != nil {
+			return model.User{}, taken
+		}
+		return model.User{}, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, before, updated)
+		if err != nil {
+			return model.User{}, err
+		}
+	}
+
+	return updated, nil
+}
+
+// DeleteUser marks the user deleted, keeping the row and everything that
+// references it. The user's schedules are paused and alerts and webhook
+// endpoints deactivated so nothing runs on behalf of the account any more.
+// A user who still holds a balance fails with ErrAccountNotEmpty. Each of
+// inTx runs afterwards inside the same transaction.
+func (r *UserRepository) DeleteUser(userId int, inTx ...func(tx *sql.Tx, user model.User) error) (deleted model.User, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Locking the user first makes exchanges, which share-lock it, finish
+	// before the balances are checked.
+	_, err = scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userId))
+	if err == sql.ErrNoRows {
+		return model.User{}, fmt.Errorf("user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	var holdsBalance bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM balances WHERE user_id = $1 AND balance <> 0)`, userId).Scan(&holdsBalance)
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to check user balances: %v", err)
+	}
+	if holdsBalance {
+		return model.User{}, fmt.Errorf("user %d: %w", userId, ErrAccountNotEmpty)
+	}
+
+	deleted, err = scanUser(tx.QueryRow(`UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 RETURNING `+userColumns, userId))
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE schedules SET status = $2 WHERE user_id = $1`, userId, model.ScheduleStatusPaused)
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to pause schedules: %v", err)
+	}
+	_, err = tx.Exec(`UPDATE price_alerts SET is_active = FALSE WHERE user_id = $1`, userId)
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to deactivate alerts: %v", err)
+	}
+	_, err = tx.Exec(`UPDATE webhook_endpoints SET is_active = FALSE WHERE user_id = $1`, userId)
+	if err != nil {
+		return model.User{}, fmt.Errorf("failed to deactivate webhook endpoints: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, deleted)
+		if err != nil {
+			return model.User{}, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// LockStatus returns the user's status and holds a share lock on the user
+// until tx ends, so a status change waits for tx to commit.
+func (r *UserRepository) LockStatus(tx *sql.Tx, userId int) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM users WHERE id = $1 AND deleted_at IS NULL FOR SHARE`, userId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user status: %v", err)
+	}
+	return status, nil
+}
+
+// SetStatus changes the user's status and records the change with its reason
+// in one transaction. Each of inTx runs afterwards inside the same
+// transaction.
+func (r *UserRepository) SetStatus(userId int, status, reason string, changedBy int, inTx ...func(tx *sql.Tx, change model.UserStatusChange) error) (change model.UserStatusChange, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.UserStatusChange{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	change = model.UserStatusChange{
+		UserID:    userId,
+		ToStatus:  status,
+		Reason:    reason,
+		ChangedBy: changedBy,
+	}
+	err = tx.QueryRow(`SELECT status FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userId).Scan(&change.FromStatus)
+	if err == sql.ErrNoRows {
+		return model.UserStatusChange{}, fmt.Errorf("user %d: %w", userId, ErrNotFound)
+	}
+	if err != nil {
+		return model.UserStatusChange{}, fmt.Errorf("failed to get user status: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE users SET status = $2 WHERE id = $1`, userId, status)
+	if err != nil {
+		return model.UserStatusChange{}, fmt.Errorf("failed to update user status: %v", err)
+	}
+
+	query := `
+		INSERT INTO user_status_changes (user_id, from_status, to_status, reason, changed_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(query, change.UserID, change.FromStatus, change.ToStatus, change.Reason, change.ChangedBy).
+		Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return model.UserStatusChange{}, fmt.Errorf("failed to record user status change: %v", err)
+	}
+
+	for _, fn := range inTx {
+		err = fn(tx, change)
+		if err != nil {
+			return model.UserStatusChange{}, err
+		}
+	}
+
+	return change, nil
+}
+
+// GetStatusHistory lists the user's status changes, newest first.
+func (r *UserRepository) GetStatusHistory(userId int) ([]model.UserStatusChange, error) {
+	query := `
+		SELECT id, user_id, from_status, to_status, reason, changed_by, created_at
+		FROM user_status_changes
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`
+
+	rows, err := r.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user status changes: %v", err)
+	}
+	defer rows.Close()
+
+	changes := []model.UserStatusChange{}
+	for rows.Next() {
+		var change model.UserStatusChange
+		err := rows.Scan(&change.ID, &change.UserID, &change.FromStatus, &change.ToStatus, &change.Reason, &change.ChangedBy, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user status change: %v", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list user status changes: %v", err)
+	}
+	return changes, nil
+}
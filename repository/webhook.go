@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"swap-wallet/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const deliveryColumns = `d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+		d.next_attempt_at, d.last_response_code, d.last_error, d.delivered_at, d.created_at`
+
+// scanDelivery scans the columns of deliveryColumns followed by any extra
+// columns into extra.
+func scanDelivery(row interface{ Scan(...interface{}) error }, extra ...interface{}) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	var responseCode sql.NullInt64
+	var deliveredAt sql.NullTime
+	dest := []interface{}{
+		&delivery.ID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&responseCode,
+		&delivery.LastError,
+		&deliveredAt,
+		&delivery.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return delivery, err
+	}
+	if responseCode.Valid {
+		code := int(responseCode.Int64)
+		delivery.LastResponseCode = &code
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return delivery, nil
+}
+
+func (r *WebhookRepository) CreateEndpoint(endpoint model.WebhookEndpoint) (model.WebhookEndpoint, error) {
+	query := `
+		INSERT INTO webhook_endpoints (user_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, is_active, created_at
+	`
+	err := r.db.QueryRow(query, endpoint.UserID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.EventTypes)).
+		Scan(&endpoint.ID, &endpoint.IsActive, &endpoint.CreatedAt)
+	if err != nil {
+		return model.WebhookEndpoint{}, fmt.Errorf("failed to create webhook endpoint: %v", err)
+	}
+	return endpoint, nil
+}
+
+// GetUserEndpoints lists the user's endpoints without their secrets.
+func (r *WebhookRepository) GetUserEndpoints(userID int) ([]model.WebhookEndpoint, error) {
+	query := `
+		SELECT id, user_id, url, event_types, is_active, created_at
+		FROM webhook_endpoints
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []model.WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint model.WebhookEndpoint
+		err := rows.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, pq.Array(&endpoint.EventTypes), &endpoint.IsActive, &endpoint.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) DeleteEndpoint(userID, endpointID int) error {
+	result, err := r.db.Exec(`DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2`, endpointID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook endpoint %d: %w", endpointID, ErrNotFound)
+	}
+	return nil
+}
+
+// EnqueueEvent queues payload for every active endpoint of the user that
+// subscribes to eventType. It runs within tx so events are only queued when
+// the change they describe commits.
+func (r *WebhookRepository) EnqueueEvent(tx *sql.Tx, userID int, eventID, eventType, payload string) error {
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		SELECT id, $3, $4, $5
+		FROM webhook_endpoints
+		WHERE user_id = $1 AND is_active AND $2 = ANY(event_types)
+	`
+	_, err := tx.Exec(query, userID, eventType, eventID, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue %s webhook: %v", eventType, err)
+	}
+	return nil
+}
+
+// GetEndpointDeliveries returns the most recent deliveries to one of the
+// user's endpoints, newest first.
+func (r *WebhookRepository) GetEndpointDeliveries(userID, endpointID, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE e.user_id = $1 AND e.id = $2
+		ORDER BY d.id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(query, userID, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// DueDelivery is a pending delivery together with where and how to send it.
+type DueDelivery struct {
+	model.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimDueDeliveries claims the limit pending deliveries that have been due
+// the longest by moving their next attempt to leaseUntil. Rows
+// another instance is claiming are skipped, so each attempt is made once; a
+// claimed delivery whose attempt is never recorded is due again after
+// leaseUntil.
+func (r *WebhookRepository) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]DueDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $3
+		FROM webhook_endpoints e
+		WHERE e.id = d.endpoint_id AND d.id IN (
+			SELECT due.id
+			FROM webhook_deliveries due
+			JOIN webhook_endpoints due_endpoint ON due_endpoint.id = due.endpoint_id
+			WHERE due.status = $1 AND due.next_attempt_at <= $2 AND due_endpoint.is_active
+			ORDER BY due.next_attempt_at, due.id
+			LIMIT $4
+			FOR UPDATE OF due SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `, e.url, e.secret
+	`
+
+	rows, err := r.db.Query(query, model.DeliveryPending, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []DueDelivery
+	for rows.Next() {
+		var due DueDelivery
+		due.WebhookDelivery, err = scanDelivery(rows, &due.URL, &due.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, due)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt. responseCode is nil
+// when no response was received.
+func (r *WebhookRepository) RecordAttempt(deliveryID int, status string, responseCode *int, lastError string, nextAttemptAt time.Time, deliveredAt *time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_response_code = $3, last_error = $4,
+			next_attempt_at = $5, delivered_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(query, deliveryID, status, responseCode, lastError, nextAttemptAt, deliveredAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %v", err)
+	}
+	return nil
+}
+
+// Redeliver queues a delivery to one of the user's endpoints again from its
+// first attempt, whatever its current status.
+func (r *WebhookRepository) Redeliver(userID, endpointID, deliveryID int) (model.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET status = $4, attempts = 0, next_attempt_at = NOW(), last_error = ''
+		FROM webhook_endpoints e
+		WHERE e.id = d.endpoint_id AND e.user_id = $1 AND e.id = $2 AND d.id = $3
+		RETURNING ` + deliveryColumns
+
+	delivery, err := scanDelivery(r.db.QueryRow(query, userID, endpointID, deliveryID, model.DeliveryPending))
+	if err == sql.ErrNoRows {
+		return model.WebhookDelivery{}, fmt.Errorf("webhook delivery %d: %w", deliveryID, ErrNotFound)
+	}
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("failed to redeliver webhook: %v", err)
+	}
+	return delivery, nil
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+)
+
+// AdjustmentService lets admins credit or debit user balances by hand. Every
+// adjustment is kept in the balance_adjustments audit trail; those worth more
+// than the approval threshold wait for a second admin before touching the
+// balance.
+type AdjustmentService struct {
+	balanceRepo  *repository.BalanceRepository
+	cryptoRepo   *repository.CryptocurrencyRepository
+	userRepo     *repository.UserRepository
+	positionRepo *repository.PositionRepository
+	prices       *PriceProvider
+	events       *EventService
+	audit        *AuditService
+	twoFactor    *TwoFactorService
+
+	approvalThreshold float64
+}
+
+type CreateAdjustmentRequest struct {
+	UserID        int     `json:"userId" required:"true" doc:"Id of the user whose balance is adjusted"`
+	Crypto        string  `json:"crypto" required:"true"`
+	Amount        float64 `json:"amount" required:"true" doc:"Positive to credit, negative to debit"`
+	ReasonCode    string  `json:"reasonCode" required:"true" enum:"deposit_correction,withdrawal_correction,fee_refund,goodwill,chargeback,migration"`
+	Justification string  `json:"justification" required:"true" doc:"Why the adjustment is needed, for the audit trail"`
+}
+
+type AdjustmentDecision struct {
+	Note string `json:"note" doc:"Optional comment recorded with the decision"`
+}
+
+func NewAdjustmentService(balanceRepo *repository.BalanceRepository, cryptoRepo *repository.CryptocurrencyRepository, userRepo *repository.UserRepository, positionRepo *repository.PositionRepository, prices *PriceProvider, events *EventService, audit *AuditService, twoFactor *TwoFactorService) *AdjustmentService {
+	return &AdjustmentService{
+		balanceRepo:  balanceRepo,
+		cryptoRepo:   cryptoRepo,
+		userRepo:     userRepo,
+		positionRepo: positionRepo,
+		prices:       prices,
+		events:       events,
+		audit:        audit,
+		twoFactor:    twoFactor,
+
+		approvalThreshold: config.LoadAdjustmentApprovalThreshold(),
+	}
+}
+
+func validateAdjustment(req CreateAdjustmentRequest) error {
+	if req.UserID <= 0 {
+		return invalidRequest("userId is required")
+	}
+	if req.Crypto == "" {
+		return invalidRequest("crypto is required")
+	}
+	if req.Amount == 0 || math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
+		return invalidRequest("amount must be a non-zero number")
+	}
+
+	validReason := false
+	for _, reason := range model.AdjustmentReasons {
+		if req.ReasonCode == reason {
+			validReason = true
+			break
+		}
+	}
+	if !validReason {
+		return invalidRequest("unsupported reasonCode: %s", req.ReasonCode)
+	}
+
+	justification := strings.TrimSpace(req.Justification)
+	if len(justification) < config.MinAdjustmentJustification {
+		return invalidRequest("justification must be at least %d characters", config.MinAdjustmentJustification)
+	}
+	if len(justification) > config.MaxAdjustmentJustification {
+		return invalidRequest("justification must be at most %d characters", config.MaxAdjustmentJustification)
+	}
+	return nil
+}
+
+// needsApproval reports whether an adjustment is worth more than the approval
+// threshold. Adjustments that cannot be valued are treated as above it.
+func (s *AdjustmentService) needsApproval(crypto string, amount float64) bool {
+	if s.approvalThreshold <= 0 {
+		return false
+	}
+	price, err := s.prices.Price(crypto, "USD")
+	if err != nil {
+		return true
+	}
+	return math.Abs(amount)*price > s.approvalThreshold
+}
+
+// CreateAdjustment records adminID's adjustment request. It is applied at
+// once unless it needs a second admin's approval, in which case it is left
+// pending. Debits take funds out of a user's balance like a withdrawal, so
+// they need adminID's two-factor code.
+func (s *AdjustmentService) CreateAdjustment(ctx context.Context, adminID int, req CreateAdjustmentRequest, totpCode string) (model.BalanceAdjustment, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+	if err := validateAdjustment(req); err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+
+	_, err := s.userRepo.GetUser(req.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.BalanceAdjustment{}, newError(CodeInvalidUser, err, "user %d does not exist", req.UserID)
+	}
+	if err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+
+	cryptoID, err := s.cryptoRepo.FindBySymbol(req.Crypto)
+	if err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+	if cryptoID == -1 {
+		return model.BalanceAdjustment{}, newError(CodeUnknownAsset, nil, "unknown cryptocurrency: %s", req.Crypto)
+	}
+	if req.Amount < 0 {
+		if err := s.twoFactor.Verify(ctx, adminID, totpCode); err != nil {
+			return model.BalanceAdjustment{}, err
+		}
+	}
+
+	status := model.AdjustmentApplied
+	if s.needsApproval(req.Crypto, req.Amount) {
+		status = model.AdjustmentPending
+	}
+
+	adjustment, err := s.balanceRepo.CreateAdjustment(model.BalanceAdjustment{
+		UserID:        req.UserID,
+		CryptoSymbol:  req.Crypto,
+		Amount:        req.Amount,
+		ReasonCode:    req.ReasonCode,
+		Justification: strings.TrimSpace(req.Justification),
+		Status:        status,
+		RequestedBy:   adminID,
+	}, s.publishApplied, func(tx *sql.Tx, adjustment model.BalanceAdjustment) error {
+		return s.audit.RecordAdjustment(ctx, tx, model.AuditAdjustmentRequested, adminID, nil, adjustment)
+	})
+	return adjustment, adjustmentError(err)
+}
+
+// ApproveAdjustment applies a pending adjustment. The approving admin must
+// not be the one who requested it, and must give their two-factor code to
+// apply a debit.
+func (s *AdjustmentService) ApproveAdjustment(ctx context.Context, adminID, adjustmentID int, decision AdjustmentDecision, totpCode string) (model.BalanceAdjustment, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+
+	adjustment, err := s.balanceRepo.GetAdjustment(adjustmentID)
+	if err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+	if adjustment.RequestedBy == adminID {
+		return model.BalanceAdjustment{}, newError(CodeForbidden, nil, "an adjustment must be approved by a second admin")
+	}
+	if adjustment.Amount < 0 {
+		if err := s.twoFactor.Verify(ctx, adminID, totpCode); err != nil {
+			return model.BalanceAdjustment{}, err
+		}
+	}
+
+	pending := adjustment
+	adjustment, err = s.balanceRepo.DecideAdjustment(adjustmentID, adminID, model.AdjustmentApplied, strings.TrimSpace(decision.Note), s.publishApplied, func(tx *sql.Tx, adjustment model.BalanceAdjustment) error {
+		return s.audit.RecordAdjustment(ctx, tx, model.AuditAdjustmentApproved, adminID, &pending, adjustment)
+	})
+	return adjustment, adjustmentError(err)
+}
+
+// RejectAdjustment closes a pending adjustment without changing the balance.
+// Any admin, including the requester, may reject.
+func (s *AdjustmentService) RejectAdjustment(ctx context.Context, adminID, adjustmentID int, decision AdjustmentDecision) (model.BalanceAdjustment, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+
+	pending, err := s.balanceRepo.GetAdjustment(adjustmentID)
+	if err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+
+	adjustment, err := s.balanceRepo.DecideAdjustment(adjustmentID, adminID, model.AdjustmentRejected, strings.TrimSpace(decision.Note), func(tx *sql.Tx, adjustment model.BalanceAdjustment) error {
+		return s.audit.RecordAdjustment(ctx, tx, model.AuditAdjustmentRejected, adminID, &pending, adjustment)
+	})
+	return adjustment, adjustmentError(err)
+}
+
+func (s *AdjustmentService) GetAdjustment(ctx context.Context, adminID, adjustmentID int) (model.BalanceAdjustment, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return model.BalanceAdjustment{}, err
+	}
+	return s.balanceRepo.GetAdjustment(adjustmentID)
+}
+
+// GetAdjustments lists adjustments, newest first, optionally only those with
+// status or for userID.
+func (s *AdjustmentService) GetAdjustments(ctx context.Context, adminID int, status string, userID, limit int) ([]model.BalanceAdjustment, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "", model.AdjustmentPending, model.AdjustmentApplied, model.AdjustmentRejected:
+	default:
+		return nil, invalidRequest("unsupported status: %s", status)
+	}
+	if limit <= 0 {
+		limit = config.DefaultAdjustmentsListed
+	}
+	if limit > config.MaxAdjustmentsListed {
+		limit = config.MaxAdjustmentsListed
+	}
+
+	return s.balanceRepo.GetAdjustments(repository.AdjustmentFilter{
+		Status: status,
+		UserID: userID,
+		Limit:  limit,
+	})
+}
+
+// publishApplied records the position event and balance.changed of an
+// applied adjustment, inside the transaction that applies it.
+func (s *AdjustmentService) publishApplied(tx *sql.Tx, adjustment model.BalanceAdjustment) error {
+	if adjustment.Status != model.AdjustmentApplied {
+		return nil
+	}
+
+	positionEvent, err := s.adjustmentPositionEvent(adjustment)
+	if err != nil {
+		return err
+	}
+	err = s.positionRepo.RecordEvents(tx, []model.PositionEvent{positionEvent})
+	if err != nil {
+		return err
+	}
+
+	return s.events.PublishAdjustment(tx, adjustment)
+}
+
+// adjustmentPositionEvent values an adjustment in USD for cost basis: a
+// credit acquires the asset and a debit disposes of it at the market price.
+func (s *AdjustmentService) adjustmentPositionEvent(adjustment model.BalanceAdjustment) (model.PositionEvent, error) {
+	usdPrice, err := s.prices.Price(adjustment.CryptoSymbol, "USD")
+	if err != nil {
+		return model.PositionEvent{}, fmt.Errorf("failed to value %s for cost basis: %w", adjustment.CryptoSymbol, err)
+	}
+
+	kind := model.PositionAcquisition
+	if adjustment.Amount < 0 {
+		kind = model.PositionDisposal
+	}
+	amount := math.Abs(adjustment.Amount)
+	return model.PositionEvent{
+		UserID:       adjustment.UserID,
+		CryptoSymbol: adjustment.CryptoSymbol,
+		Kind:         kind,
+		Amount:       amount,
+		USDValue:     amount * usdPrice,
+		Source:       model.PositionSourceAdjustment,
+	}, nil
+}
+
+func adjustmentError(err error) error {
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		return newError(CodeInsufficientFund, err, "debit would leave the balance negative")
+	}
+	if errors.Is(err, repository.ErrAlreadyDecided) {
+		return newError(CodeAlreadyDecided, err, "adjustment has already been decided")
+	}
+	return err
+}
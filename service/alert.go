@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/mail"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+type AlertService struct {
+	alertRepo *repository.AlertRepository
+	prices    *PriceProvider
+	notifiers map[string]Notifier
+}
+
+type CreateAlertRequest struct {
+	CryptoSymbol    string  `json:"crypto" required:"true"`
+	QuoteSymbol     string  `json:"quote" doc:"Default USD"`
+	Condition       string  `json:"condition" required:"true" enum:"above,below,percent_change"`
+	Threshold       float64 `json:"threshold" required:"true" minimum:"0" doc:"Price, or percentage for percent_change"`
+	Channel         string  `json:"channel" required:"true" enum:"webhook,email"`
+	Target          string  `json:"target" required:"true" doc:"Webhook URL or email address"`
+	CooldownSeconds int     `json:"cooldownSeconds" minimum:"0" doc:"Default one hour"`
+}
+
+// NewAlertService takes the notifier for every supported channel; alerts can
+// only be created for channels present in notifiers.
+func NewAlertService(alertRepo *repository.AlertRepository, prices *PriceProvider, notifiers map[string]Notifier) *AlertService {
+	return &AlertService{
+		alertRepo: alertRepo,
+		prices:    prices,
+		notifiers: notifiers,
+	}
+}
+
+func (s *AlertService) validateAlert(ctx context.Context, req CreateAlertRequest) error {
+	if req.CryptoSymbol == "" {
+		return invalidRequest("crypto is required")
+	}
+
+	switch req.Condition {
+	case model.AlertAbove, model.AlertBelow, model.AlertPercentChange:
+	default:
+		return invalidRequest("unsupported condition: %s", req.Condition)
+	}
+	if req.Threshold <= 0 {
+		return invalidRequest("threshold must be positive")
+	}
+
+	if _, ok := s.notifiers[req.Channel]; !ok {
+		return invalidRequest("unsupported notification channel: %s", req.Channel)
+	}
+	switch req.Channel {
+	case model.ChannelWebhook:
+		if err := checkOutboundURL(ctx, req.Target); err != nil {
+			return invalidRequest("webhook target %v", err)
+		}
+	case model.ChannelEmail:
+		if _, err := mail.ParseAddress(req.Target); err != nil {
+			return invalidRequest("email target must be a valid address")
+		}
+	}
+
+	if req.CooldownSeconds != 0 && req.CooldownSeconds < config.MinAlertCooldown {
+		return invalidRequest("cooldownSeconds must be at least %d", config.MinAlertCooldown)
+	}
+
+	return nil
+}
+
+func (s *AlertService) CreateAlert(ctx context.Context, userID int, req CreateAlertRequest) (model.PriceAlert, error) {
+	req.CryptoSymbol = strings.ToUpper(req.CryptoSymbol)
+	req.QuoteSymbol = strings.ToUpper(req.QuoteSymbol)
+	if req.QuoteSymbol == "" {
+		req.QuoteSymbol = DefaultQuoteCurrency
+	}
+	if req.CooldownSeconds == 0 {
+		req.CooldownSeconds = config.DefaultAlertCooldown
+	}
+	// Only the bare address is stored, so a display name or comment can
+	// never reach the email headers or the SMTP envelope.
+	if req.Channel == model.ChannelEmail {
+		if addr, err := mail.ParseAddress(req.Target); err == nil {
+			req.Target = addr.Address
+		}
+	}
+
+	if err := s.validateAlert(ctx, req); err != nil {
+		return model.PriceAlert{}, err
+	}
+
+	price, err := s.prices.Price(req.CryptoSymbol, req.QuoteSymbol)
+	if err != nil {
+		return model.PriceAlert{}, fmt.Errorf("failed to get price for %s in %s: %w", req.CryptoSymbol, req.QuoteSymbol, err)
+	}
+
+	return s.alertRepo.CreateAlert(model.PriceAlert{
+		UserID:          userID,
+		CryptoSymbol:    req.CryptoSymbol,
+		QuoteSymbol:     req.QuoteSymbol,
+		Condition:       req.Condition,
+		Threshold:       req.Threshold,
+		ReferencePrice:  price,
+		Channel:         req.Channel,
+		Target:          req.Target,
+		CooldownSeconds: req.CooldownSeconds,
+	})
+}
+
+func (s *AlertService) GetUserAlerts(userID int) ([]model.PriceAlert, error) {
+	return s.alertRepo.GetUserAlerts(userID)
+}
+
+func (s *AlertService) DeleteAlert(userID int, alertID int) error {
+	return s.alertRepo.DeleteAlert(userID, alertID)
+}
+
+func (s *AlertService) RunEvaluator(ctx context.Context) {
+	ticker := time.NewTicker(config.AlertEvaluationInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.evaluateAlerts(now)
+		}
+	}
+}
+
+func alertTriggered(alert model.PriceAlert, price float64) bool {
+	switch alert.Condition {
+	case model.AlertAbove:
+		return price >= alert.Threshold
+	case model.AlertBelow:
+		return price <= alert.Threshold
+	case model.AlertPercentChange:
+		if alert.ReferencePrice == 0 {
+			return false
+		}
+		return math.Abs(price-alert.ReferencePrice)/alert.ReferencePrice*100 >= alert.Threshold
+	}
+	return false
+}
+
+// evaluateAlerts checks every active alert that is not cooling down, fetching
+// each pair's price once per run. An alert whose notification fails is not
+// marked as triggered, so delivery is retried on the next run.
+func (s *AlertService) evaluateAlerts(now time.Time) {
+	alerts, err := s.alertRepo.GetActiveAlerts()
+	if err != nil {
+		log.Printf("Failed to load price alerts: %v", err)
+		return
+	}
+
+	prices := map[string]float64{}
+	for _, alert := range alerts {
+		if alert.LastTriggeredAt != nil && now.Sub(*alert.LastTriggeredAt) < time.Duration(alert.CooldownSeconds)*time.Second {
+			continue
+		}
+
+		pair := alert.CryptoSymbol + "/" + alert.QuoteSymbol
+		price, ok := prices[pair]
+		if !ok {
+			price, err = s.prices.Price(alert.CryptoSymbol, alert.QuoteSymbol)
+			if err != nil {
+				log.Printf("Failed to get price of %s for alerts: %v", pair, err)
+				continue
+			}
+			prices[pair] = price
+		}
+
+		if !alertTriggered(alert, price) {
+			continue
+		}
+
+		err = s.notifiers[alert.Channel].Notify(alertNotification(alert, price, now))
+		if err != nil {
+			log.Printf("Failed to notify alert %d: %v", alert.ID, err)
+			continue
+		}
+
+		err = s.alertRepo.MarkTriggered(alert.ID, now, price)
+		if err != nil {
+			log.Printf("Failed to update alert %d: %v", alert.ID, err)
+		}
+	}
+}
+
+func alertNotification(alert model.PriceAlert, price float64, now time.Time) Notification {
+	var description string
+	switch alert.Condition {
+	case model.AlertPercentChange:
+		description = fmt.Sprintf("%s moved %.2f%% from %g to %g %s",
+			alert.CryptoSymbol, (price-alert.ReferencePrice)/alert.ReferencePrice*100, alert.ReferencePrice, price, alert.QuoteSymbol)
+	default:
+		description = fmt.Sprintf("%s is %s %g %s at %g %s",
+			alert.CryptoSymbol, alert.Condition, alert.Threshold, alert.QuoteSymbol, price, alert.QuoteSymbol)
+	}
+
+	return Notification{
+		Target:  alert.Target,
+		Subject: fmt.Sprintf("Price alert: %s/%s", alert.CryptoSymbol, alert.QuoteSymbol),
+		Body:    description,
+		Payload: map[string]interface{}{
+			"alertId":     alert.ID,
+			"crypto":      alert.CryptoSymbol,
+			"quote":       alert.QuoteSymbol,
+			"condition":   alert.Condition,
+			"threshold":   alert.Threshold,
+			"price":       price,
+			"message":     description,
+			"triggeredAt": now.UTC(),
+		},
+	}
+}
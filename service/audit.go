@@ -0,0 +1,320 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"swap-wallet/config"
+	"swap-wallet/middleware"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+// AuditService appends sensitive actions to the hash-chained audit log and
+// serves it to compliance staff. The client IP and request id of each entry
+// come from the context of the request that caused it.
+type AuditService struct {
+	auditRepo  *repository.AuditRepository
+	userRepo   *repository.UserRepository
+	ledgerRepo *repository.LedgerRepository
+	cryptoRepo *repository.CryptocurrencyRepository
+}
+
+// AuditEvent is an action to record. ActorID is 0 for actions the service
+// takes on its own; Before and After are encoded as JSON, nil meaning none.
+type AuditEvent struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// AuditQuery narrows the audit entries listed.
+type AuditQuery struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository, userRepo *repository.UserRepository, ledgerRepo *repository.LedgerRepository, cryptoRepo *repository.CryptocurrencyRepository) *AuditService {
+	return &AuditService{
+		auditRepo:  auditRepo,
+		userRepo:   userRepo,
+		ledgerRepo: ledgerRepo,
+		cryptoRepo: cryptoRepo,
+	}
+}
+
+func encodeAuditValue(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
+
+func (s *AuditService) entry(ctx context.Context, event AuditEvent) (model.AuditEntry, error) {
+	before, err := encodeAuditValue(event.Before)
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to encode %s audit state: %v", event.Action, err)
+	}
+	after, err := encodeAuditValue(event.After)
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("failed to encode %s audit state: %v", event.Action, err)
+	}
+
+	entry := model.AuditEntry{
+		Action:     event.Action,
+		ActorIP:    middleware.ClientIPFrom(ctx),
+		RequestID:  middleware.RequestIDFrom(ctx),
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Before:     before,
+		After:      after,
+	}
+	if event.ActorID > 0 {
+		actorID := event.ActorID
+		entry.ActorID = &actorID
+	}
+	return entry, nil
+}
+
+// Record appends event to the audit log on its own.
+func (s *AuditService) Record(ctx context.Context, event AuditEvent) error {
+	entry, err := s.entry(ctx, event)
+	if err != nil {
+		return err
+	}
+	_, err = s.auditRepo.Append(entry)
+	return err
+}
+
+// RecordTx appends event within tx, so the entry commits together with the
+// change it describes. It locks the audit log until tx ends.
+func (s *AuditService) RecordTx(ctx context.Context, tx *sql.Tx, event AuditEvent) error {
+	entry, err := s.entry(ctx, event)
+	if err != nil {
+		return err
+	}
+	_, err = s.auditRepo.AppendTx(tx, entry)
+	return err
+}
+
+// balanceStates returns the balance of each asset in entries before the
+// first and after the last of them, formatted with the asset's scale.
+func (s *AuditService) balanceStates(entries []model.LedgerEntry) (before, after map[string]string, err error) {
+	before, after = map[string]string{}, map[string]string{}
+	for _, entry := range entries {
+		scale, err := s.cryptoRepo.GetCryptoScale(entry.CryptoSymbol)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get %s scale: %v", entry.CryptoSymbol, err)
+		}
+		if _, ok := before[entry.CryptoSymbol]; !ok {
+			before[entry.CryptoSymbol] = formatUnits(entry.BalanceAfter-entry.Amount, scale)
+		}
+		after[entry.CryptoSymbol] = formatUnits(entry.BalanceAfter, scale)
+	}
+	return before, after, nil
+}
+
+// RecordExchange records a completed exchange with the balances it changed.
+// It must run inside the exchange's transaction, after its ledger entries
+// have been written.
+func (s *AuditService) RecordExchange(ctx context.Context, tx *sql.Tx, exchange model.Exchange) error {
+	entries, err := s.ledgerRepo.GetExchangeEntries(tx, exchange.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load exchange ledger entries: %v", err)
+	}
+	before, after, err := s.balanceStates(entries)
+	if err != nil {
+		return err
+	}
+
+	return s.RecordTx(ctx, tx, AuditEvent{
+		Action:     model.AuditExchangeCompleted,
+		ActorID:    exchange.UserID,
+		TargetType: "exchange",
+		TargetID:   strconv.Itoa(exchange.ID),
+		Before:     map[string]interface{}{"balances": before},
+		After:      map[string]interface{}{"exchange": exchange, "balances": after},
+	})
+}
+
+// RecordAdjustment records adminID's action on an adjustment, with the
+// adjustment before (nil when it was just requested) and after, and the
+// balance it changed once applied. It must run inside the adjustment's
+// transaction.
+func (s *AuditService) RecordAdjustment(ctx context.Context, tx *sql.Tx, action string, adminID int, previous *model.BalanceAdjustment, adjustment model.BalanceAdjustment) error {
+	before := map[string]interface{}{}
+	after := map[string]interface{}{"adjustment": adjustment}
+	if previous != nil {
+		before["adjustment"] = previous
+	}
+
+	if adjustment.Status == model.AdjustmentApplied {
+		entries, err := s.ledgerRepo.GetAdjustmentEntries(tx, adjustment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load adjustment ledger entries: %v", err)
+		}
+		balancesBefore, balancesAfter, err := s.balanceStates(entries)
+		if err != nil {
+			return err
+		}
+		before["balances"] = balancesBefore
+		after["balances"] = balancesAfter
+	}
+
+	event := AuditEvent{
+		Action:     action,
+		ActorID:    adminID,
+		TargetType: "adjustment",
+		TargetID:   strconv.Itoa(adjustment.ID),
+		After:      after,
+	}
+	if len(before) > 0 {
+		event.Before = before
+	}
+	return s.RecordTx(ctx, tx, event)
+}
+
+// RecordAuthSuccess records that a request established userID as its
+// caller. Like failures, failing to record it is only logged.
+func (s *AuditService) RecordAuthSuccess(ctx context.Context, userID int) {
+	err := s.Record(ctx, AuditEvent{
+		Action:     model.AuditAuthSucceeded,
+		ActorID:    userID,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	})
+	if err != nil {
+		log.Printf("Failed to audit authentication of request %s: %v", middleware.RequestIDFrom(ctx), err)
+	}
+}
+
+// RecordAuthFailure records a request made with an unknown or malformed user
+// id. Failing to record it is only logged, since the request is rejected
+// either way.
+func (s *AuditService) RecordAuthFailure(ctx context.Context, presentedUserID, reason string) {
+	err := s.Record(ctx, AuditEvent{
+		Action:     model.AuditAuthFailed,
+		TargetType: "user",
+		TargetID:   truncate(presentedUserID, 64),
+		After:      map[string]string{"reason": reason},
+	})
+	if err != nil {
+		log.Printf("Failed to audit authentication failure of request %s: %v", middleware.RequestIDFrom(ctx), err)
+	}
+}
+
+// RequireRole fails with forbidden unless userID has one of roles. Denials
+// are recorded in the audit log.
+func (s *AuditService) RequireRole(ctx context.Context, userID int, roles ...string) error {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	for _, role := range roles {
+		if err == nil && user.Role == role {
+			return nil
+		}
+	}
+
+	auditErr := s.Record(ctx, AuditEvent{
+		Action:  model.AuditAuthDenied,
+		ActorID: userID,
+		After:   map[string]interface{}{"required_roles": roles, "role": user.Role},
+	})
+	if auditErr != nil {
+		log.Printf("Failed to audit denied request %s: %v", middleware.RequestIDFrom(ctx), auditErr)
+	}
+	return newError(CodeForbidden, nil, "this action requires the %s role", roles[0])
+}
+
+// RecordSettings records config.changed when settings differ from the
+// settings of the last config.changed entry. It runs at startup, since
+// settings only change when the service is redeployed.
+func (s *AuditService) RecordSettings(ctx context.Context, settings map[string]interface{}) error {
+	after, err := encodeAuditValue(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %v", err)
+	}
+
+	var before interface{}
+	last, err := s.auditRepo.GetLatest(model.AuditConfigChanged)
+	if err == nil {
+		if bytes.Equal(last.After, after) {
+			return nil
+		}
+		before = last.After
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	return s.Record(ctx, AuditEvent{
+		Action:     model.AuditConfigChanged,
+		TargetType: "config",
+		Before:     before,
+		After:      after,
+	})
+}
+
+// GetEntries lists audit entries, newest first, for compliance staff and
+// admins.
+func (s *AuditService) GetEntries(ctx context.Context, userID int, query AuditQuery) ([]model.AuditEntry, error) {
+	if err := s.RequireRole(ctx, userID, model.RoleCompliance, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, invalidRequest("from must be before to")
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = config.DefaultAuditEntries
+	}
+	if limit > config.MaxAuditEntries {
+		limit = config.MaxAuditEntries
+	}
+
+	return s.auditRepo.GetEntries(repository.AuditFilter{
+		Action:     query.Action,
+		ActorID:    query.ActorID,
+		TargetType: query.TargetType,
+		TargetID:   query.TargetID,
+		From:       query.From,
+		To:         query.To,
+		BeforeID:   query.BeforeID,
+		Limit:      limit,
+	})
+}
+
+// Verify recomputes the audit log's hash chain.
+func (s *AuditService) Verify(ctx context.Context, userID int) (model.AuditVerification, error) {
+	if err := s.RequireRole(ctx, userID, model.RoleCompliance, model.RoleAdmin); err != nil {
+		return model.AuditVerification{}, err
+	}
+	return s.auditRepo.Verify()
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
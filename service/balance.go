@@ -2,14 +2,18 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
 	"os"
+	"strconv"
 	"swap-wallet/config"
+	"swap-wallet/model"
 	"swap-wallet/repository"
 	"time"
 
@@ -19,87 +23,129 @@ import (
 )
 
 type BalanceService struct {
-	balanceRepo *repository.BalanceRepository
-	cryptoRepo  *repository.CryptocurrencyRepository
-	userRepo    *repository.UserRepository
-	redisClient *redis.Client
+	balanceRepo  *repository.BalanceRepository
+	cryptoRepo   *repository.CryptocurrencyRepository
+	userRepo     *repository.UserRepository
+	positionRepo *repository.PositionRepository
+	prices       *PriceProvider
+	events       *EventService
+	audit        *AuditService
+	twoFactor    *TwoFactorService
+	redisClient  *redis.Client
+
+	slippageTolerance float64
+	slippageAction    string
+	feeRate           float64
 }
 
-type CryptoBalanceType struct {
-	CryptoName    string  `json:"crypto_name"`
-	CryptoBalance float64 `json:"crypto_balance"`
-	USDBalance    float64 `json:"usd_balance"`
+// SlippageError is returned by FinalizeExchange when the live rate moved
+// against the quote by more than both the house tolerance and the client's
+// maximum slippage. When the house is configured to requote, Quote holds a
+// fresh quote at the live rate.
+type SlippageError struct {
+	QuotedRate float64
+	LiveRate   float64
+	Quote      *Quote
 }
 
-func NewBalanceService(balanceRepo *repository.BalanceRepository, cryptoRepo *repository.CryptocurrencyRepository, userRepo *repository.UserRepository, redisClient *redis.Client) *BalanceService {
-	return &BalanceService{
-		balanceRepo: balanceRepo,
-		cryptoRepo:  cryptoRepo,
-		userRepo:    userRepo,
-		redisClient: redisClient,
-	}
+func (e *SlippageError) Error() string {
+	return fmt.Sprintf("rate moved from %f to %f beyond the allowed slippage", e.QuotedRate, e.LiveRate)
 }
 
-func (s *BalanceService) UserExists(userID int) bool {
-	_, err := s.userRepo.GetUsername(userID)
-	return err == nil
-}
+const DefaultQuoteCurrency = "USD"
 
-func formatURL(cryptoSymbol string, toSymbol string) string {
-	return fmt.Sprintf(config.CryptoCompareAPI, cryptoSymbol, toSymbol)
+// CryptoBalanceType is a balance valued in a quote currency. USDBalance is
+// its value in USD whatever the quote, as clients from before quotes expect.
+type CryptoBalanceType struct {
+	CryptoName     string    `json:"crypto_name"`
+	CryptoBalance  float64   `json:"crypto_balance"`
+	USDBalance     float64   `json:"usd_balance"`
+	Quote          string    `json:"quote"`
+	QuoteBalance   float64   `json:"quote_balance"`
+	Price          float64   `json:"price"`
+	PriceTimestamp time.Time `json:"price_timestamp"`
 }
 
-func getCryptoPriceFromThirdParty(cryptoSymbol string, sourceSymbol string) (float64, error) {
-	url := formatURL(cryptoSymbol, sourceSymbol)
+type Portfolio struct {
+	Quote    string              `json:"quote"`
+	Total    float64             `json:"total"`
+	Balances []CryptoBalanceType `json:"balances"`
+}
 
-	client := &http.Client{
-		Timeout: config.Timeout * time.Second,
+func NewBalanceService(balanceRepo *repository.BalanceRepository, cryptoRepo *repository.CryptocurrencyRepository, userRepo *repository.UserRepository, positionRepo *repository.PositionRepository, prices *PriceProvider, events *EventService, audit *AuditService, twoFactor *TwoFactorService, redisClient *redis.Client) *BalanceService {
+	return &BalanceService{
+		balanceRepo:  balanceRepo,
+		cryptoRepo:   cryptoRepo,
+		userRepo:     userRepo,
+		positionRepo: positionRepo,
+		prices:       prices,
+		events:       events,
+		audit:        audit,
+		twoFactor:    twoFactor,
+		redisClient:  redisClient,
+
+		slippageTolerance: config.LoadSlippageTolerance(),
+		slippageAction:    config.LoadSlippageAction(),
+		feeRate:           config.LoadExchangeFeeRate(),
 	}
-	resp, err := client.Get(url)
+}
+
+// Authenticate returns the id of the user presentedUserID names. Every
+// attempt is recorded in the audit log: the user on success, and malformed
+// or unknown ids and closed accounts on failure.
+func (s *BalanceService) Authenticate(ctx context.Context, presentedUserID string) (int, error) {
+	userID, err := strconv.Atoi(presentedUserID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch cryptocurrency price: %v", err)
+		s.audit.RecordAuthFailure(ctx, presentedUserID, "malformed user id")
+		return -1, newError(CodeInvalidUser, err, "invalid user id")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code from CryptoCompare API: %d", resp.StatusCode)
+	user, err := s.userRepo.GetUser(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.audit.RecordAuthFailure(ctx, presentedUserID, "unknown user")
+		return -1, newError(CodeInvalidUser, nil, "user not found: %d", userID)
 	}
-
-	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read response body: %v", err)
+		return -1, err
 	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal JSON response: %v", err)
+	if user.Status == model.UserClosed {
+		s.audit.RecordAuthFailure(ctx, presentedUserID, "closed account")
+		return -1, newError(CodeAccountClosed, nil, "account is closed")
 	}
+	s.audit.RecordAuthSuccess(ctx, userID)
+	return userID, nil
+}
 
-	_, priceNotExist := data["Response"].(string)
-	if priceNotExist {
-		originToUsd, errOriginToUsd := getCryptoPriceFromThirdParty(cryptoSymbol, "USD")
-		if errOriginToUsd != nil {
-			return 0, fmt.Errorf("failed to extract price for origin price from response")
-		}
-
-		sourceToUsd, errSourceToUsd := getCryptoPriceFromThirdParty(sourceSymbol, "USD")
-		if errSourceToUsd != nil {
-			return 0, fmt.Errorf("failed to extract price for source price from response")
-		}
-		return originToUsd / sourceToUsd, nil
+// PreferredQuoteCurrency returns the currency the user chose to have
+// balances valued in.
+func (s *BalanceService) PreferredQuoteCurrency(userID int) (string, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return "", err
 	}
+	return user.QuoteCurrency, nil
+}
 
-	raw, ok := data["RAW"].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("failed to extract RAW data from response")
+// tradingAllowed fails unless a user with status may quote and exchange.
+func tradingAllowed(status string) error {
+	switch status {
+	case model.UserActive:
+		return nil
+	case model.UserClosed:
+		return newError(CodeAccountClosed, nil, "account is closed")
+	case model.UserWithdrawOnly:
+		return newError(CodeAccountRestricted, nil, "account is withdraw-only and cannot trade")
+	default:
+		return newError(CodeAccountRestricted, nil, "account is %s and cannot trade", status)
 	}
+}
 
-	price, ok := raw["PRICE"].(float64)
-	if !ok {
-		return 0, fmt.Errorf("failed to extract PRICE from RAW data")
+func (s *BalanceService) checkTrading(userID int) error {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return err
 	}
-
-	return price, nil
+	return tradingAllowed(user.Status)
 }
 
 func (s *BalanceService) getUserBalance(userID int, crypto string) (float64, error) {
@@ -124,34 +170,52 @@ func scaleCryptoBalance(balance int64, scale int) float64 {
 	return float64(balance) / divisor
 }
 
-func (s *BalanceService) GetUserBalancesWithUsd(userID int) ([]CryptoBalanceType, error) {
+// GetUserBalancesInQuote values every balance of the user in quote, which may
+// be a fiat currency or a cryptocurrency, and totals the portfolio.
+func (s *BalanceService) GetUserBalancesInQuote(userID int, quote string) (Portfolio, error) {
 	cryptoBalances, err := s.getUserBalances(userID)
 	if err != nil {
-		return nil, err
+		return Portfolio{}, err
 	}
 
-	var result []CryptoBalanceType
+	portfolio := Portfolio{Quote: quote, Balances: []CryptoBalanceType{}}
 
 	for _, cryptoBalance := range cryptoBalances {
-		adjustedCryptoBalance, err := s.getUserBalance(userID, cryptoBalance.CryptoName)
+		valued, err := s.valueBalance(cryptoBalance.CryptoName, cryptoBalance.CryptoBalance, quote)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get balance for %s: %v", cryptoBalance.CryptoName, err)
+			return Portfolio{}, err
 		}
 
-		price, err := getCryptoPriceFromThirdParty(cryptoBalance.CryptoName, "USD")
+		portfolio.Total += valued.QuoteBalance
+		portfolio.Balances = append(portfolio.Balances, valued)
+	}
+
+	return portfolio, nil
+}
+
+func (s *BalanceService) valueBalance(crypto string, cryptoBalance float64, quote string) (CryptoBalanceType, error) {
+	price, priceTime, err := s.prices.PriceWithTime(crypto, quote)
+	if err != nil {
+		return CryptoBalanceType{}, fmt.Errorf("failed to get price for %s in %s: %w", crypto, quote, err)
+	}
+
+	usdPrice := price
+	if quote != "USD" {
+		usdPrice, err = s.prices.Price(crypto, "USD")
 		if err != nil {
-			return nil, fmt.Errorf("failed to get price for %s: %v", cryptoBalance.CryptoName, err)
+			return CryptoBalanceType{}, fmt.Errorf("failed to get price for %s in USD: %w", crypto, err)
 		}
-
-		usdBalance := adjustedCryptoBalance * price
-		result = append(result, CryptoBalanceType{
-			CryptoName:    cryptoBalance.CryptoName,
-			CryptoBalance: adjustedCryptoBalance,
-			USDBalance:    usdBalance,
-		})
 	}
 
-	return result, nil
+	return CryptoBalanceType{
+		CryptoName:     crypto,
+		CryptoBalance:  cryptoBalance,
+		USDBalance:     cryptoBalance * usdPrice,
+		Quote:          quote,
+		QuoteBalance:   cryptoBalance * price,
+		Price:          price,
+		PriceTimestamp: priceTime,
+	}, nil
 }
 
 func (s *BalanceService) getUserBalances(userID int) ([]CryptoBalanceType, error) {
@@ -179,23 +243,16 @@ func (s *BalanceService) getUserBalances(userID int) ([]CryptoBalanceType, error
 	return adjustedBalances, nil
 }
 
-func (s *BalanceService) GetUserBalanceWithUsd(userID int, crypto string) (float64, float64, error) {
+func (s *BalanceService) GetUserBalanceInQuote(userID int, crypto string, quote string) (CryptoBalanceType, error) {
 	cryptoBalance, err := s.getUserBalance(userID, crypto)
 	if err != nil {
-		return 0, 0, err
-	}
-
-	crypoPriceUSDUnit, err := getCryptoPriceFromThirdParty(crypto, "USD")
-
-	if err != nil {
-		return 0, 0, err
+		return CryptoBalanceType{}, err
 	}
 
-	UsdBalance := crypoPriceUSDUnit * cryptoBalance
-	return cryptoBalance, UsdBalance, nil
+	return s.valueBalance(crypto, cryptoBalance, quote)
 }
 
-func createJWTToken(source, target string, sourceAmount, targetAmount float64) (string, error) {
+func createJWTToken(quote Quote) (string, error) {
 	err := godotenv.Load()
 	if err != nil {
 		return "", fmt.Errorf("error loading .env file: %v", err)
@@ -204,11 +261,16 @@ func createJWTToken(source, target string, sourceAmount, targetAmount float64) (
 	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
 
 	claims := jwt.MapClaims{
-		"exp":          time.Now().Add(60 * time.Second).Unix(),
-		"sourceCrypto": source,
-		"targetCrypto": target,
-		"sourceAmount": sourceAmount,
-		"targetAmount": targetAmount,
+		"exp":          quote.ExpiresAt.Unix(),
+		"sourceCrypto": quote.SourceCrypto,
+		"targetCrypto": quote.TargetCrypto,
+		"sourceAmount": quote.SourceAmount,
+		"targetAmount": quote.TargetAmount,
+		"fee":          quote.Fee,
+		"rate":         quote.Rate,
+		"fixedSide":    quote.FixedSide,
+		"maxSlippage":  quote.MaxSlippage,
+		"legs":         quote.Legs,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -220,24 +282,71 @@ func createJWTToken(source, target string, sourceAmount, targetAmount float64) (
 	return tokenString, nil
 }
 
-func (s *BalanceService) GetExchangePreview(sourceCrypto, targetCrypto string, amount float64) (float64, string, error) {
-	conversionRate, err := getCryptoPriceFromThirdParty(sourceCrypto, targetCrypto)
+// GetExchangePreview quotes an exchange where amount is either the source
+// amount to spend or the target amount to receive, depending on fixedSide.
+// maxSlippage is the fraction of adverse rate movement the client accepts at
+// finalization; zero means the client only accepts the quoted rate. Every
+// quote issued to userID is recorded in the audit log.
+func (s *BalanceService) GetExchangePreview(ctx context.Context, userID int, sourceCrypto, targetCrypto string, amount float64, fixedSide string, maxSlippage float64) (Quote, error) {
+	quote, err := s.PriceExchange(userID, sourceCrypto, targetCrypto, amount, fixedSide, maxSlippage)
+	if err != nil {
+		return Quote{}, err
+	}
+	return s.issueQuote(ctx, userID, quote)
+}
+
+// PriceExchange prices an exchange like GetExchangePreview without issuing
+// it as a quote, so the result has no token and cannot be applied. Nothing
+// is stored, which suits previews refreshed every few seconds.
+func (s *BalanceService) PriceExchange(userID int, sourceCrypto, targetCrypto string, amount float64, fixedSide string, maxSlippage float64) (Quote, error) {
+	if err := s.checkTrading(userID); err != nil {
+		return Quote{}, err
+	}
+
+	route, err := s.findRoute(sourceCrypto, targetCrypto)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to get price for %s: %v", sourceCrypto, err)
+		return Quote{}, err
 	}
-	convertedAmount := amount * conversionRate
 
-	token, err := createJWTToken(sourceCrypto, targetCrypto, amount, convertedAmount)
+	quote, err := s.priceRoute(route, amount, fixedSide)
 	if err != nil {
-		return 0, "", fmt.Errorf("error in create JWT Toekn %s", err)
+		return Quote{}, err
 	}
+	quote.MaxSlippage = maxSlippage
+	return quote, nil
+}
 
-	err = s.redisClient.Set(context.Background(), token, 1, 60*time.Second).Err()
+func (s *BalanceService) issueQuote(ctx context.Context, userID int, quote Quote) (Quote, error) {
+	quote.ExpiresAt = time.Now().Add(config.QuoteTTL * time.Second).UTC()
+	token, err := createJWTToken(quote)
+	if err != nil {
+		return Quote{}, fmt.Errorf("error in create JWT Toekn %s", err)
+	}
+
+	err = s.redisClient.Set(context.Background(), token, 1, config.QuoteTTL*time.Second).Err()
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to store token in Redis: %v", err)
+	}
+
+	err = s.audit.Record(ctx, AuditEvent{
+		Action:     model.AuditQuoteCreated,
+		ActorID:    userID,
+		TargetType: "quote",
+		TargetID:   quoteRef(token),
+		After:      quote,
+	})
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to store token in Redis: %v", err)
+		return Quote{}, fmt.Errorf("failed to audit quote: %v", err)
 	}
 
-	return convertedAmount, token, nil
+	quote.Token = token
+	return quote, nil
+}
+
+// quoteRef identifies a quote in the audit log without revealing its token.
+func quoteRef(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
 }
 
 func (s *BalanceService) checkToken(token string) error {
@@ -250,7 +359,7 @@ func (s *BalanceService) checkToken(token string) error {
 	}
 
 	if exists == 0 {
-		return fmt.Errorf("token not found or already used")
+		return newError(CodeQuoteExpired, nil, "quote has expired or was already used")
 	}
 
 	err = s.redisClient.Del(ctx, redisKey).Err()
@@ -261,8 +370,62 @@ func (s *BalanceService) checkToken(token string) error {
 	return nil
 }
 
-func (s *BalanceService) FinalizeExchange(userID int, tokenString string) error {
-	err := s.checkToken(tokenString)
+func quoteFromClaims(claims jwt.MapClaims) (Quote, error) {
+	quote := Quote{
+		SourceCrypto: claims["sourceCrypto"].(string),
+		TargetCrypto: claims["targetCrypto"].(string),
+		SourceAmount: claims["sourceAmount"].(float64),
+		TargetAmount: claims["targetAmount"].(float64),
+		FixedSide:    FixedSideSource,
+	}
+	quote.Fee, _ = claims["fee"].(float64)
+	quote.MaxSlippage, _ = claims["maxSlippage"].(float64)
+	if fixedSide, ok := claims["fixedSide"].(string); ok {
+		quote.FixedSide = fixedSide
+	}
+	if rate, ok := claims["rate"].(float64); ok {
+		quote.Rate = rate
+	} else {
+		quote.Rate = quote.TargetAmount / quote.SourceAmount
+	}
+
+	legs, ok := claims["legs"]
+	if !ok {
+		quote.Legs = []QuoteLeg{{
+			SourceCrypto: quote.SourceCrypto,
+			TargetCrypto: quote.TargetCrypto,
+			SourceAmount: quote.SourceAmount,
+			TargetAmount: quote.TargetAmount,
+			Rate:         quote.Rate,
+		}}
+		return quote, nil
+	}
+
+	encodedLegs, err := json.Marshal(legs)
+	if err != nil {
+		return Quote{}, err
+	}
+	err = json.Unmarshal(encodedLegs, &quote.Legs)
+	if err != nil || len(quote.Legs) == 0 {
+		return Quote{}, newError(CodeInvalidQuote, err, "quote token has invalid legs")
+	}
+
+	return quote, nil
+}
+
+// FinalizeExchange applies the quote of tokenString for userID. Exchanges
+// above the two-factor threshold need totpCode, a TOTP or recovery code.
+func (s *BalanceService) FinalizeExchange(ctx context.Context, userID int, tokenString string, totpCode string) error {
+	return s.finalizeExchange(ctx, userID, tokenString, func(quote Quote) error {
+		return s.twoFactor.CheckAmount(ctx, userID, quote.SourceCrypto, quote.SourceAmount, totpCode)
+	})
+}
+
+// finalizeExchange applies the quote of tokenString once authorize, when
+// given, accepts it. authorize runs before the quote is used up, so a
+// rejected exchange can be retried with the same quote.
+func (s *BalanceService) finalizeExchange(ctx context.Context, userID int, tokenString string, authorize func(Quote) error) error {
+	err := s.checkTrading(userID)
 	if err != nil {
 		return err
 	}
@@ -276,22 +439,138 @@ func (s *BalanceService) FinalizeExchange(userID int, tokenString string) error
 	})
 
 	if err != nil {
-		return fmt.Errorf("invalid token: %v", err)
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return newError(CodeQuoteExpired, err, "quote has expired")
+		}
+		return newError(CodeInvalidQuote, err, "quote token is invalid")
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		targetAmount := claims["targetAmount"].(float64)
-		sourceCrypto := claims["sourceCrypto"].(string)
-		targetCrypto := claims["targetCrypto"].(string)
-		amount := claims["sourceAmount"].(float64)
+		quote, err := quoteFromClaims(claims)
+		if err != nil {
+			return err
+		}
 
-		err := s.balanceRepo.ExchangeBalances(userID, sourceCrypto, targetCrypto, amount, targetAmount)
+		if authorize != nil {
+			err = authorize(quote)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = s.checkToken(tokenString)
+		if err != nil {
+			return err
+		}
+
+		quote, err = s.checkSlippage(ctx, userID, quote)
 		if err != nil {
-			return fmt.Errorf("exchange operation failed: %v", err)
+			return err
+		}
+
+		positionEvents, err := s.exchangePositionEvents(userID, quote)
+		if err != nil {
+			return err
+		}
+
+		exchange := quote.exchange(userID)
+		_, err = s.balanceRepo.ExchangeLegs(exchange, quote.exchangeLegs(), func(tx *sql.Tx, exchangeID int) error {
+			// Checked again under lock, so a freeze that commits while the
+			// exchange runs is not bypassed.
+			status, err := s.userRepo.LockStatus(tx, userID)
+			if err != nil {
+				return err
+			}
+			return tradingAllowed(status)
+		}, func(tx *sql.Tx, exchangeID int) error {
+			return s.positionRepo.RecordEvents(tx, positionEvents)
+		}, func(tx *sql.Tx, exchangeID int) error {
+			exchange.ID = exchangeID
+			exchange.CreatedAt = time.Now().UTC()
+			return s.events.PublishExchange(tx, exchange)
+		}, func(tx *sql.Tx, exchangeID int) error {
+			return s.audit.RecordExchange(ctx, tx, exchange)
+		})
+		if errors.Is(err, repository.ErrInsufficientBalance) {
+			return newError(CodeInsufficientFund, err, "insufficient balance to complete the exchange")
+		}
+		if err != nil {
+			return fmt.Errorf("exchange operation failed: %w", err)
 		}
 
 		return nil
 	}
 
-	return fmt.Errorf("invalid token")
+	return newError(CodeInvalidQuote, nil, "quote token is invalid")
+}
+
+// checkSlippage re-prices a quote's route against live rates and returns the
+// quote to execute. Movement within the house tolerance is honoured at the
+// quoted rate, movement within the client's maximum slippage is re-priced at
+// the live rates keeping the fixed side, and anything beyond both is rejected
+// or requoted.
+func (s *BalanceService) checkSlippage(ctx context.Context, userID int, quote Quote) (Quote, error) {
+	liveRoute, err := s.refreshRoute(quote.Legs)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	liveRate := 1.0
+	for _, leg := range liveRoute {
+		liveRate *= leg.Rate
+	}
+
+	movement := (quote.Rate - liveRate) / quote.Rate
+	if movement <= s.slippageTolerance {
+		return quote, nil
+	}
+
+	if movement <= quote.MaxSlippage {
+		return s.priceRoute(liveRoute, quote.fixedAmount(), quote.FixedSide)
+	}
+
+	slippageErr := &SlippageError{QuotedRate: quote.Rate, LiveRate: liveRate}
+	if s.slippageAction == config.SlippageActionRequote {
+		requote, err := s.priceRoute(liveRoute, quote.fixedAmount(), quote.FixedSide)
+		if err == nil {
+			requote.MaxSlippage = quote.MaxSlippage
+			requote, err = s.issueQuote(ctx, userID, requote)
+		}
+		if err != nil {
+			return Quote{}, fmt.Errorf("failed to requote: %w", err)
+		}
+		slippageErr.Quote = &requote
+	}
+	return Quote{}, slippageErr
+}
+
+// exchangePositionEvents values the source side of an exchange in USD and uses
+// that value both as the proceeds of disposing the source and as the cost of
+// acquiring the target, so fees show up as a loss on the acquired lot.
+// Intermediate assets of a routed exchange net to zero and are not recorded.
+func (s *BalanceService) exchangePositionEvents(userID int, quote Quote) ([]model.PositionEvent, error) {
+	sourceUSDPrice, err := s.prices.Price(quote.SourceCrypto, "USD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to value %s for cost basis: %w", quote.SourceCrypto, err)
+	}
+	usdValue := quote.SourceAmount * sourceUSDPrice
+
+	return []model.PositionEvent{
+		{
+			UserID:       userID,
+			CryptoSymbol: quote.SourceCrypto,
+			Kind:         model.PositionDisposal,
+			Amount:       quote.SourceAmount,
+			USDValue:     usdValue,
+			Source:       model.PositionSourceExchange,
+		},
+		{
+			UserID:       userID,
+			CryptoSymbol: quote.TargetCrypto,
+			Kind:         model.PositionAcquisition,
+			Amount:       quote.TargetAmount,
+			USDValue:     usdValue,
+			Source:       model.PositionSourceExchange,
+		},
+	}, nil
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"swap-wallet/config"
+	"swap-wallet/model"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Broker publishes outbox events to consumers outside the service. Publish
+// must only return nil once the broker has accepted the event.
+type Broker interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+// RedisStreamBroker appends events to a Redis stream, which consumer groups
+// can read with XREADGROUP. Events may be appended more than once, so
+// consumers should deduplicate on event_id.
+type RedisStreamBroker struct {
+	redisClient *redis.Client
+	stream      string
+}
+
+func NewRedisStreamBroker(redisClient *redis.Client) *RedisStreamBroker {
+	return &RedisStreamBroker{
+		redisClient: redisClient,
+		stream:      config.OutboxStream,
+	}
+}
+
+func (b *RedisStreamBroker) Publish(ctx context.Context, event model.OutboxEvent) error {
+	return b.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.stream,
+		MaxLen: config.OutboxStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"event_id":   event.EventID,
+			"event_type": event.EventType,
+			"user_id":    event.UserID,
+			"payload":    event.Payload,
+		},
+	}).Err()
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"swap-wallet/repository"
+)
+
+// Error codes are stable identifiers clients can rely on; messages may change.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeInvalidUser      = "invalid_user"
+	CodeNotFound         = "not_found"
+	CodeUnknownAsset     = "unknown_asset"
+	CodeAssetDisabled    = "asset_disabled"
+	CodeNoRoute          = "no_route"
+	CodeInsufficientFund = "insufficient_funds"
+	CodeQuoteExpired     = "quote_expired"
+	CodeInvalidQuote     = "invalid_quote"
+	CodeSlippageExceeded = "slippage_exceeded"
+	CodePriceUnavailable = "price_unavailable"
+	CodeInternal         = "internal_error"
+
+	CodeRequestInProgress    = "request_in_progress"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeRateLimited          = "rate_limited"
+
+	CodeForbidden      = "forbidden"
+	CodeAlreadyDecided = "already_decided"
+
+	CodeAccountClosed     = "account_closed"
+	CodeAccountRestricted = "account_restricted"
+	CodeAlreadyExists     = "already_exists"
+	CodeAccountNotEmpty   = "account_not_empty"
+
+	CodeTwoFactorRequired = "two_factor_required"
+	CodeInvalidTwoFactor  = "invalid_two_factor_code"
+	CodeTwoFactorLocked   = "two_factor_locked"
+)
+
+// Error is a failure the client can act on. Message is safe to show to
+// clients; Err keeps the underlying cause for logs.
+type Error struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(code string, err error, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func invalidRequest(format string, args ...interface{}) *Error {
+	return newError(CodeInvalidRequest, nil, format, args...)
+}
+
+func priceUnavailable(err error, cryptoSymbol, quoteSymbol string) *Error {
+	return newError(CodePriceUnavailable, err, "price of %s in %s is unavailable", cryptoSymbol, quoteSymbol)
+}
+
+// AsError returns the client-facing form of err: the service Error in its
+// chain, slippage_exceeded for a SlippageError, not_found for missing records,
+// or an internal_error that reveals nothing about the cause.
+func AsError(err error) *Error {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr
+	}
+	var slippageErr *SlippageError
+	if errors.As(err, &slippageErr) {
+		return &Error{Code: CodeSlippageExceeded, Message: slippageErr.Error(), Err: err}
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return &Error{Code: CodeNotFound, Message: err.Error(), Err: err}
+	}
+	return &Error{Code: CodeInternal, Message: "internal server error", Err: err}
+}
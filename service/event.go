@@ -0,0 +1,150 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+// Event is the envelope every domain event is published in, both through the
+// outbox and to webhook endpoints.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// EventService records domain events inside the transaction of the change
+// they describe: each event is appended to the outbox and queued for the
+// user's webhook endpoints.
+type EventService struct {
+	outboxRepo *repository.OutboxRepository
+	ledgerRepo *repository.LedgerRepository
+	cryptoRepo *repository.CryptocurrencyRepository
+	webhooks   *WebhookService
+}
+
+func NewEventService(outboxRepo *repository.OutboxRepository, ledgerRepo *repository.LedgerRepository, cryptoRepo *repository.CryptocurrencyRepository, webhooks *WebhookService) *EventService {
+	return &EventService{
+		outboxRepo: outboxRepo,
+		ledgerRepo: ledgerRepo,
+		cryptoRepo: cryptoRepo,
+		webhooks:   webhooks,
+	}
+}
+
+func (s *EventService) record(tx *sql.Tx, userID int, eventType string, data interface{}) error {
+	eventID, err := randomHex(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate event id: %v", err)
+	}
+
+	event := Event{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", eventType, err)
+	}
+
+	err = s.outboxRepo.Append(tx, model.OutboxEvent{
+		EventID:   event.ID,
+		EventType: event.Type,
+		UserID:    userID,
+		Payload:   string(payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.webhooks.enqueue(tx, userID, event, string(payload))
+}
+
+// PublishExchange records exchange.completed for exchange and one
+// balance.changed per asset it touched. It must run inside the exchange's
+// transaction, after its ledger entries have been written.
+func (s *EventService) PublishExchange(tx *sql.Tx, exchange model.Exchange) error {
+	err := s.record(tx, exchange.UserID, model.EventExchangeCompleted, exchange)
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.ledgerRepo.GetExchangeEntries(tx, exchange.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load exchange ledger entries: %v", err)
+	}
+
+	return s.publishBalanceChanges(tx, exchange.UserID, entries, model.LedgerExchange, "exchange_id", exchange.ID)
+}
+
+// PublishAdjustment records balance.changed for an applied balance
+// adjustment. It must run inside the adjustment's transaction, after its
+// ledger entry has been written.
+func (s *EventService) PublishAdjustment(tx *sql.Tx, adjustment model.BalanceAdjustment) error {
+	entries, err := s.ledgerRepo.GetAdjustmentEntries(tx, adjustment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load adjustment ledger entries: %v", err)
+	}
+
+	return s.publishBalanceChanges(tx, adjustment.UserID, entries, model.LedgerAdjustment, "adjustment_id", adjustment.ID)
+}
+
+// PublishSchedulePaused records schedule.paused when the scheduler pauses
+// schedule on its own, with reason explaining what the user has to do. It
+// must run inside the transaction that pauses it.
+func (s *EventService) PublishSchedulePaused(tx *sql.Tx, schedule model.Schedule, reason string) error {
+	return s.record(tx, schedule.UserID, model.EventSchedulePaused, map[string]interface{}{
+		"schedule": schedule,
+		"reason":   reason,
+	})
+}
+
+// publishBalanceChanges records one balance.changed per asset in entries,
+// carrying the net change and the final balance, and refKey naming the record
+// that caused it.
+func (s *EventService) publishBalanceChanges(tx *sql.Tx, userID int, entries []model.LedgerEntry, reason, refKey string, refID int) error {
+	type balanceChange struct {
+		change       int64
+		balanceAfter int64
+	}
+	var symbols []string
+	changes := map[string]*balanceChange{}
+	for _, entry := range entries {
+		change, ok := changes[entry.CryptoSymbol]
+		if !ok {
+			change = &balanceChange{}
+			changes[entry.CryptoSymbol] = change
+			symbols = append(symbols, entry.CryptoSymbol)
+		}
+		change.change += entry.Amount
+		change.balanceAfter = entry.BalanceAfter
+	}
+
+	for _, symbol := range symbols {
+		scale, err := s.cryptoRepo.GetCryptoScale(symbol)
+		if err != nil {
+			return fmt.Errorf("failed to get %s scale: %v", symbol, err)
+		}
+
+		err = s.record(tx, userID, model.EventBalanceChanged, map[string]interface{}{
+			"user_id": userID,
+			"crypto":  symbol,
+			"change":  formatUnits(changes[symbol].change, scale),
+			"balance": formatUnits(changes[symbol].balanceAfter, scale),
+			"reason":  reason,
+			refKey:    refID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
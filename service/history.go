@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+const (
+	GranularityHourly = "hourly"
+	GranularityDaily  = "daily"
+)
+
+type HistoryService struct {
+	historyRepo *repository.HistoryRepository
+	cryptoRepo  *repository.CryptocurrencyRepository
+	prices      *PriceProvider
+}
+
+type PortfolioPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type PortfolioHistory struct {
+	Quote       string           `json:"quote"`
+	Granularity string           `json:"granularity"`
+	Points      []PortfolioPoint `json:"points"`
+}
+
+func NewHistoryService(historyRepo *repository.HistoryRepository, cryptoRepo *repository.CryptocurrencyRepository, prices *PriceProvider) *HistoryService {
+	return &HistoryService{
+		historyRepo: historyRepo,
+		cryptoRepo:  cryptoRepo,
+		prices:      prices,
+	}
+}
+
+func (s *HistoryService) RunSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(config.SnapshotInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.TakeSnapshot(now.UTC())
+		}
+	}
+}
+
+// TakeSnapshot stores every user's balances and observes the price of every
+// available cryptocurrency in each of config.HistoryQuoteCurrencies, which the
+// price provider records. A price that cannot be fetched is logged and
+// skipped; the series carries the previous price.
+func (s *HistoryService) TakeSnapshot(now time.Time) {
+	err := s.historyRepo.SnapshotBalances(now)
+	if err != nil {
+		log.Printf("Failed to snapshot balances: %v", err)
+	}
+
+	cryptos, err := s.cryptoRepo.GetCryptocurrencies()
+	if err != nil {
+		log.Printf("Failed to load cryptocurrencies for price snapshot: %v", err)
+		return
+	}
+
+	for _, crypto := range cryptos {
+		if !crypto.IsAvailable {
+			continue
+		}
+		for _, quote := range config.HistoryQuoteCurrencies {
+			_, err := s.prices.Price(crypto.Symbol, quote)
+			if err != nil {
+				log.Printf("Failed to snapshot price of %s in %s: %v", crypto.Symbol, quote, err)
+			}
+		}
+	}
+}
+
+func granularityStep(granularity string) (time.Duration, error) {
+	switch granularity {
+	case GranularityHourly:
+		return time.Hour, nil
+	case GranularityDaily:
+		return 24 * time.Hour, nil
+	default:
+		return 0, invalidRequest("unsupported granularity: %s", granularity)
+	}
+}
+
+// priceSeries answers "what was the latest price at time t" for one symbol.
+type priceSeries []model.PricePoint
+
+func (p priceSeries) at(t time.Time) (float64, bool) {
+	i := sort.Search(len(p), func(i int) bool { return p[i].ObservedAt.After(t) })
+	if i == 0 {
+		return 0, false
+	}
+	return p[i-1].Price, true
+}
+
+type snapshotSeries []model.BalanceSnapshot
+
+func (s snapshotSeries) at(t time.Time) (float64, bool) {
+	i := sort.Search(len(s), func(i int) bool { return s[i].TakenAt.After(t) })
+	if i == 0 {
+		return 0, false
+	}
+	return scaleCryptoBalance(s[i-1].Balance, s[i-1].Scale), true
+}
+
+// GetPortfolioHistory values the user's snapshotted balances at the end of
+// every hourly or daily bucket between from and to, using the latest stored
+// price at that moment. Buckets before the user's first snapshot are omitted.
+func (s *HistoryService) GetPortfolioHistory(userID int, quote, granularity string, from, to time.Time) (PortfolioHistory, error) {
+	step, err := granularityStep(granularity)
+	if err != nil {
+		return PortfolioHistory{}, err
+	}
+	if !from.Before(to) {
+		return PortfolioHistory{}, invalidRequest("from must be before to")
+	}
+	from = from.UTC().Truncate(step)
+	if int(to.Sub(from)/step) > config.MaxHistoryPoints {
+		return PortfolioHistory{}, invalidRequest("range exceeds %d %s points", config.MaxHistoryPoints, granularity)
+	}
+
+	snapshots, err := s.historyRepo.GetUserSnapshots(userID, from, to)
+	if err != nil {
+		return PortfolioHistory{}, fmt.Errorf("failed to load balance snapshots: %v", err)
+	}
+
+	balances := map[string]snapshotSeries{}
+	var symbols []string
+	for _, snapshot := range snapshots {
+		if _, ok := balances[snapshot.CryptoSymbol]; !ok {
+			symbols = append(symbols, snapshot.CryptoSymbol)
+		}
+		balances[snapshot.CryptoSymbol] = append(balances[snapshot.CryptoSymbol], snapshot)
+	}
+
+	prices, err := s.loadPriceSeries(symbols, quote, from, to)
+	if err != nil {
+		return PortfolioHistory{}, err
+	}
+
+	history := PortfolioHistory{Quote: quote, Granularity: granularity, Points: []PortfolioPoint{}}
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(to) {
+			end = to
+		}
+
+		var value float64
+		var seen bool
+		for _, symbol := range symbols {
+			balance, ok := balances[symbol].at(end)
+			if !ok {
+				continue
+			}
+			seen = true
+			if price, ok := prices[symbol].at(end); ok {
+				value += balance * price
+			}
+		}
+		if seen {
+			history.Points = append(history.Points, PortfolioPoint{Time: end, Value: value})
+		}
+	}
+
+	return history, nil
+}
+
+// loadPriceSeries returns per-symbol price series in quote. Quotes that are
+// snapshotted directly are read as stored; any other quote must be a
+// cryptocurrency and is derived by crossing both sides through USD.
+func (s *HistoryService) loadPriceSeries(symbols []string, quote string, from, to time.Time) (map[string]priceSeries, error) {
+	for _, snapshotted := range config.HistoryQuoteCurrencies {
+		if quote == snapshotted {
+			return s.fetchPriceSeries(symbols, quote, from, to)
+		}
+	}
+
+	usdPrices, err := s.fetchPriceSeries(append(symbols, quote), "USD", from, to)
+	if err != nil {
+		return nil, err
+	}
+	quoteUSD, ok := usdPrices[quote]
+	if !ok {
+		return nil, invalidRequest("no price history for quote currency: %s", quote)
+	}
+
+	crossed := map[string]priceSeries{}
+	for _, symbol := range symbols {
+		for _, point := range usdPrices[symbol] {
+			quotePrice, ok := quoteUSD.at(point.ObservedAt)
+			if !ok || quotePrice == 0 {
+				continue
+			}
+			point.Price /= quotePrice
+			point.QuoteSymbol = quote
+			crossed[symbol] = append(crossed[symbol], point)
+		}
+	}
+	return crossed, nil
+}
+
+func (s *HistoryService) fetchPriceSeries(symbols []string, quote string, from, to time.Time) (map[string]priceSeries, error) {
+	points, err := s.historyRepo.GetPrices(symbols, quote, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load price history: %v", err)
+	}
+
+	series := map[string]priceSeries{}
+	for _, point := range points {
+		series[point.CryptoSymbol] = append(series[point.CryptoSymbol], point)
+	}
+	return series, nil
+}
+
+// GetCandles returns OHLC candles of symbol in quote at the given resolution
+// for buckets starting between from and to.
+func (s *HistoryService) GetCandles(symbol, quote, resolution string, from, to time.Time) ([]model.Candle, error) {
+	seconds, ok := config.CandleIntervals[resolution]
+	if !ok {
+		return nil, invalidRequest("unsupported interval: %s", resolution)
+	}
+	if !from.Before(to) {
+		return nil, invalidRequest("from must be before to")
+	}
+	if int(to.Sub(from)/(time.Duration(seconds)*time.Second)) > config.MaxCandles {
+		return nil, invalidRequest("range exceeds %d %s candles", config.MaxCandles, resolution)
+	}
+
+	candles, err := s.historyRepo.GetCandles(symbol, quote, resolution, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load candles: %v", err)
+	}
+	return candles, nil
+}
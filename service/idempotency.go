@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"swap-wallet/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// IdempotencyService remembers the responses to requests sent with an
+// idempotency key, so that a client retrying a request that already completed
+// gets the original response instead of running it again.
+type IdempotencyService struct {
+	redisClient *redis.Client
+}
+
+// StoredResponse is a recorded response. While the request is still running
+// its Status is zero.
+type StoredResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+func NewIdempotencyService(redisClient *redis.Client) *IdempotencyService {
+	return &IdempotencyService{redisClient: redisClient}
+}
+
+func idempotencyKey(scope string) string {
+	return "idempotency:" + scope
+}
+
+// Begin claims scope for a request whose content hashes to fingerprint. It
+// returns the stored response when the request already completed, and nil
+// when the caller should run it and then call Complete or Abandon. Reusing a
+// key for a different request, or while the first is still running, is an
+// error.
+func (s *IdempotencyService) Begin(scope, fingerprint string) (*StoredResponse, error) {
+	ctx := context.Background()
+	key := idempotencyKey(scope)
+
+	pending, err := json.Marshal(StoredResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.redisClient.SetNX(ctx, key, pending, config.IdempotencyLockTTL*time.Second).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %v", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	data, err := s.redisClient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, newError(CodeRequestInProgress, nil, "a request with this idempotency key is in progress; retry later")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotent response: %v", err)
+	}
+
+	var stored StoredResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotent response: %v", err)
+	}
+	if stored.Fingerprint != fingerprint {
+		return nil, newError(CodeIdempotencyKeyReused, nil, "the idempotency key was already used for a different request")
+	}
+	if stored.Status == 0 {
+		return nil, newError(CodeRequestInProgress, nil, "a request with this idempotency key is in progress; retry later")
+	}
+	return &stored, nil
+}
+
+// Complete stores the response of the request that claimed scope.
+func (s *IdempotencyService) Complete(scope string, response StoredResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	err = s.redisClient.Set(context.Background(), idempotencyKey(scope), data, config.IdempotencyTTL*time.Second).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %v", err)
+	}
+	return nil
+}
+
+// Abandon releases scope without storing a response, so the request can be
+// retried.
+func (s *IdempotencyService) Abandon(scope string) error {
+	err := s.redisClient.Del(context.Background(), idempotencyKey(scope)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"swap-wallet/config"
+	"time"
+)
+
+type Notification struct {
+	Target  string
+	Subject string
+	Body    string
+	Payload interface{}
+}
+
+// Notifier delivers a notification to its target, whose meaning depends on
+// the implementation (a URL for webhooks, an address for email).
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		client: newOutboundClient(config.NotificationTimeout * time.Second),
+	}
+}
+
+// Notify posts the notification payload as JSON and treats any non-2xx
+// response, redirects included, as a failed delivery. Targets resolving to
+// addresses that are not public are refused.
+func (n *WebhookNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(notification.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+
+	resp, err := n.client.Post(notification.Target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to deliver webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier sends plain-text mail over SMTP. Authentication is skipped
+// when no username is configured, which suits a local SMTP stub such as the
+// mailhog service in docker-compose.
+type EmailNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewEmailNotifier(cfg config.Config) *EmailNotifier {
+	notifier := &EmailNotifier{
+		addr: fmt.Sprintf("%s:%s", cfg.SMTPHost, cfg.SMTPPort),
+		from: cfg.SMTPFrom,
+	}
+	if cfg.SMTPUsername != "" {
+		notifier.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return notifier
+}
+
+// Notify sends notification to the bare address of its target, parsed again
+// so that targets stored before addresses were normalised cannot inject
+// headers.
+func (n *EmailNotifier) Notify(notification Notification) error {
+	addr, err := mail.ParseAddress(notification.Target)
+	if err != nil {
+		return fmt.Errorf("invalid email target: %v", err)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", addr.Address)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", notification.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(notification.Body)
+	msg.WriteString("\r\n")
+
+	err = smtp.SendMail(n.addr, n.auth, n.from, []string{addr.Address}, []byte(msg.String()))
+	if err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Users choose where webhooks and alert notifications are sent, so requests
+// to those URLs must not reach the service's own network: loopback, private
+// and link-local addresses such as the 169.254.169.254 metadata endpoint.
+// URLs are checked when they are registered, and every connection is checked
+// again when it is dialed, since DNS answers can change in between.
+
+// nonPublicNetworks are reserved ranges that the net.IP predicates in
+// publicIP do not cover.
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",     // this network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can reach any IPv4 address
+)
+
+var errNonPublicAddress = errors.New("address is not public")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// publicIP reports whether ip is a public unicast address.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkOutboundURL checks that raw is an http or https URL whose host only
+// resolves to public addresses.
+func checkOutboundURL(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errors.New("must be an http or https URL")
+	}
+
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return fmt.Errorf("must not point to %s: %w", ip, errNonPublicAddress)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("host %s could not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("host %s resolves to %s: %w", host, addr.IP, errNonPublicAddress)
+		}
+	}
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control function that refuses connections
+// to addresses that are not public, after DNS resolution.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("refusing to connect to %s: %w", host, errNonPublicAddress)
+	}
+	return nil
+}
+
+// newOutboundClient returns a client for user-chosen URLs. It only connects
+// to public addresses, ignores proxy settings, which would hide the address
+// dialed, and does not follow redirects: a 3xx response is returned as is.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: dialPublicOnly,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+// OutboxRelay publishes outbox events to a broker. Delivery is at least once:
+// an event is only marked published after the broker accepts it, so a crash
+// in between publishes it again.
+type OutboxRelay struct {
+	outboxRepo *repository.OutboxRepository
+	broker     Broker
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, broker Broker) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		broker:     broker,
+	}
+}
+
+func (r *OutboxRelay) RunRelay(ctx context.Context) {
+	ticker := time.NewTicker(config.OutboxRelayInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.outboxRepo.RelayBatch(config.OutboxBatchSize, func(events []model.OutboxEvent) []int64 {
+				return r.publish(ctx, events)
+			})
+			if err != nil {
+				log.Printf("Failed to relay outbox events: %v", err)
+			}
+		}
+	}
+}
+
+// publish sends events in order and returns the ids the broker accepted.
+// Once an event fails, the user's later events in the batch are held back so
+// that each user's events reach the broker in the order they were written.
+func (r *OutboxRelay) publish(ctx context.Context, events []model.OutboxEvent) []int64 {
+	var published []int64
+	blocked := map[int]bool{}
+	for _, event := range events {
+		if blocked[event.UserID] {
+			continue
+		}
+
+		err := r.broker.Publish(ctx, event)
+		if err != nil {
+			log.Printf("Failed to publish outbox event %s: %v", event.EventID, err)
+			blocked[event.UserID] = true
+			continue
+		}
+		published = append(published, event.ID)
+	}
+	return published
+}
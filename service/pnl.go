@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+)
+
+const (
+	CostBasisFIFO    = "fifo"
+	CostBasisLIFO    = "lifo"
+	CostBasisAverage = "average"
+)
+
+// lotEpsilon is the amount below which a lot is considered fully consumed.
+const lotEpsilon = 1e-12
+
+type PnLService struct {
+	positionRepo   *repository.PositionRepository
+	balanceService *BalanceService
+}
+
+// AssetPnL is denominated in USD. UncoveredAmount is the part of the balance
+// with no recorded acquisition, for example balances that predate cost basis
+// tracking; it is excluded from cost basis and unrealized PnL.
+type AssetPnL struct {
+	CryptoName      string  `json:"crypto_name"`
+	Balance         float64 `json:"balance"`
+	CostBasis       float64 `json:"cost_basis"`
+	AverageCost     float64 `json:"average_cost"`
+	MarketValue     float64 `json:"market_value"`
+	RealizedPnL     float64 `json:"realized_pnl"`
+	UnrealizedPnL   float64 `json:"unrealized_pnl"`
+	UncoveredAmount float64 `json:"uncovered_amount"`
+}
+
+type PortfolioPnL struct {
+	Method        string     `json:"method"`
+	Quote         string     `json:"quote"`
+	CostBasis     float64    `json:"cost_basis"`
+	MarketValue   float64    `json:"market_value"`
+	RealizedPnL   float64    `json:"realized_pnl"`
+	UnrealizedPnL float64    `json:"unrealized_pnl"`
+	Assets        []AssetPnL `json:"assets"`
+}
+
+func NewPnLService(positionRepo *repository.PositionRepository, balanceService *BalanceService) *PnLService {
+	return &PnLService{
+		positionRepo:   positionRepo,
+		balanceService: balanceService,
+	}
+}
+
+type lot struct {
+	amount   float64
+	unitCost float64
+}
+
+// costBasisBook replays acquisitions and disposals of one asset into lots
+// according to a cost basis method.
+type costBasisBook struct {
+	method   string
+	lots     []lot
+	realized float64
+}
+
+func (b *costBasisBook) acquire(amount, cost float64) {
+	if amount <= 0 {
+		return
+	}
+	if b.method == CostBasisAverage && len(b.lots) > 0 {
+		pooled := b.lots[0]
+		totalAmount := pooled.amount + amount
+		b.lots[0] = lot{amount: totalAmount, unitCost: (pooled.amount*pooled.unitCost + cost) / totalAmount}
+		return
+	}
+	b.lots = append(b.lots, lot{amount: amount, unitCost: cost / amount})
+}
+
+// dispose consumes lots for amount and realizes the difference between the
+// proceeds and the consumed cost. Any part of amount not covered by lots has
+// no known cost and is left out of realized PnL.
+func (b *costBasisBook) dispose(amount, proceeds float64) {
+	if amount <= 0 {
+		return
+	}
+	unitProceeds := proceeds / amount
+	remaining := amount
+
+	for remaining > lotEpsilon && len(b.lots) > 0 {
+		i := 0
+		if b.method == CostBasisLIFO {
+			i = len(b.lots) - 1
+		}
+
+		taken := math.Min(remaining, b.lots[i].amount)
+		b.realized += taken * (unitProceeds - b.lots[i].unitCost)
+		b.lots[i].amount -= taken
+		remaining -= taken
+
+		if b.lots[i].amount <= lotEpsilon {
+			b.lots = append(b.lots[:i], b.lots[i+1:]...)
+		}
+	}
+}
+
+func (b *costBasisBook) holdings() (amount, cost float64) {
+	for _, l := range b.lots {
+		amount += l.amount
+		cost += l.amount * l.unitCost
+	}
+	return amount, cost
+}
+
+// GetUserPnL replays the user's position events with the given method and
+// values the open lots at current USD prices.
+func (s *PnLService) GetUserPnL(userID int, method string) (PortfolioPnL, error) {
+	if method != CostBasisFIFO && method != CostBasisLIFO && method != CostBasisAverage {
+		return PortfolioPnL{}, invalidRequest("unsupported cost basis method: %s", method)
+	}
+
+	events, err := s.positionRepo.GetUserEvents(userID)
+	if err != nil {
+		return PortfolioPnL{}, fmt.Errorf("failed to load position events: %v", err)
+	}
+
+	books := map[string]*costBasisBook{}
+	var symbols []string
+	bookFor := func(symbol string) *costBasisBook {
+		book, ok := books[symbol]
+		if !ok {
+			book = &costBasisBook{method: method}
+			books[symbol] = book
+			symbols = append(symbols, symbol)
+		}
+		return book
+	}
+
+	for _, event := range events {
+		book := bookFor(event.CryptoSymbol)
+		if event.Kind == model.PositionAcquisition {
+			book.acquire(event.Amount, event.USDValue)
+		} else {
+			book.dispose(event.Amount, event.USDValue)
+		}
+	}
+
+	portfolio, err := s.balanceService.GetUserBalancesInQuote(userID, DefaultQuoteCurrency)
+	if err != nil {
+		return PortfolioPnL{}, err
+	}
+	balances := map[string]CryptoBalanceType{}
+	for _, balance := range portfolio.Balances {
+		balances[balance.CryptoName] = balance
+		bookFor(balance.CryptoName)
+	}
+
+	result := PortfolioPnL{Method: method, Quote: DefaultQuoteCurrency, Assets: []AssetPnL{}}
+	for _, symbol := range symbols {
+		book := books[symbol]
+		balance := balances[symbol]
+		heldAmount, heldCost := book.holdings()
+
+		covered := math.Min(balance.CryptoBalance, heldAmount)
+		asset := AssetPnL{
+			CryptoName:      symbol,
+			Balance:         balance.CryptoBalance,
+			MarketValue:     balance.QuoteBalance,
+			RealizedPnL:     book.realized,
+			UncoveredAmount: balance.CryptoBalance - covered,
+		}
+		if covered > 0 {
+			asset.CostBasis = heldCost * covered / heldAmount
+			asset.AverageCost = asset.CostBasis / covered
+			asset.UnrealizedPnL = covered*balance.Price - asset.CostBasis
+		}
+
+		result.CostBasis += asset.CostBasis
+		result.MarketValue += asset.MarketValue
+		result.RealizedPnL += asset.RealizedPnL
+		result.UnrealizedPnL += asset.UnrealizedPnL
+		result.Assets = append(result.Assets, asset)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostBasisBook(t *testing.T) {
+	type op struct {
+		dispose     bool
+		amount, usd float64
+	}
+	acquire := func(amount, cost float64) op { return op{amount: amount, usd: cost} }
+	dispose := func(amount, proceeds float64) op { return op{dispose: true, amount: amount, usd: proceeds} }
+
+	twoLotsSoldAcross := []op{acquire(1, 100), acquire(1, 200), dispose(1.5, 450)}
+
+	tests := []struct {
+		name         string
+		method       string
+		ops          []op
+		wantRealized float64
+		wantAmount   float64
+		wantCost     float64
+	}{
+		{"fifo sells oldest lot", CostBasisFIFO, []op{acquire(1, 100), acquire(1, 200), dispose(1, 300)}, 200, 1, 200},
+		{"lifo sells newest lot", CostBasisLIFO, []op{acquire(1, 100), acquire(1, 200), dispose(1, 300)}, 100, 1, 100},
+		{"average pools lots", CostBasisAverage, []op{acquire(1, 100), acquire(1, 200), dispose(1, 300)}, 150, 1, 150},
+		{"fifo disposal across lots", CostBasisFIFO, twoLotsSoldAcross, 250, 0.5, 100},
+		{"lifo disposal across lots", CostBasisLIFO, twoLotsSoldAcross, 200, 0.5, 50},
+		{"average disposal across lots", CostBasisAverage, twoLotsSoldAcross, 225, 0.5, 75},
+		{"partial lot", CostBasisFIFO, []op{acquire(2, 200), dispose(0.5, 100)}, 50, 1.5, 150},
+		{"uncovered disposal is not realized", CostBasisFIFO, []op{acquire(1, 100), dispose(2, 400)}, 100, 0, 0},
+		{"disposal before any acquisition", CostBasisLIFO, []op{dispose(1, 100), acquire(1, 50)}, 0, 1, 50},
+		{"average after emptying the pool", CostBasisAverage, []op{acquire(1, 100), dispose(1, 100), acquire(2, 300)}, 0, 2, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &costBasisBook{method: tt.method}
+			for _, o := range tt.ops {
+				if o.dispose {
+					book.dispose(o.amount, o.usd)
+				} else {
+					book.acquire(o.amount, o.usd)
+				}
+			}
+
+			amount, cost := book.holdings()
+			if math.Abs(book.realized-tt.wantRealized) > 1e-9 ||
+				math.Abs(amount-tt.wantAmount) > 1e-9 ||
+				math.Abs(cost-tt.wantCost) > 1e-9 {
+				t.Errorf("realized %v, holding %v at cost %v, want realized %v, holding %v at cost %v",
+					book.realized, amount, cost, tt.wantRealized, tt.wantAmount, tt.wantCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+// PriceProvider fetches prices from CryptoCompare and records every price it
+// observes into the price history. Recording happens on a background
+// goroutine so that price lookups never wait on the database.
+type PriceProvider struct {
+	historyRepo *repository.HistoryRepository
+	observed    chan model.PricePoint
+}
+
+func NewPriceProvider(historyRepo *repository.HistoryRepository) *PriceProvider {
+	return &PriceProvider{
+		historyRepo: historyRepo,
+		observed:    make(chan model.PricePoint, config.PriceRecorderBuffer),
+	}
+}
+
+// RunRecorder stores observed prices until ctx is cancelled.
+func (p *PriceProvider) RunRecorder(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case point := <-p.observed:
+			err := p.historyRepo.RecordPrice(point)
+			if err != nil {
+				log.Printf("Failed to record price of %s in %s: %v", point.CryptoSymbol, point.QuoteSymbol, err)
+			}
+		}
+	}
+}
+
+func (p *PriceProvider) observe(cryptoSymbol, quoteSymbol string, price float64) {
+	point := model.PricePoint{
+		CryptoSymbol: cryptoSymbol,
+		QuoteSymbol:  quoteSymbol,
+		Price:        price,
+		ObservedAt:   time.Now().UTC(),
+	}
+	select {
+	case p.observed <- point:
+	default:
+		log.Printf("Price recorder is full, dropping price of %s in %s", cryptoSymbol, quoteSymbol)
+	}
+}
+
+func (p *PriceProvider) Price(cryptoSymbol string, quoteSymbol string) (float64, error) {
+	price, _, err := p.PriceWithTime(cryptoSymbol, quoteSymbol)
+	return price, err
+}
+
+// PriceWithTime returns the price of cryptoSymbol in quoteSymbol and the time
+// the price was last updated by the provider. Pairs the provider does not
+// quote directly are derived through USD and carry the older of the two
+// timestamps.
+func (p *PriceProvider) PriceWithTime(cryptoSymbol string, quoteSymbol string) (float64, time.Time, error) {
+	for _, symbol := range []string{cryptoSymbol, quoteSymbol} {
+		if !validSymbol(symbol) {
+			return 0, time.Time{}, invalidRequest("invalid symbol %q: symbols are 1 to 10 capital letters or digits", symbol)
+		}
+	}
+	if cryptoSymbol == quoteSymbol {
+		return 1, time.Now().UTC(), nil
+	}
+
+	price, updatedAt, found, err := fetchCryptoPrice(cryptoSymbol, quoteSymbol)
+	if err != nil {
+		return 0, time.Time{}, priceUnavailable(err, cryptoSymbol, quoteSymbol)
+	}
+	if found {
+		p.observe(cryptoSymbol, quoteSymbol, price)
+		return price, updatedAt, nil
+	}
+
+	if cryptoSymbol == "USD" || quoteSymbol == "USD" {
+		return 0, time.Time{}, priceUnavailable(nil, cryptoSymbol, quoteSymbol)
+	}
+
+	originToUsd, originTime, errOriginToUsd := p.PriceWithTime(cryptoSymbol, "USD")
+	if errOriginToUsd != nil {
+		return 0, time.Time{}, priceUnavailable(errOriginToUsd, cryptoSymbol, quoteSymbol)
+	}
+
+	quoteToUsd, quoteTime, errQuoteToUsd := p.PriceWithTime(quoteSymbol, "USD")
+	if errQuoteToUsd != nil {
+		return 0, time.Time{}, priceUnavailable(errQuoteToUsd, cryptoSymbol, quoteSymbol)
+	}
+	if quoteTime.Before(originTime) {
+		originTime = quoteTime
+	}
+
+	price = originToUsd / quoteToUsd
+	p.observe(cryptoSymbol, quoteSymbol, price)
+	return price, originTime, nil
+}
+
+// symbolPattern matches the asset and currency symbols prices can be asked
+// for.
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+
+func validSymbol(symbol string) bool {
+	return symbolPattern.MatchString(symbol)
+}
+
+// formatURL escapes the symbols as well, although PriceWithTime only passes
+// valid ones.
+func formatURL(cryptoSymbol string, toSymbol string) string {
+	return fmt.Sprintf(config.CryptoCompareAPI, url.QueryEscape(cryptoSymbol), url.QueryEscape(toSymbol))
+}
+
+// fetchCryptoPrice asks CryptoCompare for a single pair. found is false when
+// the provider has no market for the pair.
+func fetchCryptoPrice(cryptoSymbol string, quoteSymbol string) (float64, time.Time, bool, error) {
+	client := &http.Client{
+		Timeout: config.Timeout * time.Second,
+	}
+	resp, err := client.Get(formatURL(cryptoSymbol, quoteSymbol))
+	if err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("failed to fetch cryptocurrency price: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, time.Time{}, false, fmt.Errorf("unexpected status code from CryptoCompare API: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("failed to unmarshal JSON response: %v", err)
+	}
+
+	_, priceNotExist := data["Response"].(string)
+	if priceNotExist {
+		return 0, time.Time{}, false, nil
+	}
+
+	raw, ok := data["RAW"].(map[string]interface{})
+	if !ok {
+		return 0, time.Time{}, false, fmt.Errorf("failed to extract RAW data from response")
+	}
+
+	price, ok := raw["PRICE"].(float64)
+	if !ok {
+		return 0, time.Time{}, false, fmt.Errorf("failed to extract PRICE from RAW data")
+	}
+
+	updatedAt := time.Now().UTC()
+	if lastUpdate, ok := raw["LASTUPDATE"].(float64); ok && lastUpdate > 0 {
+		updatedAt = time.Unix(int64(lastUpdate), 0).UTC()
+	}
+
+	return price, updatedAt, true, nil
+}
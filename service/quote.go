@@ -0,0 +1,165 @@
+package service
+
+import (
+	"math"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+const (
+	FixedSideSource = "source"
+	FixedSideTarget = "target"
+)
+
+// Quote is a priced exchange, possibly routed through intermediate assets.
+// Rate is the market rate of the whole route before spreads and fees, and Fee
+// is everything the house keeps, expressed in the target asset.
+type Quote struct {
+	SourceCrypto string     `json:"sourceCrypto"`
+	TargetCrypto string     `json:"targetCrypto"`
+	SourceAmount float64    `json:"sourceAmount"`
+	TargetAmount float64    `json:"targetAmount"`
+	Fee          float64    `json:"fee"`
+	Rate         float64    `json:"rate"`
+	FixedSide    string     `json:"fixedSide"`
+	MaxSlippage  float64    `json:"maxSlippage"`
+	Legs         []QuoteLeg `json:"legs"`
+	Token        string     `json:"token,omitempty"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+}
+
+// QuoteLeg is one conversion of a routed quote. The spread is applied to the
+// market rate and the fee, charged in the leg's target asset, on the result.
+type QuoteLeg struct {
+	SourceCrypto string  `json:"sourceCrypto"`
+	TargetCrypto string  `json:"targetCrypto"`
+	SourceAmount float64 `json:"sourceAmount"`
+	TargetAmount float64 `json:"targetAmount"`
+	Rate         float64 `json:"rate"`
+	Spread       float64 `json:"spread"`
+	FeeRate      float64 `json:"feeRate"`
+	Fee          float64 `json:"fee"`
+}
+
+func (l QuoteLeg) effectiveRate() float64 {
+	return l.Rate * (1 - l.Spread)
+}
+
+func (q Quote) exchangeLegs() []repository.ExchangeLeg {
+	legs := make([]repository.ExchangeLeg, len(q.Legs))
+	for i, leg := range q.Legs {
+		legs[i] = repository.ExchangeLeg{
+			SourceCrypto: leg.SourceCrypto,
+			TargetCrypto: leg.TargetCrypto,
+			SourceAmount: leg.SourceAmount,
+			TargetAmount: leg.TargetAmount,
+			Fee:          leg.Fee,
+		}
+	}
+	return legs
+}
+
+func (q Quote) exchange(userID int) model.Exchange {
+	return model.Exchange{
+		UserID:       userID,
+		SourceCrypto: q.SourceCrypto,
+		TargetCrypto: q.TargetCrypto,
+		SourceAmount: q.SourceAmount,
+		TargetAmount: q.TargetAmount,
+		Fee:          q.Fee,
+		Rate:         q.Rate,
+	}
+}
+
+// roundingEpsilon absorbs float representation error so that amounts which
+// are already exact at a given scale are not pushed to the neighbouring unit.
+const roundingEpsilon = 1e-9
+
+func roundDown(amount float64, scale int) float64 {
+	factor := math.Pow(10, float64(scale))
+	return math.Floor(amount*factor+roundingEpsilon) / factor
+}
+
+func roundUp(amount float64, scale int) float64 {
+	factor := math.Pow(10, float64(scale))
+	return math.Ceil(amount*factor-roundingEpsilon) / factor
+}
+
+// priceRoute fills in the amounts of every leg of route, starting from the
+// fixed side. Amounts are rounded to each asset's scale in the house's
+// favour: received amounts are rounded down and spent amounts rounded up.
+func (s *BalanceService) priceRoute(route []QuoteLeg, amount float64, fixedSide string) (Quote, error) {
+	scales := map[string]int{}
+	for _, leg := range route {
+		for _, symbol := range []string{leg.SourceCrypto, leg.TargetCrypto} {
+			if _, ok := scales[symbol]; ok {
+				continue
+			}
+			scale, err := s.cryptoRepo.GetCryptoScale(symbol)
+			if err != nil {
+				return Quote{}, newError(CodeUnknownAsset, err, "unknown cryptocurrency: %s", symbol)
+			}
+			scales[symbol] = scale
+		}
+	}
+	return priceLegs(route, scales, amount, fixedSide)
+}
+
+// priceLegs is priceRoute with the scale of every asset on route given.
+func priceLegs(route []QuoteLeg, scales map[string]int, amount float64, fixedSide string) (Quote, error) {
+	legs := append([]QuoteLeg(nil), route...)
+	quote := Quote{
+		SourceCrypto: legs[0].SourceCrypto,
+		TargetCrypto: legs[len(legs)-1].TargetCrypto,
+		Rate:         1,
+		FixedSide:    fixedSide,
+		Legs:         legs,
+	}
+	for _, leg := range legs {
+		quote.Rate *= leg.Rate
+	}
+
+	switch fixedSide {
+	case FixedSideSource:
+		next := roundDown(amount, scales[quote.SourceCrypto])
+		for i := range legs {
+			legs[i].SourceAmount = next
+			gross := next * legs[i].effectiveRate()
+			legs[i].TargetAmount = roundDown(gross*(1-legs[i].FeeRate), scales[legs[i].TargetCrypto])
+			legs[i].Fee = gross - legs[i].TargetAmount
+			next = legs[i].TargetAmount
+		}
+	case FixedSideTarget:
+		next := roundDown(amount, scales[quote.TargetCrypto])
+		for i := len(legs) - 1; i >= 0; i-- {
+			legs[i].TargetAmount = next
+			gross := next / (1 - legs[i].FeeRate)
+			legs[i].SourceAmount = roundUp(gross/legs[i].effectiveRate(), scales[legs[i].SourceCrypto])
+			legs[i].Fee = legs[i].SourceAmount*legs[i].effectiveRate() - next
+			next = legs[i].SourceAmount
+		}
+	default:
+		return Quote{}, invalidRequest("unsupported fixed side: %s", fixedSide)
+	}
+
+	quote.SourceAmount = legs[0].SourceAmount
+	quote.TargetAmount = legs[len(legs)-1].TargetAmount
+	quote.Fee = quote.SourceAmount*quote.Rate - quote.TargetAmount
+
+	for _, leg := range legs {
+		if leg.SourceAmount <= 0 || leg.TargetAmount <= 0 {
+			return Quote{}, invalidRequest("amount is too small to exchange")
+		}
+	}
+
+	return quote, nil
+}
+
+// fixedAmount returns the amount on the side the client fixed.
+func (q Quote) fixedAmount() float64 {
+	if q.FixedSide == FixedSideTarget {
+		return q.TargetAmount
+	}
+	return q.SourceAmount
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriceLegs(t *testing.T) {
+	scales := map[string]int{"BTC": 4, "ETH": 6, "USDT": 2}
+
+	tests := []struct {
+		name       string
+		route      []QuoteLeg
+		amount     float64
+		fixedSide  string
+		wantSource float64
+		wantTarget float64
+		wantFee    float64
+		wantErr    bool
+	}{
+		{
+			name:       "source fixed rounds received amount down",
+			route:      []QuoteLeg{{SourceCrypto: "BTC", TargetCrypto: "USDT", Rate: 100, FeeRate: 0.01}},
+			amount:     1.234567,
+			fixedSide:  FixedSideSource,
+			wantSource: 1.2345,
+			wantTarget: 122.21,
+			wantFee:    1.24,
+		},
+		{
+			name:       "target fixed rounds spent amount up",
+			route:      []QuoteLeg{{SourceCrypto: "BTC", TargetCrypto: "USDT", Rate: 100, FeeRate: 0.01}},
+			amount:     100,
+			fixedSide:  FixedSideTarget,
+			wantSource: 1.0102,
+			wantTarget: 100,
+			wantFee:    1.02,
+		},
+		{
+			name:       "exact amounts are not pushed to the next unit",
+			route:      []QuoteLeg{{SourceCrypto: "USDT", TargetCrypto: "BTC", Rate: 0.01}},
+			amount:     0.29,
+			fixedSide:  FixedSideSource,
+			wantSource: 0.29,
+			wantTarget: 0.0029,
+		},
+		{
+			name: "spread and fee apply on each leg",
+			route: []QuoteLeg{
+				{SourceCrypto: "BTC", TargetCrypto: "ETH", Rate: 20, Spread: 0.5},
+				{SourceCrypto: "ETH", TargetCrypto: "USDT", Rate: 5, FeeRate: 0.001},
+			},
+			amount:     1,
+			fixedSide:  FixedSideSource,
+			wantSource: 1,
+			wantTarget: 49.95,
+			wantFee:    50.05,
+		},
+		{
+			name:      "amount rounding to zero",
+			route:     []QuoteLeg{{SourceCrypto: "BTC", TargetCrypto: "USDT", Rate: 100}},
+			amount:    0.00001,
+			fixedSide: FixedSideSource,
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported fixed side",
+			route:     []QuoteLeg{{SourceCrypto: "BTC", TargetCrypto: "USDT", Rate: 100}},
+			amount:    1,
+			fixedSide: "both",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := priceLegs(tt.route, scales, tt.amount, tt.fixedSide)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("priceLegs = %+v, want an error", quote)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("priceLegs: %v", err)
+			}
+			if math.Abs(quote.SourceAmount-tt.wantSource) > 1e-9 ||
+				math.Abs(quote.TargetAmount-tt.wantTarget) > 1e-9 ||
+				math.Abs(quote.Fee-tt.wantFee) > 1e-9 {
+				t.Errorf("priceLegs = %v %s for %v %s with fee %v, want %v for %v with fee %v",
+					quote.SourceAmount, quote.SourceCrypto, quote.TargetAmount, quote.TargetCrypto, quote.Fee,
+					tt.wantSource, tt.wantTarget, tt.wantFee)
+			}
+		})
+	}
+}
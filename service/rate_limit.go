@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"swap-wallet/config"
+	"swap-wallet/middleware"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimiter limits route groups per calling user and per client IP with
+// token buckets kept in Redis, so every instance of the service shares the
+// same limits.
+type RateLimiter struct {
+	redisClient *redis.Client
+	limits      map[string]config.RouteRateLimit
+}
+
+// RateLimitResult is the state of a bucket after a request took from it.
+// Reset is how long until the bucket is full again.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// takeTokens refills each bucket at KEYS for the time since it was last
+// used, then takes one token from every bucket if each has one, and from
+// none otherwise, so a request rejected by one bucket does not use up
+// another. ARGV holds the burst and rate of each bucket in turn. It replies
+// whether the tokens were taken, then the tokens left, the seconds until a
+// token is available and the seconds until the bucket is full, for each
+// bucket. It runs on the Redis clock so that instances with drifting clocks
+// agree. Buckets expire once they would be full again. Older Redis versions
+// only allow writes after TIME with effects replication, which newer ones
+// always use.
+var takeTokens = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local buckets = {}
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	local burst = tonumber(ARGV[2 * i - 1])
+	local rate = tonumber(ARGV[2 * i])
+	local state = redis.call('HMGET', key, 'tokens', 'updated')
+	local tokens = tonumber(state[1])
+	local updated = tonumber(state[2])
+	if tokens == nil or updated == nil then
+		tokens = burst
+		updated = now
+	end
+	tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+	if tokens < 1 then
+		allowed = 0
+	end
+	buckets[i] = {burst = burst, rate = rate, tokens = tokens}
+end
+
+local reply = {allowed}
+for i, key in ipairs(KEYS) do
+	local bucket = buckets[i]
+	local retry = 0
+	if allowed == 1 then
+		bucket.tokens = bucket.tokens - 1
+	elseif bucket.tokens < 1 then
+		retry = (1 - bucket.tokens) / bucket.rate
+	end
+
+	local reset = (bucket.burst - bucket.tokens) / bucket.rate
+	redis.call('HSET', key, 'tokens', tostring(bucket.tokens), 'updated', tostring(now))
+	redis.call('EXPIRE', key, math.ceil(reset) + 1)
+	table.insert(reply, tostring(bucket.tokens))
+	table.insert(reply, tostring(retry))
+	table.insert(reply, tostring(reset))
+end
+return reply
+`)
+
+func NewRateLimiter(redisClient *redis.Client) *RateLimiter {
+	return &RateLimiter{
+		redisClient: redisClient,
+		limits:      config.LoadRateLimits(),
+	}
+}
+
+func rateLimitKey(group, scope, id string) string {
+	return "ratelimit:" + group + ":" + scope + ":" + id
+}
+
+// Allow takes a token for a request of the route group from the bucket of
+// userID and from that of ip, skipping either when it is empty, in a single
+// step: when one bucket is empty the request takes from neither. It returns
+// the most constrained bucket, which for a rejected request is the one that
+// refills last, and a rate_limited error when one was empty. Requests are
+// let through when Redis fails, so an outage of the limiter does not take
+// the API down with it.
+func (l *RateLimiter) Allow(ctx context.Context, group, userID, ip string) (RateLimitResult, error) {
+	limit := l.limits[group]
+	var keys []string
+	var limits []config.RateLimit
+	if userID != "" && limit.PerUser.Burst > 0 {
+		keys = append(keys, rateLimitKey(group, "user", userID))
+		limits = append(limits, limit.PerUser)
+	}
+	if ip != "" && limit.PerIP.Burst > 0 {
+		keys = append(keys, rateLimitKey(group, "ip", ip))
+		limits = append(limits, limit.PerIP)
+	}
+	if len(keys) == 0 {
+		return RateLimitResult{Allowed: true}, nil
+	}
+
+	buckets, err := l.take(ctx, keys, limits)
+	if err != nil {
+		log.Printf("Request %s was not rate limited: %v", middleware.RequestIDFrom(ctx), err)
+		return RateLimitResult{Allowed: true}, nil
+	}
+
+	result := buckets[0]
+	for _, bucket := range buckets[1:] {
+		if (result.Allowed && bucket.Remaining < result.Remaining) || (!result.Allowed && bucket.RetryAfter > result.RetryAfter) {
+			result = bucket
+		}
+	}
+	if !result.Allowed {
+		return result, newError(CodeRateLimited, nil, "too many requests, retry in %d seconds", retryAfterSeconds(result.RetryAfter))
+	}
+	return result, nil
+}
+
+// retryAfterSeconds rounds d up to whole seconds, as Retry-After needs.
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// RetryAfterSeconds is the Retry-After of a rejected request.
+func (r RateLimitResult) RetryAfterSeconds() int {
+	return retryAfterSeconds(r.RetryAfter)
+}
+
+// take runs takeTokens on the buckets at keys, which hold up to the Burst
+// of the matching limits, and returns the state of each.
+func (l *RateLimiter) take(ctx context.Context, keys []string, limits []config.RateLimit) ([]RateLimitResult, error) {
+	args := make([]interface{}, 0, 2*len(limits))
+	for _, limit := range limits {
+		args = append(args, limit.Burst, limit.Rate)
+	}
+	values, err := takeTokens.Run(ctx, l.redisClient, keys, args...).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take rate limit tokens: %v", err)
+	}
+	if len(values) != 1+3*len(keys) {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	results := make([]RateLimitResult, len(keys))
+	for i := range results {
+		var numbers [3]float64
+		for j := range numbers {
+			text, _ := values[1+3*i+j].(string)
+			numbers[j], err = strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected rate limit reply: %v", values)
+			}
+		}
+
+		results[i] = RateLimitResult{
+			Allowed:    allowed == 1,
+			Limit:      limits[i].Burst,
+			Remaining:  int(math.Floor(numbers[0])),
+			RetryAfter: time.Duration(numbers[1] * float64(time.Second)),
+			Reset:      time.Duration(numbers[2] * float64(time.Second)),
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"fmt"
+	"swap-wallet/config"
+	"swap-wallet/model"
+)
+
+// findRoute picks the path from sourceCrypto to targetCrypto that yields the
+// most target per unit of source after spreads and fees, using the enabled
+// trading pairs as a graph of at most config.MaxRouteHops legs. When no pairs
+// are configured every available asset trades directly with every other at the
+// default fee. The returned legs carry live rates but no amounts.
+func (s *BalanceService) findRoute(sourceCrypto, targetCrypto string) ([]QuoteLeg, error) {
+	if sourceCrypto == targetCrypto {
+		return nil, invalidRequest("source and target must differ")
+	}
+
+	pairs, err := s.cryptoRepo.GetTradingPairs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trading pairs: %v", err)
+	}
+
+	if len(pairs) == 0 {
+		return s.directRoute(sourceCrypto, targetCrypto)
+	}
+
+	graph := s.buildPairGraph(pairs)
+	rates := map[string]float64{}
+	rateErrors := map[string]error{}
+	return bestRoute(graph, sourceCrypto, targetCrypto, func(leg QuoteLeg) (float64, error) {
+		key := leg.SourceCrypto + "/" + leg.TargetCrypto
+		if err, failed := rateErrors[key]; failed {
+			return 0, err
+		}
+		if rate, ok := rates[key]; ok {
+			return rate, nil
+		}
+		rate, err := s.prices.Price(leg.SourceCrypto, leg.TargetCrypto)
+		if err != nil {
+			rateErrors[key] = err
+			return 0, err
+		}
+		rates[key] = rate
+		return rate, nil
+	})
+}
+
+// bestRoute walks graph for the path of at most config.MaxRouteHops legs from
+// sourceCrypto to targetCrypto with the best return, pricing each leg with
+// legRate. Legs that cannot be priced are skipped.
+func bestRoute(graph map[string][]QuoteLeg, sourceCrypto, targetCrypto string, legRate func(QuoteLeg) (float64, error)) ([]QuoteLeg, error) {
+	var best []QuoteLeg
+	var bestFactor float64
+	var lastErr error
+	visited := map[string]bool{sourceCrypto: true}
+	var path []QuoteLeg
+
+	var walk func(symbol string, factor float64)
+	walk = func(symbol string, factor float64) {
+		if symbol == targetCrypto {
+			if factor > bestFactor {
+				bestFactor = factor
+				best = append([]QuoteLeg(nil), path...)
+			}
+			return
+		}
+		if len(path) == config.MaxRouteHops {
+			return
+		}
+
+		for _, edge := range graph[symbol] {
+			if visited[edge.TargetCrypto] {
+				continue
+			}
+			rate, err := legRate(edge)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			edge.Rate = rate
+
+			visited[edge.TargetCrypto] = true
+			path = append(path, edge)
+			walk(edge.TargetCrypto, factor*edge.effectiveRate()*(1-edge.FeeRate))
+			path = path[:len(path)-1]
+			visited[edge.TargetCrypto] = false
+		}
+	}
+	walk(sourceCrypto, 1)
+
+	if best == nil {
+		if lastErr != nil {
+			return nil, fmt.Errorf("no priced route from %s to %s: %w", sourceCrypto, targetCrypto, lastErr)
+		}
+		return nil, newError(CodeNoRoute, nil, "no route from %s to %s", sourceCrypto, targetCrypto)
+	}
+
+	return best, nil
+}
+
+func (s *BalanceService) directRoute(sourceCrypto, targetCrypto string) ([]QuoteLeg, error) {
+	cryptos, err := s.cryptoRepo.GetCryptocurrencies()
+	if err != nil {
+		return nil, err
+	}
+
+	available := map[string]bool{}
+	for _, crypto := range cryptos {
+		available[crypto.Symbol] = crypto.IsAvailable
+	}
+	for _, symbol := range []string{sourceCrypto, targetCrypto} {
+		isAvailable, known := available[symbol]
+		if !known {
+			return nil, newError(CodeUnknownAsset, nil, "unknown cryptocurrency: %s", symbol)
+		}
+		if !isAvailable {
+			return nil, newError(CodeAssetDisabled, nil, "cryptocurrency not available: %s", symbol)
+		}
+	}
+
+	rate, err := s.prices.Price(sourceCrypto, targetCrypto)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price for %s: %w", sourceCrypto, err)
+	}
+
+	return []QuoteLeg{{
+		SourceCrypto: sourceCrypto,
+		TargetCrypto: targetCrypto,
+		Rate:         rate,
+		FeeRate:      s.feeRate,
+	}}, nil
+}
+
+// buildPairGraph turns each pair into edges in both directions. Pairs without
+// their own fee rate use the default exchange fee.
+func (s *BalanceService) buildPairGraph(pairs []model.TradingPair) map[string][]QuoteLeg {
+	graph := map[string][]QuoteLeg{}
+	for _, pair := range pairs {
+		feeRate := s.feeRate
+		if pair.FeeRate != nil {
+			feeRate = *pair.FeeRate
+		}
+		graph[pair.BaseSymbol] = append(graph[pair.BaseSymbol], QuoteLeg{
+			SourceCrypto: pair.BaseSymbol,
+			TargetCrypto: pair.QuoteSymbol,
+			Spread:       pair.Spread,
+			FeeRate:      feeRate,
+		})
+		graph[pair.QuoteSymbol] = append(graph[pair.QuoteSymbol], QuoteLeg{
+			SourceCrypto: pair.QuoteSymbol,
+			TargetCrypto: pair.BaseSymbol,
+			Spread:       pair.Spread,
+			FeeRate:      feeRate,
+		})
+	}
+	return graph
+}
+
+// refreshRoute returns the legs of route with live rates.
+func (s *BalanceService) refreshRoute(route []QuoteLeg) ([]QuoteLeg, error) {
+	refreshed := make([]QuoteLeg, len(route))
+	for i, leg := range route {
+		rate, err := s.prices.Price(leg.SourceCrypto, leg.TargetCrypto)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-check price for %s: %w", leg.SourceCrypto, err)
+		}
+		refreshed[i] = QuoteLeg{
+			SourceCrypto: leg.SourceCrypto,
+			TargetCrypto: leg.TargetCrypto,
+			Rate:         rate,
+			Spread:       leg.Spread,
+			FeeRate:      leg.FeeRate,
+		}
+	}
+	return refreshed, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"swap-wallet/model"
+)
+
+func TestBestRoute(t *testing.T) {
+	zeroFee := 0.0
+	pair := func(base, quote string, feeRate *float64) model.TradingPair {
+		return model.TradingPair{BaseSymbol: base, QuoteSymbol: quote, FeeRate: feeRate}
+	}
+	// chain links a pair between each neighbour of symbols.
+	chain := func(symbols ...string) []model.TradingPair {
+		var pairs []model.TradingPair
+		for i := 1; i < len(symbols); i++ {
+			pairs = append(pairs, pair(symbols[i-1], symbols[i], &zeroFee))
+		}
+		return pairs
+	}
+	errUnpriced := errors.New("no price")
+
+	tests := []struct {
+		name     string
+		pairs    []model.TradingPair
+		unpriced string
+		source   string
+		target   string
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "direct pair",
+			pairs:    chain("BTC", "USDT"),
+			source:   "BTC",
+			target:   "USDT",
+			wantPath: "BTC>USDT",
+		},
+		{
+			name:     "reverse direction of a pair",
+			pairs:    chain("BTC", "USDT"),
+			source:   "USDT",
+			target:   "BTC",
+			wantPath: "USDT>BTC",
+		},
+		{
+			name:     "cheaper route through an intermediate asset",
+			pairs:    append(chain("BTC", "ETH", "USDT"), pair("BTC", "USDT", nil)),
+			source:   "BTC",
+			target:   "USDT",
+			wantPath: "BTC>ETH>USDT",
+		},
+		{
+			name:     "route of config.MaxRouteHops legs",
+			pairs:    chain("A", "B", "C", "D"),
+			source:   "A",
+			target:   "D",
+			wantPath: "A>B>C>D",
+		},
+		{
+			name:    "route longer than config.MaxRouteHops legs",
+			pairs:   chain("A", "B", "C", "D", "E"),
+			source:  "A",
+			target:  "E",
+			wantErr: true,
+		},
+		{
+			name:     "unpriced leg is skipped",
+			pairs:    append(chain("BTC", "ETH", "USDT"), pair("BTC", "USDT", nil)),
+			unpriced: "ETH/USDT",
+			source:   "BTC",
+			target:   "USDT",
+			wantPath: "BTC>USDT",
+		},
+		{
+			name:     "only route is unpriced",
+			pairs:    chain("BTC", "USDT"),
+			unpriced: "BTC/USDT",
+			source:   "BTC",
+			target:   "USDT",
+			wantErr:  true,
+		},
+	}
+
+	s := &BalanceService{feeRate: 0.01}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legRate := func(leg QuoteLeg) (float64, error) {
+				if leg.SourceCrypto+"/"+leg.TargetCrypto == tt.unpriced {
+					return 0, errUnpriced
+				}
+				return 1, nil
+			}
+
+			route, err := bestRoute(s.buildPairGraph(tt.pairs), tt.source, tt.target, legRate)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("bestRoute = %v, want an error", route)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bestRoute: %v", err)
+			}
+
+			path := []string{route[0].SourceCrypto}
+			for _, leg := range route {
+				path = append(path, leg.TargetCrypto)
+			}
+			if got := strings.Join(path, ">"); got != tt.wantPath {
+				t.Errorf("route = %s, want %s", got, tt.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+type ScheduleService struct {
+	scheduleRepo   *repository.ScheduleRepository
+	balanceService *BalanceService
+}
+
+type CreateScheduleRequest struct {
+	SourceCrypto    string  `json:"source" required:"true"`
+	TargetCrypto    string  `json:"target" required:"true"`
+	SourceAmount    float64 `json:"sourceAmount" required:"true" minimum:"0"`
+	Recurrence      string  `json:"recurrence" required:"true" enum:"interval,daily,weekly"`
+	IntervalSeconds int     `json:"intervalSeconds" minimum:"0" doc:"Required for interval schedules"`
+	Weekday         int     `json:"weekday" minimum:"0" maximum:"6" doc:"0 is Sunday; weekly schedules only"`
+	Hour            int     `json:"hour" minimum:"0" maximum:"23" doc:"UTC"`
+	Minute          int     `json:"minute" minimum:"0" maximum:"59"`
+}
+
+func NewScheduleService(scheduleRepo *repository.ScheduleRepository, balanceService *BalanceService) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:   scheduleRepo,
+		balanceService: balanceService,
+	}
+}
+
+func validateSchedule(req CreateScheduleRequest) error {
+	if req.SourceCrypto == "" || req.TargetCrypto == "" {
+		return invalidRequest("source and target are required")
+	}
+	if req.SourceCrypto == req.TargetCrypto {
+		return invalidRequest("source and target must differ")
+	}
+	if req.SourceAmount <= 0 {
+		return invalidRequest("sourceAmount must be positive")
+	}
+	if req.Hour < 0 || req.Hour > 23 || req.Minute < 0 || req.Minute > 59 {
+		return invalidRequest("invalid time of day %02d:%02d", req.Hour, req.Minute)
+	}
+
+	switch req.Recurrence {
+	case model.RecurrenceInterval:
+		if req.IntervalSeconds < config.MinScheduleInterval {
+			return invalidRequest("intervalSeconds must be at least %d", config.MinScheduleInterval)
+		}
+	case model.RecurrenceDaily:
+	case model.RecurrenceWeekly:
+		if req.Weekday < 0 || req.Weekday > 6 {
+			return invalidRequest("weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	default:
+		return invalidRequest("unsupported recurrence: %s", req.Recurrence)
+	}
+
+	return nil
+}
+
+// nextRunAt returns the first occurrence of the schedule strictly after from.
+// Daily and weekly recurrences are evaluated in UTC.
+func nextRunAt(schedule model.Schedule, from time.Time) time.Time {
+	from = from.UTC()
+
+	switch schedule.Recurrence {
+	case model.RecurrenceInterval:
+		return from.Add(time.Duration(schedule.IntervalSeconds) * time.Second)
+	case model.RecurrenceDaily:
+		next := time.Date(from.Year(), from.Month(), from.Day(), schedule.Hour, schedule.Minute, 0, 0, time.UTC)
+		if !next.After(from) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		next := time.Date(from.Year(), from.Month(), from.Day(), schedule.Hour, schedule.Minute, 0, 0, time.UTC)
+		days := (schedule.Weekday - int(next.Weekday()) + 7) % 7
+		next = next.AddDate(0, 0, days)
+		if !next.After(from) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+}
+
+// errNeedsApproval fails runs above the two-factor threshold that the user
+// has not approved, or whose approval lapsed.
+var errNeedsApproval = errors.New("schedule needs two-factor approval")
+
+// CreateSchedule creates a recurring exchange for userID. Exchanges above
+// the two-factor threshold need totpCode here, which approves the runs that
+// happen later without the user.
+func (s *ScheduleService) CreateSchedule(ctx context.Context, userID int, req CreateScheduleRequest, totpCode string) (model.Schedule, error) {
+	if err := validateSchedule(req); err != nil {
+		return model.Schedule{}, err
+	}
+
+	for _, symbol := range []string{req.SourceCrypto, req.TargetCrypto} {
+		cryptoID, err := s.balanceService.cryptoRepo.FindBySymbol(symbol)
+		if err != nil {
+			return model.Schedule{}, err
+		}
+		if cryptoID == -1 {
+			return model.Schedule{}, newError(CodeUnknownAsset, nil, "unknown cryptocurrency: %s", symbol)
+		}
+	}
+
+	approvedAt, err := s.balanceService.twoFactor.ApproveAmount(ctx, userID, req.SourceCrypto, req.SourceAmount, totpCode)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	schedule := model.Schedule{
+		UserID:              userID,
+		SourceCrypto:        req.SourceCrypto,
+		TargetCrypto:        req.TargetCrypto,
+		SourceAmount:        req.SourceAmount,
+		Recurrence:          req.Recurrence,
+		IntervalSeconds:     req.IntervalSeconds,
+		Weekday:             req.Weekday,
+		Hour:                req.Hour,
+		Minute:              req.Minute,
+		Status:              model.ScheduleStatusActive,
+		TwoFactorApprovedAt: approvedAt,
+	}
+	schedule.NextRunAt = nextRunAt(schedule, time.Now())
+
+	return s.scheduleRepo.CreateSchedule(schedule)
+}
+
+func (s *ScheduleService) GetUserSchedules(userID int) ([]model.Schedule, error) {
+	return s.scheduleRepo.GetUserSchedules(userID)
+}
+
+func (s *ScheduleService) PauseSchedule(userID int, scheduleID int) error {
+	schedule, err := s.scheduleRepo.GetSchedule(userID, scheduleID)
+	if err != nil {
+		return err
+	}
+	return s.scheduleRepo.UpdateStatus(userID, scheduleID, model.ScheduleStatusPaused, schedule.NextRunAt)
+}
+
+// ResumeSchedule reactivates a schedule from the next occurrence after now, so
+// runs missed while paused are not executed retroactively. Schedules above
+// the two-factor threshold need totpCode, which approves their runs again.
+func (s *ScheduleService) ResumeSchedule(ctx context.Context, userID int, scheduleID int, totpCode string) error {
+	schedule, err := s.scheduleRepo.GetSchedule(userID, scheduleID)
+	if err != nil {
+		return err
+	}
+	approvedAt, err := s.balanceService.twoFactor.ApproveAmount(ctx, userID, schedule.SourceCrypto, schedule.SourceAmount, totpCode)
+	if err != nil {
+		return err
+	}
+	if approvedAt != nil {
+		err = s.scheduleRepo.ApproveTwoFactor(userID, scheduleID, *approvedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return s.scheduleRepo.UpdateStatus(userID, scheduleID, model.ScheduleStatusActive, nextRunAt(schedule, time.Now()))
+}
+
+func (s *ScheduleService) DeleteSchedule(userID int, scheduleID int) error {
+	return s.scheduleRepo.DeleteSchedule(userID, scheduleID)
+}
+
+func (s *ScheduleService) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(config.SchedulerTickInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDueSchedules(ctx, now)
+		}
+	}
+}
+
+func (s *ScheduleService) runDueSchedules(ctx context.Context, now time.Time) {
+	schedules, err := s.scheduleRepo.GetDueSchedules(now)
+	if err != nil {
+		log.Printf("Failed to load due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		claimed, err := s.scheduleRepo.ClaimSchedule(schedule.ID, schedule.NextRunAt, nextRunAt(schedule, now))
+		if err != nil {
+			log.Printf("Failed to claim schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		s.executeSchedule(ctx, schedule, now)
+	}
+}
+
+// executeSchedule quotes and finalizes a single occurrence. An occurrence with
+// insufficient balance is skipped without counting as a failure; any other
+// error counts as a failure and the schedule is paused after
+// config.MaxScheduleFailures consecutive failures. A run above the two-factor
+// threshold without a standing approval pauses the schedule at once and
+// tells the user through a schedule.paused event.
+func (s *ScheduleService) executeSchedule(ctx context.Context, schedule model.Schedule, now time.Time) {
+	status := model.ScheduleStatusActive
+	failures := schedule.ConsecutiveFailures
+	var inTx []func(tx *sql.Tx) error
+
+	result, err := s.exchange(ctx, schedule)
+	if errors.Is(err, errNeedsApproval) {
+		status = model.ScheduleStatusPaused
+		result = fmt.Sprintf("paused: exchanges worth more than %s USD need two-factor approval; resume the schedule with a code",
+			strconv.FormatFloat(s.balanceService.twoFactor.exchangeThreshold, 'f', -1, 64))
+		paused := schedule
+		paused.Status = status
+		paused.LastRunAt = &now
+		paused.LastResult = result
+		inTx = append(inTx, func(tx *sql.Tx) error {
+			return s.balanceService.events.PublishSchedulePaused(tx, paused, result)
+		})
+	} else if err != nil {
+		failures++
+		result = fmt.Sprintf("failed: %v", err)
+		if failures >= config.MaxScheduleFailures {
+			status = model.ScheduleStatusPaused
+		}
+	} else if result == "completed" {
+		failures = 0
+	}
+
+	err = s.scheduleRepo.RecordRun(schedule.ID, now, result, failures, status, inTx...)
+	if err != nil {
+		log.Printf("Failed to record run of schedule %d: %v", schedule.ID, err)
+	}
+}
+
+func (s *ScheduleService) exchange(ctx context.Context, schedule model.Schedule) (string, error) {
+	balance, err := s.balanceService.getUserBalance(schedule.UserID, schedule.SourceCrypto)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if balance < schedule.SourceAmount {
+		return "skipped: insufficient balance", nil
+	}
+
+	quote, err := s.balanceService.GetExchangePreview(ctx, schedule.UserID, schedule.SourceCrypto, schedule.TargetCrypto, schedule.SourceAmount, FixedSideSource, 0)
+	if err != nil {
+		return "", err
+	}
+
+	// The threshold is checked on every run, since prices move and schedules
+	// may predate it.
+	err = s.balanceService.finalizeExchange(ctx, schedule.UserID, quote.Token, func(Quote) error {
+		return s.authorizeRun(schedule)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "completed", nil
+}
+
+// authorizeRun fails with errNeedsApproval when a run of schedule is above
+// the two-factor threshold and the user's approval of it does not stand.
+func (s *ScheduleService) authorizeRun(schedule model.Schedule) error {
+	twoFactor := s.balanceService.twoFactor
+	if !twoFactor.ExceedsThreshold(schedule.SourceCrypto, schedule.SourceAmount) {
+		return nil
+	}
+	approved, err := twoFactor.Approved(schedule.UserID, schedule.TwoFactorApprovedAt)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return errNeedsApproval
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	StatementCSV = "csv"
+	StatementPDF = "pdf"
+)
+
+type StatementService struct {
+	ledgerRepo  *repository.LedgerRepository
+	balanceRepo *repository.BalanceRepository
+	cryptoRepo  *repository.CryptocurrencyRepository
+	userRepo    *repository.UserRepository
+}
+
+type Statement struct {
+	Username string
+	From     time.Time
+	To       time.Time
+	Assets   []AssetStatement
+}
+
+// AssetStatement lists the ledger entries of one asset in a period. Amounts
+// are in the asset's smallest unit and formatted with Scale when rendered.
+type AssetStatement struct {
+	CryptoSymbol   string
+	Scale          int
+	OpeningBalance int64
+	ClosingBalance int64
+	Entries        []model.LedgerEntry
+}
+
+func NewStatementService(ledgerRepo *repository.LedgerRepository, balanceRepo *repository.BalanceRepository, cryptoRepo *repository.CryptocurrencyRepository, userRepo *repository.UserRepository) *StatementService {
+	return &StatementService{
+		ledgerRepo:  ledgerRepo,
+		balanceRepo: balanceRepo,
+		cryptoRepo:  cryptoRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// formatUnits renders an amount in an asset's smallest unit as a decimal with
+// exactly scale fractional digits.
+func formatUnits(units int64, scale int) string {
+	if scale <= 0 {
+		return strconv.FormatInt(units, 10)
+	}
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	divisor := int64(1)
+	for i := 0; i < scale; i++ {
+		divisor *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/divisor, scale, units%divisor)
+}
+
+// GetStatement builds the statement for [from, to). Opening and closing
+// balances are derived backwards from the current balance, so they are
+// correct even for balances that predate the ledger.
+func (s *StatementService) GetStatement(userID int, from, to time.Time) (Statement, error) {
+	if !from.Before(to) {
+		return Statement{}, invalidRequest("from must be before to")
+	}
+
+	username, err := s.userRepo.GetUsername(userID)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	cryptos, err := s.cryptoRepo.GetCryptocurrencies()
+	if err != nil {
+		return Statement{}, err
+	}
+	scales := map[string]int{}
+	for _, crypto := range cryptos {
+		scales[crypto.Symbol] = crypto.Scale
+	}
+
+	balances, err := s.balanceRepo.GetUserBalances(userID)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	entries, err := s.ledgerRepo.GetEntriesSince(userID, from)
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to load ledger: %v", err)
+	}
+
+	assets := map[string]*AssetStatement{}
+	assetFor := func(symbol string) *AssetStatement {
+		asset, ok := assets[symbol]
+		if !ok {
+			asset = &AssetStatement{CryptoSymbol: symbol, Scale: scales[symbol]}
+			assets[symbol] = asset
+		}
+		return asset
+	}
+
+	for _, balance := range balances {
+		asset := assetFor(balance.CryptoName)
+		asset.OpeningBalance = balance.Balance
+		asset.ClosingBalance = balance.Balance
+	}
+	for _, entry := range entries {
+		asset := assetFor(entry.CryptoSymbol)
+		asset.OpeningBalance -= entry.Amount
+		if entry.CreatedAt.Before(to) {
+			asset.Entries = append(asset.Entries, entry)
+		} else {
+			asset.ClosingBalance -= entry.Amount
+		}
+	}
+
+	statement := Statement{Username: username, From: from, To: to}
+	for _, asset := range assets {
+		statement.Assets = append(statement.Assets, *asset)
+	}
+	sort.Slice(statement.Assets, func(i, j int) bool {
+		return statement.Assets[i].CryptoSymbol < statement.Assets[j].CryptoSymbol
+	})
+
+	return statement, nil
+}
+
+func entryReference(entry model.LedgerEntry) string {
+	if entry.ExchangeID != nil {
+		return fmt.Sprintf("exchange #%d", *entry.ExchangeID)
+	}
+	if entry.AdjustmentID != nil {
+		return fmt.Sprintf("adjustment #%d", *entry.AdjustmentID)
+	}
+	return ""
+}
+
+func WriteStatementCSV(w io.Writer, statement Statement) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"asset", "date", "type", "amount", "balance", "reference"})
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range statement.Assets {
+		rows := [][]string{{
+			asset.CryptoSymbol, statement.From.Format(time.RFC3339), "opening balance",
+			"", formatUnits(asset.OpeningBalance, asset.Scale), "",
+		}}
+		for _, entry := range asset.Entries {
+			rows = append(rows, []string{
+				asset.CryptoSymbol, entry.CreatedAt.UTC().Format(time.RFC3339), entry.Kind,
+				formatUnits(entry.Amount, asset.Scale), formatUnits(entry.BalanceAfter, asset.Scale), entryReference(entry),
+			})
+		}
+		rows = append(rows, []string{
+			asset.CryptoSymbol, statement.To.Format(time.RFC3339), "closing balance",
+			"", formatUnits(asset.ClosingBalance, asset.Scale), "",
+		})
+
+		err = writer.WriteAll(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func WriteStatementPDF(w io.Writer, statement Statement) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Account statement", false)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, "Account statement")
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.Cell(0, 6, fmt.Sprintf("Account: %s", statement.Username))
+	pdf.Ln(6)
+	pdf.Cell(0, 6, fmt.Sprintf("Period: %s to %s (UTC)", statement.From.UTC().Format(time.RFC3339), statement.To.UTC().Format(time.RFC3339)))
+	pdf.Ln(10)
+
+	widths := []float64{45, 30, 40, 40, 35}
+	headers := []string{"Date", "Type", "Amount", "Balance", "Reference"}
+
+	for _, asset := range statement.Assets {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.Cell(0, 8, asset.CryptoSymbol)
+		pdf.Ln(8)
+
+		pdf.SetFont("Helvetica", "B", 9)
+		for i, header := range headers {
+			pdf.CellFormat(widths[i], 6, header, "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Helvetica", "", 9)
+		row := func(cells ...string) {
+			for i, cell := range cells {
+				align := "L"
+				if i == 2 || i == 3 {
+					align = "R"
+				}
+				pdf.CellFormat(widths[i], 6, cell, "1", 0, align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+
+		row(statement.From.UTC().Format("2006-01-02 15:04"), "opening balance", "", formatUnits(asset.OpeningBalance, asset.Scale), "")
+		for _, entry := range asset.Entries {
+			row(entry.CreatedAt.UTC().Format("2006-01-02 15:04"), entry.Kind,
+				formatUnits(entry.Amount, asset.Scale), formatUnits(entry.BalanceAfter, asset.Scale), entryReference(entry))
+		}
+		row(statement.To.UTC().Format("2006-01-02 15:04"), "closing balance", "", formatUnits(asset.ClosingBalance, asset.Scale), "")
+		pdf.Ln(6)
+	}
+
+	return pdf.Output(w)
+}
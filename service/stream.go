@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	StreamChannelPrices   = "prices"
+	StreamChannelBalances = "balances"
+)
+
+// StreamMessage is a message sent to a streaming client.
+type StreamMessage struct {
+	Type      string      `json:"type"`
+	Pair      string      `json:"pair,omitempty"`
+	Price     float64     `json:"price,omitempty"`
+	Timestamp *time.Time  `json:"timestamp,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Message   string      `json:"message,omitempty"`
+}
+
+// StreamClient is one streaming connection. Messages for it are queued on
+// Send; when the queue is full the client is too slow, Send is closed and
+// the connection should be dropped.
+type StreamClient struct {
+	UserID int
+	Send   chan []byte
+
+	hub      *StreamHub
+	mu       sync.Mutex
+	pairs    map[string]bool
+	balances bool
+	closed   bool
+	slow     bool
+}
+
+// StreamHub fans price ticks and balance updates out to streaming clients.
+// Prices are fetched once per subscribed pair per tick, however many clients
+// follow the pair, and the last tick of each pair is kept for clients that
+// subscribe in between; balance updates are read from the outbox event
+// stream. Lock a client before the hub, never the other way round.
+type StreamHub struct {
+	prices      *PriceProvider
+	redisClient *redis.Client
+
+	mu      sync.Mutex
+	clients map[*StreamClient]bool
+	// followers counts the clients following each pair.
+	followers map[string]int
+	latest    map[string]StreamMessage
+}
+
+func NewStreamHub(prices *PriceProvider, redisClient *redis.Client) *StreamHub {
+	return &StreamHub{
+		prices:      prices,
+		redisClient: redisClient,
+		clients:     map[*StreamClient]bool{},
+		followers:   map[string]int{},
+		latest:      map[string]StreamMessage{},
+	}
+}
+
+func (h *StreamHub) Register(userID int) *StreamClient {
+	client := &StreamClient{
+		UserID: userID,
+		Send:   make(chan []byte, config.StreamSendBuffer),
+		hub:    h,
+		pairs:  map[string]bool{},
+	}
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+	return client
+}
+
+func (h *StreamHub) Unregister(client *StreamClient) {
+	client.mu.Lock()
+	pairs := client.pairs
+	client.pairs = map[string]bool{}
+	client.mu.Unlock()
+
+	h.mu.Lock()
+	delete(h.clients, client)
+	for pair := range pairs {
+		h.unfollowLocked(pair)
+	}
+	h.mu.Unlock()
+	client.close()
+}
+
+// follow counts a new follower of pair and returns the pair's last tick, if
+// any. It fails when pair would take the hub over config.MaxStreamPairs.
+func (h *StreamHub) follow(pair string) (*StreamMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.followers[pair] == 0 && len(h.followers) >= config.MaxStreamPairs {
+		return nil, fmt.Errorf("too many pairs are being streamed, try again later")
+	}
+	h.followers[pair]++
+	if latest, ok := h.latest[pair]; ok {
+		return &latest, nil
+	}
+	return nil, nil
+}
+
+func (h *StreamHub) unfollow(pair string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unfollowLocked(pair)
+}
+
+func (h *StreamHub) unfollowLocked(pair string) {
+	h.followers[pair]--
+	if h.followers[pair] <= 0 {
+		delete(h.followers, pair)
+		delete(h.latest, pair)
+	}
+}
+
+func (c *StreamClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
+// Enqueue queues a message without blocking. A client whose queue is full is
+// closed rather than allowed to hold up everyone else.
+func (c *StreamClient) Enqueue(message StreamMessage) {
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to encode stream message: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.Send <- encoded:
+	default:
+		log.Printf("Stream client of user %d is too slow, disconnecting", c.UserID)
+		c.closed = true
+		c.slow = true
+		close(c.Send)
+	}
+}
+
+// TooSlow reports whether the client was disconnected for falling behind.
+func (c *StreamClient) TooSlow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slow
+}
+
+// normalizePair turns "btc/usd" into "BTC/USD".
+func normalizePair(pair string) (string, error) {
+	parts := strings.Split(strings.ToUpper(pair), "/")
+	if len(parts) != 2 || !validSymbol(parts[0]) || !validSymbol(parts[1]) {
+		return "", fmt.Errorf("invalid pair: %s", pair)
+	}
+	return parts[0] + "/" + parts[1], nil
+}
+
+// Subscribe adds a channel subscription. Price subscriptions name their
+// pairs as CRYPTO/QUOTE, and are sent the last tick of each new pair the hub
+// already streams.
+func (c *StreamClient) Subscribe(channel string, pairs []string) error {
+	latest, err := c.subscribe(channel, pairs)
+	for _, message := range latest {
+		c.Enqueue(message)
+	}
+	return err
+}
+
+func (c *StreamClient) subscribe(channel string, pairs []string) ([]StreamMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch channel {
+	case StreamChannelBalances:
+		c.balances = true
+	case StreamChannelPrices:
+		if len(pairs) == 0 {
+			return nil, fmt.Errorf("pairs are required")
+		}
+		var latest []StreamMessage
+		for _, pair := range pairs {
+			normalized, err := normalizePair(pair)
+			if err != nil {
+				return latest, err
+			}
+			if c.pairs[normalized] {
+				continue
+			}
+			if len(c.pairs) >= config.MaxStreamSubscriptions {
+				return latest, fmt.Errorf("at most %d pairs can be subscribed", config.MaxStreamSubscriptions)
+			}
+			message, err := c.hub.follow(normalized)
+			if err != nil {
+				return latest, err
+			}
+			c.pairs[normalized] = true
+			if message != nil {
+				latest = append(latest, *message)
+			}
+		}
+		return latest, nil
+	default:
+		return nil, fmt.Errorf("unknown channel: %s", channel)
+	}
+	return nil, nil
+}
+
+// Unsubscribe removes a channel subscription, or only the given pairs of a
+// price subscription when any are named.
+func (c *StreamClient) Unsubscribe(channel string, pairs []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch channel {
+	case StreamChannelBalances:
+		c.balances = false
+	case StreamChannelPrices:
+		if len(pairs) == 0 {
+			for pair := range c.pairs {
+				c.hub.unfollow(pair)
+			}
+			c.pairs = map[string]bool{}
+		}
+		for _, pair := range pairs {
+			normalized, err := normalizePair(pair)
+			if err != nil {
+				return err
+			}
+			if c.pairs[normalized] {
+				c.hub.unfollow(normalized)
+				delete(c.pairs, normalized)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown channel: %s", channel)
+	}
+	return nil
+}
+
+func (c *StreamClient) followsPair(pair string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pairs[pair]
+}
+
+func (c *StreamClient) followsBalances() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.balances
+}
+
+func (h *StreamHub) snapshot() []*StreamClient {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients := make([]*StreamClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func (h *StreamHub) RunPrices(ctx context.Context) {
+	ticker := time.NewTicker(config.StreamPriceInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.publishPrices()
+		}
+	}
+}
+
+// publishPrices prices every followed pair once and sends the tick to its
+// followers.
+func (h *StreamHub) publishPrices() {
+	clients := h.snapshot()
+
+	h.mu.Lock()
+	pairs := make([]string, 0, len(h.followers))
+	for pair := range h.followers {
+		pairs = append(pairs, pair)
+	}
+	h.mu.Unlock()
+
+	for _, pair := range pairs {
+		symbols := strings.Split(pair, "/")
+		price, updatedAt, err := h.prices.PriceWithTime(symbols[0], symbols[1])
+		if err != nil {
+			log.Printf("Failed to get price of %s for streaming: %v", pair, err)
+			continue
+		}
+
+		message := StreamMessage{Type: "price", Pair: pair, Price: price, Timestamp: &updatedAt}
+		h.mu.Lock()
+		if h.followers[pair] > 0 {
+			h.latest[pair] = message
+		}
+		h.mu.Unlock()
+
+		for _, client := range clients {
+			if client.followsPair(pair) {
+				client.Enqueue(message)
+			}
+		}
+	}
+}
+
+// RunBalances follows the outbox event stream from its current end and
+// forwards each balance.changed event to the user's subscribed clients.
+func (h *StreamHub) RunBalances(ctx context.Context) {
+	lastID := "$"
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		streams, err := h.redisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{config.OutboxStream, lastID},
+			Count:   config.OutboxBatchSize,
+			Block:   config.StreamEventBlock * time.Second,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read event stream: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastID = message.ID
+				h.forwardBalanceEvent(message.Values)
+			}
+		}
+	}
+}
+
+func (h *StreamHub) forwardBalanceEvent(values map[string]interface{}) {
+	if values["event_type"] != model.EventBalanceChanged {
+		return
+	}
+
+	userID, err := strconv.Atoi(fmt.Sprint(values["user_id"]))
+	if err != nil {
+		return
+	}
+
+	var event Event
+	err = json.Unmarshal([]byte(fmt.Sprint(values["payload"])), &event)
+	if err != nil {
+		log.Printf("Failed to decode balance event: %v", err)
+		return
+	}
+
+	message := StreamMessage{Type: "balance", Timestamp: &event.CreatedAt, Data: event.Data}
+	for _, client := range h.snapshot() {
+		if client.UserID == userID && client.followsBalances() {
+			client.Enqueue(message)
+		}
+	}
+}
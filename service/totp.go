@@ -0,0 +1,104 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"swap-wallet/config"
+	"time"
+)
+
+// TOTP codes follow RFC 6238 with the parameters every authenticator app
+// defaults to: HMAC-SHA1, config.TOTPDigits digits and config.TOTPPeriod
+// second steps.
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret returns a random 160-bit secret in base32, as authenticator
+// apps expect it.
+func newTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// totpURI returns the otpauth:// URI authenticator apps import, usually from
+// a QR code.
+func totpURI(secret, account string) string {
+	// Spaces are escaped as %20 throughout, since some apps show a + in
+	// the issuer literally.
+	issuer := url.PathEscape(config.TOTPIssuer)
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&algorithm=SHA1&digits=%d&period=%d",
+		issuer, url.PathEscape(account), secret, issuer, config.TOTPDigits, config.TOTPPeriod)
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / config.TOTPPeriod
+}
+
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < config.TOTPDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", config.TOTPDigits, value%modulus)
+}
+
+// matchTOTP returns the step, within config.TOTPSkew steps of now and after
+// lastUsedStep, whose code is code. Codes of lastUsedStep and earlier steps
+// were already used or superseded, so they are never matched again.
+func matchTOTP(secret, code string, now time.Time, lastUsedStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != config.TOTPDigits {
+		return 0, false
+	}
+
+	current := totpStep(now)
+	for step := max(current-config.TOTPSkew, lastUsedStep+1); step <= current+config.TOTPSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newRecoveryCodes returns config.RecoveryCodeCount random codes formatted
+// as xxxxx-xxxxx.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, config.RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %v", err)
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// recoveryCodeHash returns the stored form of code. Case and dashes are
+// ignored, so codes can be typed either way.
+func recoveryCodeHash(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"swap-wallet/config"
+)
+
+// rfc6238Key is the SHA1 seed of the RFC 6238 Appendix B test vectors.
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; a shorter code is their last digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		want := tt.want[len(tt.want)-config.TOTPDigits:]
+		step := totpStep(time.Unix(tt.unix, 0))
+		if got := totpCode(rfc6238Key, step); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	now := time.Unix(1234567890, 0)
+	current := totpStep(now)
+	codeAt := func(step int64) string { return totpCode(rfc6238Key, step) }
+
+	tests := []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		wantStep     int64
+		wantOK       bool
+	}{
+		{"current step", codeAt(current), 0, current, true},
+		{"previous step within skew", codeAt(current - config.TOTPSkew), 0, current - config.TOTPSkew, true},
+		{"next step within skew", codeAt(current + config.TOTPSkew), 0, current + config.TOTPSkew, true},
+		{"step before skew", codeAt(current - config.TOTPSkew - 1), 0, 0, false},
+		{"step after skew", codeAt(current + config.TOTPSkew + 1), 0, 0, false},
+		{"wrong length", codeAt(current)[1:], 0, 0, false},
+		{"replayed step", codeAt(current), current, 0, false},
+		{"earlier step than last used", codeAt(current - 1), current, 0, false},
+		{"later step than last used", codeAt(current + 1), current, current + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(secret, tt.code, now, tt.lastUsedStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("matchTOTP = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodeHash(t *testing.T) {
+	want := recoveryCodeHash("abcde-fghij")
+	for _, code := range []string{"abcdefghij", "ABCDE-FGHIJ", " abcde-fghij "} {
+		if got := recoveryCodeHash(code); got != want {
+			t.Errorf("recoveryCodeHash(%q) = %s, want %s", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/middleware"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+// TwoFactorService enrols users in TOTP two-factor authentication and checks
+// their codes before sensitive actions. Recovery codes are accepted wherever
+// a TOTP code is, and each works once.
+type TwoFactorService struct {
+	twoFactorRepo *repository.TwoFactorRepository
+	userRepo      *repository.UserRepository
+	prices        *PriceProvider
+	audit         *AuditService
+
+	exchangeThreshold float64
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending" doc:"Enrolment was started but no code has been confirmed yet"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+}
+
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret" doc:"Base32 secret to type into an authenticator app"`
+	URI    string `json:"uri" doc:"otpauth:// URI of the secret, usually shown as a QR code"`
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" required:"true" doc:"Current code shown by the authenticator app"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes" doc:"Single-use codes accepted in place of a TOTP code. They are only shown once."`
+}
+
+func NewTwoFactorService(twoFactorRepo *repository.TwoFactorRepository, userRepo *repository.UserRepository, prices *PriceProvider, audit *AuditService) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo:     twoFactorRepo,
+		userRepo:          userRepo,
+		prices:            prices,
+		audit:             audit,
+		exchangeThreshold: config.LoadTwoFactorExchangeThreshold(),
+	}
+}
+
+func (s *TwoFactorService) Status(userID int) (TwoFactorStatus, error) {
+	twoFactor, err := s.twoFactorRepo.Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return TwoFactorStatus{}, nil
+	}
+	if err != nil {
+		return TwoFactorStatus{}, err
+	}
+
+	status := TwoFactorStatus{
+		Enabled:   twoFactor.EnabledAt != nil,
+		Pending:   twoFactor.EnabledAt == nil,
+		EnabledAt: twoFactor.EnabledAt,
+	}
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = s.twoFactorRepo.CountRecoveryCodes(userID)
+		if err != nil {
+			return TwoFactorStatus{}, err
+		}
+	}
+	return status, nil
+}
+
+// Enrol starts enrolment with a new secret, replacing any pending one. The
+// enrolment takes effect once ConfirmEnrolment accepts a code of the secret.
+func (s *TwoFactorService) Enrol(ctx context.Context, userID int) (TwoFactorEnrolment, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return TwoFactorEnrolment{}, err
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TwoFactorEnrolment{}, err
+	}
+
+	_, err = s.twoFactorRepo.SetPending(userID, secret)
+	if errors.Is(err, repository.ErrTwoFactorEnabled) {
+		return TwoFactorEnrolment{}, newError(CodeAlreadyExists, err, "two-factor authentication is already enabled")
+	}
+	if err != nil {
+		return TwoFactorEnrolment{}, err
+	}
+
+	return TwoFactorEnrolment{Secret: secret, URI: totpURI(secret, user.Username)}, nil
+}
+
+// ConfirmEnrolment enables two-factor authentication once code matches the
+// pending secret, and returns the user's first recovery codes. Invalid codes
+// count towards the same lockout as Verify.
+func (s *TwoFactorService) ConfirmEnrolment(ctx context.Context, userID int, code string) (RecoveryCodes, error) {
+	twoFactor, err := s.twoFactorRepo.Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return RecoveryCodes{}, invalidRequest("no two-factor enrolment was started")
+	}
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+	if twoFactor.EnabledAt != nil {
+		return RecoveryCodes{}, newError(CodeAlreadyExists, nil, "two-factor authentication is already enabled")
+	}
+
+	now := time.Now()
+	if err := checkLockout(twoFactor, now); err != nil {
+		return RecoveryCodes{}, err
+	}
+	step, ok := matchTOTP(twoFactor.Secret, strings.TrimSpace(code), now, twoFactor.LastUsedStep)
+	if !ok {
+		return RecoveryCodes{}, s.rejectCode(ctx, userID)
+	}
+
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+
+	err = s.twoFactorRepo.Enable(userID, step, hashes, func(tx *sql.Tx) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditTwoFactorEnabled,
+			ActorID:    userID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+		})
+	})
+	if errors.Is(err, repository.ErrTwoFactorEnabled) {
+		return RecoveryCodes{}, newError(CodeAlreadyExists, err, "two-factor authentication is already enabled")
+	}
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+	return codes, nil
+}
+
+// ResetRecoveryCodes replaces the user's recovery codes after checking code.
+func (s *TwoFactorService) ResetRecoveryCodes(ctx context.Context, userID int, code string) (RecoveryCodes, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return RecoveryCodes{}, err
+	}
+
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+
+	err = s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes, func(tx *sql.Tx) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditRecoveryCodesReset,
+			ActorID:    userID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+		})
+	})
+	if err != nil {
+		return RecoveryCodes{}, err
+	}
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking code.
+func (s *TwoFactorService) Disable(ctx context.Context, userID int, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return s.twoFactorRepo.Disable(userID, func(tx *sql.Tx) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditTwoFactorDisabled,
+			ActorID:    userID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+		})
+	})
+}
+
+// Verify checks code, a TOTP code or an unused recovery code, for userID,
+// who must have two-factor authentication enabled. Sensitive actions call it
+// before they run. Each TOTP code is accepted once, and repeated invalid
+// codes lock the user out for a while.
+func (s *TwoFactorService) Verify(ctx context.Context, userID int, code string) error {
+	twoFactor, err := s.twoFactorRepo.Get(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err != nil || twoFactor.EnabledAt == nil {
+		return newError(CodeTwoFactorRequired, nil, "this action requires two-factor authentication to be enabled")
+	}
+
+	now := time.Now()
+	if err := checkLockout(twoFactor, now); err != nil {
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return newError(CodeTwoFactorRequired, nil, "this action requires a two-factor code")
+	}
+
+	var used bool
+	if step, ok := matchTOTP(twoFactor.Secret, code, now, twoFactor.LastUsedStep); ok {
+		used, err = s.twoFactorRepo.UseStep(userID, step)
+	} else if len(code) != config.TOTPDigits {
+		used, err = s.twoFactorRepo.UseRecoveryCode(userID, recoveryCodeHash(code))
+	}
+	if err != nil {
+		return err
+	}
+	if used {
+		return nil
+	}
+	return s.rejectCode(ctx, userID)
+}
+
+// checkLockout fails while twoFactor is locked out after too many invalid
+// codes.
+func checkLockout(twoFactor model.TwoFactor, now time.Time) error {
+	if twoFactor.LockedUntil != nil && now.Before(*twoFactor.LockedUntil) {
+		return newError(CodeTwoFactorLocked, nil, "too many invalid two-factor codes, try again after %s",
+			twoFactor.LockedUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// rejectCode counts an invalid code against userID, locking them out after
+// config.MaxTOTPFailures in a row, and returns the error to answer with.
+func (s *TwoFactorService) rejectCode(ctx context.Context, userID int) error {
+	twoFactor, err := s.twoFactorRepo.RecordFailure(userID, config.MaxTOTPFailures, config.TOTPLockout*time.Second)
+	if err != nil {
+		return err
+	}
+	auditErr := s.audit.Record(ctx, AuditEvent{
+		Action:     model.AuditTwoFactorFailed,
+		ActorID:    userID,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+		After:      map[string]interface{}{"failed_attempts": twoFactor.FailedAttempts, "locked_until": twoFactor.LockedUntil},
+	})
+	if auditErr != nil {
+		log.Printf("Failed to audit two-factor failure of request %s: %v", middleware.RequestIDFrom(ctx), auditErr)
+	}
+	return newError(CodeInvalidTwoFactor, nil, "two-factor code is invalid or was already used")
+}
+
+// ExceedsThreshold reports whether amount of crypto is worth more than the
+// exchange threshold above which exchanges need a two-factor code. Amounts
+// that cannot be priced are treated as above it.
+func (s *TwoFactorService) ExceedsThreshold(crypto string, amount float64) bool {
+	if s.exchangeThreshold <= 0 {
+		return false
+	}
+	price, err := s.prices.Price(crypto, "USD")
+	return err != nil || amount*price > s.exchangeThreshold
+}
+
+// CheckAmount verifies code when amount of crypto exceeds the exchange
+// threshold.
+func (s *TwoFactorService) CheckAmount(ctx context.Context, userID int, crypto string, amount float64, code string) error {
+	if !s.ExceedsThreshold(crypto, amount) {
+		return nil
+	}
+
+	err := s.Verify(ctx, userID, code)
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) && serviceErr.Code == CodeTwoFactorRequired {
+		return newError(CodeTwoFactorRequired, err, "exchanges worth more than %s USD require two-factor authentication and a code",
+			strconv.FormatFloat(s.exchangeThreshold, 'f', -1, 64))
+	}
+	return err
+}
+
+// ApproveAmount is CheckAmount for exchanges that run later without the
+// user, such as scheduled ones. It returns when the code was verified, or
+// nil when the amount needed no code.
+func (s *TwoFactorService) ApproveAmount(ctx context.Context, userID int, crypto string, amount float64, code string) (*time.Time, error) {
+	if !s.ExceedsThreshold(crypto, amount) {
+		return nil, nil
+	}
+	if err := s.CheckAmount(ctx, userID, crypto, amount, code); err != nil {
+		return nil, err
+	}
+	approvedAt := time.Now()
+	return &approvedAt, nil
+}
+
+// Approved reports whether an approval that ApproveAmount gave at approvedAt
+// still stands. It lapses when two-factor authentication is disabled or
+// enabled again afterwards.
+func (s *TwoFactorService) Approved(userID int, approvedAt *time.Time) (bool, error) {
+	if approvedAt == nil {
+		return false, nil
+	}
+	twoFactor, err := s.twoFactorRepo.Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return twoFactor.EnabledAt != nil && !twoFactor.EnabledAt.After(*approvedAt), nil
+}
+
+// recoveryCodes returns new recovery codes and their hashes.
+func recoveryCodes() (RecoveryCodes, []string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return RecoveryCodes{}, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = recoveryCodeHash(code)
+	}
+	return RecoveryCodes{Codes: codes}, hashes, nil
+}
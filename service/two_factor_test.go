@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"swap-wallet/model"
+)
+
+func TestCheckLockout(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Second), now.Add(time.Second)
+
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		wantLocked  bool
+	}{
+		{"never locked", nil, false},
+		{"lockout over", &before, false},
+		{"locked", &after, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLockout(model.TwoFactor{LockedUntil: tt.lockedUntil}, now)
+			if tt.wantLocked && (err == nil || AsError(err).Code != CodeTwoFactorLocked) {
+				t.Errorf("checkLockout = %v, want %s", err, CodeTwoFactorLocked)
+			}
+			if !tt.wantLocked && err != nil {
+				t.Errorf("checkLockout = %v, want no error", err)
+			}
+		})
+	}
+}
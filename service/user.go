@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"unicode/utf8"
+)
+
+// UserService manages user accounts. Users register themselves and manage
+// their own profile; staff can look anyone up. Changing a user's status is
+// reserved to admins and every change is kept with its reason.
+type UserService struct {
+	userRepo   *repository.UserRepository
+	cryptoRepo *repository.CryptocurrencyRepository
+	audit      *AuditService
+}
+
+type RegisterUserRequest struct {
+	Username      string `json:"username" required:"true" doc:"3 to 32 letters, digits, '.', '_' or '-', stored in lower case"`
+	DisplayName   string `json:"displayName"`
+	Email         string `json:"email"`
+	QuoteCurrency string `json:"quoteCurrency" doc:"Currency balances are valued in when no quote is given (default USD)"`
+	Locale        string `json:"locale" doc:"Language tag such as en-US (default en-US)"`
+}
+
+// UpdateProfileRequest changes the fields that are set and keeps the rest.
+type UpdateProfileRequest struct {
+	DisplayName   *string `json:"displayName"`
+	Email         *string `json:"email" doc:"Empty removes the email"`
+	QuoteCurrency *string `json:"quoteCurrency"`
+	Locale        *string `json:"locale"`
+}
+
+type SetUserStatusRequest struct {
+	Status string `json:"status" required:"true" enum:"active,frozen,withdraw_only,closed"`
+	Reason string `json:"reason" required:"true" doc:"Why the status changes, kept in the user's status history"`
+}
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	localePattern   = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+)
+
+func NewUserService(userRepo *repository.UserRepository, cryptoRepo *repository.CryptocurrencyRepository, audit *AuditService) *UserService {
+	return &UserService{
+		userRepo:   userRepo,
+		cryptoRepo: cryptoRepo,
+		audit:      audit,
+	}
+}
+
+// normalizeProfile trims and canonicalises profile and checks every field.
+func (s *UserService) normalizeProfile(profile model.UserProfile) (model.UserProfile, error) {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	if utf8.RuneCountInString(profile.DisplayName) > config.MaxDisplayName {
+		return profile, invalidRequest("displayName must be at most %d characters", config.MaxDisplayName)
+	}
+
+	profile.Email = strings.ToLower(strings.TrimSpace(profile.Email))
+	if profile.Email != "" {
+		address, err := mail.ParseAddress(profile.Email)
+		if err != nil || address.Address != profile.Email || len(profile.Email) > config.MaxEmailLength {
+			return profile, invalidRequest("email must be a valid address")
+		}
+	}
+
+	profile.QuoteCurrency = strings.ToUpper(strings.TrimSpace(profile.QuoteCurrency))
+	if profile.QuoteCurrency == "" {
+		profile.QuoteCurrency = DefaultQuoteCurrency
+	}
+	if !slices.Contains(config.ProfileQuoteCurrencies, profile.QuoteCurrency) {
+		cryptoID, err := s.cryptoRepo.FindBySymbol(profile.QuoteCurrency)
+		if err != nil {
+			return profile, err
+		}
+		if cryptoID == -1 {
+			return profile, invalidRequest("quoteCurrency must be one of %s or a listed cryptocurrency", strings.Join(config.ProfileQuoteCurrencies, ", "))
+		}
+	}
+
+	profile.Locale = strings.ReplaceAll(strings.TrimSpace(profile.Locale), "_", "-")
+	if profile.Locale == "" {
+		profile.Locale = config.DefaultLocale
+	}
+	if language, region, ok := strings.Cut(profile.Locale, "-"); ok {
+		profile.Locale = strings.ToLower(language) + "-" + strings.ToUpper(region)
+	} else {
+		profile.Locale = strings.ToLower(profile.Locale)
+	}
+	if !localePattern.MatchString(profile.Locale) {
+		return profile, invalidRequest("locale must be a language tag such as en or en-US")
+	}
+
+	return profile, nil
+}
+
+// takenError converts the uniqueness failures of the user repository.
+func takenError(err error) error {
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		return newError(CodeAlreadyExists, err, "username is already taken")
+	}
+	if errors.Is(err, repository.ErrEmailTaken) {
+		return newError(CodeAlreadyExists, err, "email is already registered to another user")
+	}
+	return err
+}
+
+func userProfile(user model.User) model.UserProfile {
+	return model.UserProfile{
+		DisplayName:   user.DisplayName,
+		Email:         user.Email,
+		QuoteCurrency: user.QuoteCurrency,
+		Locale:        user.Locale,
+	}
+}
+
+// Register creates a user with the user role. Registration needs no
+// existing account, so the new user is recorded as the actor.
+func (s *UserService) Register(ctx context.Context, req RegisterUserRequest) (model.User, error) {
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+	if len(username) < config.MinUsernameLength || len(username) > config.MaxUsernameLength || !usernamePattern.MatchString(username) {
+		return model.User{}, invalidRequest("username must be %d to %d letters, digits, '.', '_' or '-', starting with a letter or digit",
+			config.MinUsernameLength, config.MaxUsernameLength)
+	}
+
+	profile, err := s.normalizeProfile(model.UserProfile{
+		DisplayName:   req.DisplayName,
+		Email:         req.Email,
+		QuoteCurrency: req.QuoteCurrency,
+		Locale:        req.Locale,
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := s.userRepo.CreateUser(username, profile, func(tx *sql.Tx, user model.User) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditUserRegistered,
+			ActorID:    user.ID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(user.ID),
+			After:      user,
+		})
+	})
+	return user, takenError(err)
+}
+
+// authorizeUser lets callers act on their own account and users with one of
+// roles act on anyone's.
+func (s *UserService) authorizeUser(ctx context.Context, callerID, userID int, roles ...string) error {
+	if callerID == userID {
+		return nil
+	}
+	return s.audit.RequireRole(ctx, callerID, roles...)
+}
+
+// GetUser returns userID's account to the user or to staff.
+func (s *UserService) GetUser(ctx context.Context, callerID, userID int) (model.User, error) {
+	if err := s.authorizeUser(ctx, callerID, userID, model.RoleAdmin, model.RoleCompliance); err != nil {
+		return model.User{}, err
+	}
+	return s.userRepo.GetUser(userID)
+}
+
+// LookupUser finds a user by exact username or email for staff.
+func (s *UserService) LookupUser(ctx context.Context, callerID int, username, email string) (model.User, error) {
+	if err := s.audit.RequireRole(ctx, callerID, model.RoleAdmin, model.RoleCompliance); err != nil {
+		return model.User{}, err
+	}
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	email = strings.ToLower(strings.TrimSpace(email))
+	switch {
+	case username != "" && email != "":
+		return model.User{}, invalidRequest("give either username or email, not both")
+	case username != "":
+		return s.userRepo.FindByUsername(username)
+	case email != "":
+		return s.userRepo.FindByEmail(email)
+	default:
+		return model.User{}, invalidRequest("username or email is required")
+	}
+}
+
+// UpdateProfile changes the fields set in req on userID's profile, on behalf
+// of the user or an admin.
+func (s *UserService) UpdateProfile(ctx context.Context, callerID, userID int, req UpdateProfileRequest) (model.User, error) {
+	if err := s.authorizeUser(ctx, callerID, userID, model.RoleAdmin); err != nil {
+		return model.User{}, err
+	}
+
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	profile := userProfile(user)
+	if req.DisplayName != nil {
+		profile.DisplayName = *req.DisplayName
+	}
+	if req.Email != nil {
+		profile.Email = *req.Email
+	}
+	if req.QuoteCurrency != nil {
+		profile.QuoteCurrency = *req.QuoteCurrency
+	}
+	if req.Locale != nil {
+		profile.Locale = *req.Locale
+	}
+	profile, err = s.normalizeProfile(profile)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err = s.userRepo.UpdateProfile(userID, profile, func(tx *sql.Tx, before, after model.User) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditUserProfileUpdated,
+			ActorID:    callerID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+			Before:     userProfile(before),
+			After:      userProfile(after),
+		})
+	})
+	return user, takenError(err)
+}
+
+// DeleteUser soft-deletes userID's account on behalf of the user or an
+// admin. The account must not hold any balance. Deleted users can no longer
+// sign in; their history is kept and their email can be registered again.
+func (s *UserService) DeleteUser(ctx context.Context, callerID, userID int) error {
+	if err := s.authorizeUser(ctx, callerID, userID, model.RoleAdmin); err != nil {
+		return err
+	}
+
+	_, err := s.userRepo.DeleteUser(userID, func(tx *sql.Tx, user model.User) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditUserDeleted,
+			ActorID:    callerID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+			Before:     map[string]string{"status": user.Status},
+			After:      map[string]interface{}{"deleted_at": user.DeletedAt},
+		})
+	})
+	if errors.Is(err, repository.ErrAccountNotEmpty) {
+		return newError(CodeAccountNotEmpty, err, "user %d still holds a balance", userID)
+	}
+	return err
+}
+
+func validateUserStatus(req SetUserStatusRequest) error {
+	switch req.Status {
+	case model.UserActive, model.UserFrozen, model.UserWithdrawOnly, model.UserClosed:
+	default:
+		return invalidRequest("unsupported status: %s", req.Status)
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) < config.MinStatusReason {
+		return invalidRequest("reason must be at least %d characters", config.MinStatusReason)
+	}
+	if len(reason) > config.MaxStatusReason {
+		return invalidRequest("reason must be at most %d characters", config.MaxStatusReason)
+	}
+	return nil
+}
+
+// SetStatus changes userID's status on behalf of adminID. Admins cannot
+// change their own status, so nobody can lock themselves out by mistake.
+func (s *UserService) SetStatus(ctx context.Context, adminID, userID int, req SetUserStatusRequest) (model.UserStatusChange, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin); err != nil {
+		return model.UserStatusChange{}, err
+	}
+	if err := validateUserStatus(req); err != nil {
+		return model.UserStatusChange{}, err
+	}
+	if adminID == userID {
+		return model.UserStatusChange{}, newError(CodeForbidden, nil, "admins cannot change their own status")
+	}
+
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return model.UserStatusChange{}, err
+	}
+	if user.Status == req.Status {
+		return model.UserStatusChange{}, invalidRequest("user %d is already %s", userID, req.Status)
+	}
+
+	return s.userRepo.SetStatus(userID, req.Status, strings.TrimSpace(req.Reason), adminID, func(tx *sql.Tx, change model.UserStatusChange) error {
+		return s.audit.RecordTx(ctx, tx, AuditEvent{
+			Action:     model.AuditUserStatusChanged,
+			ActorID:    adminID,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userID),
+			Before:     map[string]string{"status": change.FromStatus},
+			After:      map[string]string{"status": change.ToStatus, "reason": change.Reason},
+		})
+	})
+}
+
+// GetStatusHistory lists userID's status changes, newest first.
+func (s *UserService) GetStatusHistory(ctx context.Context, adminID, userID int) ([]model.UserStatusChange, error) {
+	if err := s.audit.RequireRole(ctx, adminID, model.RoleAdmin, model.RoleCompliance); err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetUser(userID); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetStatusHistory(userID)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"swap-wallet/config"
+	"swap-wallet/model"
+	"swap-wallet/repository"
+	"time"
+)
+
+const (
+	WebhookEventHeader     = "X-Swap-Wallet-Event"
+	WebhookDeliveryHeader  = "X-Swap-Wallet-Delivery"
+	WebhookSignatureHeader = "X-Swap-Wallet-Signature"
+)
+
+var webhookEventTypes = map[string]bool{
+	model.EventExchangeCompleted: true,
+	model.EventBalanceChanged:    true,
+	model.EventSchedulePaused:    true,
+}
+
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	twoFactor   *TwoFactorService
+	client      *http.Client
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" required:"true"`
+	Events []string `json:"events" required:"true" enum:"exchange.completed,balance.changed,schedule.paused"`
+}
+
+func NewWebhookService(webhookRepo *repository.WebhookRepository, twoFactor *TwoFactorService) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		twoFactor:   twoFactor,
+		client:      newOutboundClient(config.WebhookTimeout * time.Second),
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateEndpoint registers an endpoint with a freshly generated signing
+// secret, which is returned only here. The URL must resolve to public
+// addresses only.
+// CreateEndpoint registers a webhook endpoint and generates its signing
+// secret. Like an API key, the secret needs the user's two-factor code.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, userID int, req CreateWebhookRequest, totpCode string) (model.WebhookEndpoint, error) {
+	if err := checkOutboundURL(ctx, req.URL); err != nil {
+		return model.WebhookEndpoint{}, invalidRequest("url %v", err)
+	}
+
+	if len(req.Events) == 0 {
+		return model.WebhookEndpoint{}, invalidRequest("at least one event type is required")
+	}
+	for _, eventType := range req.Events {
+		if !webhookEventTypes[eventType] {
+			return model.WebhookEndpoint{}, invalidRequest("unsupported event type: %s", eventType)
+		}
+	}
+	if err := s.twoFactor.Verify(ctx, userID, totpCode); err != nil {
+		return model.WebhookEndpoint{}, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return model.WebhookEndpoint{}, fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+
+	return s.webhookRepo.CreateEndpoint(model.WebhookEndpoint{
+		UserID:     userID,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.Events,
+	})
+}
+
+func (s *WebhookService) GetUserEndpoints(userID int) ([]model.WebhookEndpoint, error) {
+	return s.webhookRepo.GetUserEndpoints(userID)
+}
+
+func (s *WebhookService) DeleteEndpoint(userID, endpointID int) error {
+	return s.webhookRepo.DeleteEndpoint(userID, endpointID)
+}
+
+func (s *WebhookService) GetDeliveries(userID, endpointID int) ([]model.WebhookDelivery, error) {
+	return s.webhookRepo.GetEndpointDeliveries(userID, endpointID, config.WebhookDeliveryBatch)
+}
+
+func (s *WebhookService) Redeliver(userID, endpointID, deliveryID int) (model.WebhookDelivery, error) {
+	return s.webhookRepo.Redeliver(userID, endpointID, deliveryID)
+}
+
+// enqueue queues an encoded event for the user's subscribed endpoints within
+// tx.
+func (s *WebhookService) enqueue(tx *sql.Tx, userID int, event Event, payload string) error {
+	return s.webhookRepo.EnqueueEvent(tx, userID, event.ID, event.Type, payload)
+}
+
+// SignPayload returns the signature header value for payload sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">".
+// Receivers recompute it with the endpoint secret and should reject stale
+// timestamps.
+func SignPayload(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(payload)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) RunDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(config.WebhookDeliveryInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.deliverDue(now)
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(now time.Time) {
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(now, now.Add(config.WebhookClaimLease*time.Second), config.WebhookDeliveryBatch)
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		s.deliver(delivery)
+	}
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := config.WebhookRetryBase
+	for i := 1; i < attempts && delay < config.WebhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > config.WebhookRetryMax {
+		delay = config.WebhookRetryMax
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// deliver makes one attempt at a delivery and records the outcome. Any 2xx
+// response counts as delivered; otherwise the delivery is retried with
+// exponential backoff until it runs out of attempts and is marked failed.
+func (s *WebhookService) deliver(delivery repository.DueDelivery) {
+	now := time.Now()
+	payload := []byte(delivery.Payload)
+
+	var responseCode *int
+	var deliveryErr error
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(payload))
+	if err != nil {
+		deliveryErr = err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookEventHeader, delivery.EventType)
+		req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+		req.Header.Set(WebhookSignatureHeader, SignPayload(delivery.Secret, now, payload))
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			deliveryErr = err
+		} else {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			code := resp.StatusCode
+			responseCode = &code
+			if code < 200 || code >= 300 {
+				deliveryErr = fmt.Errorf("endpoint responded with status %d", code)
+			}
+		}
+	}
+
+	if deliveryErr == nil {
+		err = s.webhookRepo.RecordAttempt(delivery.ID, model.DeliverySucceeded, responseCode, "", now, &now)
+	} else {
+		attempts := delivery.Attempts + 1
+		status := model.DeliveryPending
+		if attempts >= config.MaxWebhookAttempts {
+			status = model.DeliveryFailed
+		}
+		err = s.webhookRepo.RecordAttempt(delivery.ID, status, responseCode, deliveryErr.Error(), now.Add(retryDelay(attempts)), nil)
+	}
+	if err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}